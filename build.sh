@@ -46,9 +46,21 @@ echo -e "${BLUE}║      Hugo Site Build Process           ║${NC}"
 echo -e "${BLUE}╚════════════════════════════════════════╝${NC}"
 echo ""
 
-# Step 1: Image dithering
+# Step 1: Check image metadata without changing any file; fails if an
+# image has a GPS location. Stripping is done with
+# go run scripts/strip_metadata.go --recursive, and the result committed.
+echo -e "${YELLOW}[1/3]${NC} Checking image metadata..."
+if ! go run scripts/strip_metadata.go content assets static --recursive --check; then
+    echo ""
+    echo -e "${RED}✗${NC} Image metadata check failed: run go run scripts/strip_metadata.go --recursive, commit the stripped images and build again"
+    exit 1
+fi
+echo -e "${GREEN}✓${NC} No image has a GPS location"
+echo ""
+
+# Step 2: Image dithering
 if [ "$SKIP_DITHER" = false ]; then
-    echo -e "${YELLOW}[1/2]${NC} Processing images..."
+    echo -e "${YELLOW}[2/3]${NC} Processing images..."
     
     DITHER_OPTS="--recursive"
     if [ "$FORCE_DITHER" = true ]; then
@@ -56,33 +68,39 @@ if [ "$SKIP_DITHER" = false ]; then
         echo -e "      ${BLUE}↳${NC} Force mode: Re-dithering all images"
     fi
     
+    # Resize covers and posters first, so their variants get dithered too
+    if [ -d "static/images" ]; then
+        echo -e "      ${BLUE}↳${NC} Creating responsive variants in static/images/"
+        go run scripts/image_variants.go static/images $DITHER_OPTS
+    fi
+    
     # Dither images in assets directory
     if [ -d "assets" ]; then
         echo -e "      ${BLUE}↳${NC} Dithering images in assets/"
-        ./scripts/dither_images.sh assets $DITHER_OPTS
+        go run scripts/dither_images.go assets $DITHER_OPTS
     fi
     
     # Dither images in content directory
     if [ -d "content" ]; then
         echo -e "      ${BLUE}↳${NC} Dithering images in content/"
-        ./scripts/dither_images.sh content $DITHER_OPTS
+        go run scripts/dither_images.go content $DITHER_OPTS
     fi
     
     # Dither images in static directory
     if [ -d "static" ]; then
         echo -e "      ${BLUE}↳${NC} Dithering images in static/"
-        ./scripts/dither_images.sh static $DITHER_OPTS
+        go run scripts/dither_images.go static $DITHER_OPTS
     fi
     
     echo -e "${GREEN}✓${NC} Image processing complete"
     echo ""
 else
-    echo -e "${YELLOW}[1/2]${NC} Skipping image dithering"
+    echo -e "${YELLOW}[2/3]${NC} Skipping image dithering"
     echo ""
 fi
 
-# Step 2: Build site
-echo -e "${YELLOW}[2/2]${NC} Building Hugo site..."
+# Step 3: Build site
+echo -e "${YELLOW}[3/3]${NC} Building Hugo site..."
 echo ""
 
 if hugo; then
@@ -2,4 +2,7 @@ module github.com/christiankopac/christiankopac_com__hugo
 
 go 1.21
 
-require github.com/joho/godotenv v1.5.1
+require (
+	github.com/joho/godotenv v1.5.1
+	golang.org/x/image v0.18.0
+)
@@ -0,0 +1,771 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/artwork"
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/consumed"
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/imageset"
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/journal"
+)
+
+const consumedUsage = `Usage: go run scripts/consumed.go <command> [flags] [args]
+
+Reading status:
+  want <book>                 Mark a book as want-to-read
+  start <book>                Start reading a book (-date, -pages)
+  progress <book> <page>      Record the page you are on
+  finish <book>               Finish a book (-date, -rating)
+  abandon <book>              Give up on a book (-date)
+  status [status]             List books, optionally only those with a status
+  footers                     Regenerate every book footer from its status and dates
+
+Artwork:
+  artwork [-force]            Add placeholder, color and accent to pages with a local img
+  rename-artwork [-dry-run]   Rename title-only posters and covers to title_year_poster.jpg
+
+Download runs:
+  undo [-force] [run-id]      Restore the pages and artwork a download run or rename-artwork changed (the last one by default)
+  undo -list                  List the recorded runs
+
+<book> is a page slug (cant-hurt-me-master-your-mind-and-defy-the-odds) or a title.
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Print(consumedUsage)
+		os.Exit(1)
+	}
+
+	baseDir := getBaseDir()
+	contentDir := filepath.Join(baseDir, "content")
+
+	cmd, args := os.Args[1], os.Args[2:]
+	var err error
+	switch cmd {
+	case "want":
+		err = transitionCommand(contentDir, consumed.WantToRead, args)
+	case "start":
+		err = transitionCommand(contentDir, consumed.Reading, args)
+	case "finish":
+		err = transitionCommand(contentDir, consumed.Finished, args)
+	case "abandon":
+		err = transitionCommand(contentDir, consumed.Abandoned, args)
+	case "progress":
+		err = progressCommand(contentDir, args)
+	case "status":
+		err = statusCommand(contentDir, args)
+	case "footers":
+		err = footersCommand(contentDir)
+	case "artwork":
+		err = artworkCommand(baseDir, args)
+	case "rename-artwork":
+		err = renameArtworkCommand(baseDir, args)
+	case "undo":
+		err = undoCommand(baseDir, args)
+	case "help", "-h", "--help":
+		fmt.Print(consumedUsage)
+	default:
+		fmt.Printf("Unknown command: %s\n\n%s", cmd, consumedUsage)
+		os.Exit(1)
+	}
+
+	if err != nil {
+		fmt.Printf("✗ %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func getBaseDir() string {
+	// Start from current working directory and walk up to find project root
+	wd, _ := os.Getwd()
+	startWd := wd
+	for {
+		if _, err := os.Stat(filepath.Join(wd, "content")); err == nil {
+			return wd
+		}
+		parent := filepath.Dir(wd)
+		if parent == wd {
+			break
+		}
+		wd = parent
+	}
+	return startWd
+}
+
+// findBookPages returns every language version of the book matching a slug
+// or title
+func findBookPages(contentDir, book string) ([]*consumed.Page, error) {
+	pages, err := consumed.LoadAll(contentDir, "book")
+	if err != nil {
+		return nil, err
+	}
+
+	slug := ""
+	for _, page := range pages {
+		if page.Slug() == book {
+			slug = book
+			break
+		}
+	}
+	if slug == "" {
+		key := consumed.TitleKey(book)
+		for _, page := range pages {
+			if consumed.TitleKey(page.String("title")) == key {
+				slug = page.Slug()
+				break
+			}
+		}
+	}
+	if slug == "" {
+		return nil, fmt.Errorf("no book page found for %q", book)
+	}
+
+	var matches []*consumed.Page
+	for _, page := range pages {
+		if page.Slug() == slug {
+			matches = append(matches, page)
+		}
+	}
+	return matches, nil
+}
+
+func parseDateFlag(value string) (time.Time, error) {
+	if value == "" {
+		now := time.Now()
+		return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC), nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q, expected YYYY-MM-DD", value)
+	}
+	return t, nil
+}
+
+// parseArgs parses flags that may come before or after the positional
+// arguments, e.g. "start dune -pages 412"
+func parseArgs(fs *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		fs.Parse(args)
+		if fs.NArg() == 0 {
+			return positional
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+func transitionCommand(contentDir string, to consumed.Status, args []string) error {
+	fs := flag.NewFlagSet(string(to), flag.ExitOnError)
+	date := fs.String("date", "", "Date of the change, YYYY-MM-DD (default: today)")
+	pages := fs.Int("pages", 0, "Total number of pages")
+	rating := fs.Float64("rating", 0, "Rating to record when finishing")
+	args = parseArgs(fs, args)
+
+	if len(args) != 1 {
+		return fmt.Errorf("expected exactly one book, got %d", len(args))
+	}
+	on, err := parseDateFlag(*date)
+	if err != nil {
+		return err
+	}
+
+	matches, err := findBookPages(contentDir, args[0])
+	if err != nil {
+		return err
+	}
+
+	for _, page := range matches {
+		if *pages > 0 {
+			page.Set("pages", strconv.Itoa(*pages), "publisher", "author", "year", "category")
+		}
+		if err := consumed.Transition(page, to, on); err != nil {
+			return fmt.Errorf("%s: %w", page.Slug(), err)
+		}
+		if *rating > 0 {
+			page.Set("rating", strconv.FormatFloat(*rating, 'f', -1, 64), "footer")
+		}
+	}
+
+	// Only write once every language version accepted the transition
+	for _, page := range matches {
+		if err := page.Save(); err != nil {
+			return err
+		}
+		fmt.Printf("✓ %s/%s: %s (footer = %q)\n", page.Lang, page.Slug(), to, page.String("footer"))
+	}
+	return nil
+}
+
+func progressCommand(contentDir string, args []string) error {
+	fs := flag.NewFlagSet("progress", flag.ExitOnError)
+	pages := fs.Int("pages", 0, "Total number of pages")
+	args = parseArgs(fs, args)
+
+	if len(args) != 2 {
+		return fmt.Errorf("expected a book and a page number")
+	}
+	page, err := strconv.Atoi(args[1])
+	if err != nil || page < 0 {
+		return fmt.Errorf("invalid page number %q", args[1])
+	}
+
+	matches, err := findBookPages(contentDir, args[0])
+	if err != nil {
+		return err
+	}
+
+	for _, p := range matches {
+		if *pages > 0 {
+			p.Set("pages", strconv.Itoa(*pages), "publisher", "author", "year", "category")
+		}
+		if err := consumed.SetProgress(p, page); err != nil {
+			return fmt.Errorf("%s: %w", p.Slug(), err)
+		}
+	}
+
+	for _, p := range matches {
+		if err := p.Save(); err != nil {
+			return err
+		}
+		fmt.Printf("✓ %s/%s: page %d (footer = %q)\n", p.Lang, p.Slug(), page, p.String("footer"))
+	}
+	return nil
+}
+
+func statusCommand(contentDir string, args []string) error {
+	var only consumed.Status
+	if len(args) > 0 {
+		status, err := consumed.ParseStatus(args[0])
+		if err != nil {
+			return err
+		}
+		only = status
+	}
+
+	pages, err := consumed.LoadAll(contentDir, "book")
+	if err != nil {
+		return err
+	}
+	seen := make(map[string]bool)
+	for _, page := range pages {
+		if seen[page.Slug()] {
+			continue
+		}
+		seen[page.Slug()] = true
+
+		status := consumed.BookStatus(page)
+		if only != "" && status != only {
+			continue
+		}
+		line := fmt.Sprintf("%-13s %s", status, page.String("title"))
+		if progress, ok := page.Float("progress"); ok && status == consumed.Reading {
+			line += fmt.Sprintf(" (page %d", int(progress))
+			if total, ok := page.Float("pages"); ok {
+				line += fmt.Sprintf(" of %d", int(total))
+			}
+			line += ")"
+		}
+		fmt.Println(line)
+	}
+	return nil
+}
+
+func footersCommand(contentDir string) error {
+	pages, err := consumed.LoadAll(contentDir, "book")
+	if err != nil {
+		return err
+	}
+	updated := 0
+	for _, page := range pages {
+		footer := consumed.BookFooter(page, page.Lang)
+		if footer == "" || footer == page.String("footer") {
+			continue
+		}
+		page.SetString("footer", footer, "finished", "started", "status")
+		if err := page.Save(); err != nil {
+			return err
+		}
+		fmt.Printf("✓ %s/%s: %s\n", page.Lang, page.Slug(), footer)
+		updated++
+	}
+
+	fmt.Printf("\n%s\n", strings.Repeat("=", 50))
+	fmt.Printf("Summary:\n")
+	fmt.Printf("  Footers updated: %d\n", updated)
+	return nil
+}
+
+// artworkCommand fills in the placeholder and colors of every page whose
+// img is a local file, skipping pages that already have them unless -force
+func artworkCommand(baseDir string, args []string) error {
+	fs := flag.NewFlagSet("artwork", flag.ExitOnError)
+	force := fs.Bool("force", false, "Recompute pages that already have a placeholder")
+	if rest := parseArgs(fs, args); len(rest) > 0 {
+		return fmt.Errorf("artwork takes no arguments")
+	}
+
+	contentDir := filepath.Join(baseDir, "content")
+	cache := make(map[string]imageset.Artwork)
+	updated, skipped, failed := 0, 0, 0
+	for _, kind := range []string{"book", "movie", "music"} {
+		pages, err := consumed.LoadAll(contentDir, kind)
+		if err != nil {
+			return err
+		}
+		for _, page := range pages {
+			img := page.String("img")
+			if img == "" || strings.Contains(img, "://") {
+				continue
+			}
+			if page.String("placeholder") != "" && !*force {
+				skipped++
+				continue
+			}
+			art, ok := cache[img]
+			if !ok {
+				file, found := imageset.Locate(baseDir, img)
+				if !found {
+					fmt.Printf("⚠ %s/%s: %s not found\n", page.Lang, page.Slug(), img)
+					failed++
+					continue
+				}
+				if art, err = imageset.Analyze(file); err != nil {
+					fmt.Printf("✗ %s/%s: %v\n", page.Lang, page.Slug(), err)
+					failed++
+					continue
+				}
+				cache[img] = art
+			}
+			art.Apply(page)
+			if err := page.Save(); err != nil {
+				return err
+			}
+			fmt.Printf("✓ %s/%s: %s, accent %s\n", page.Lang, page.Slug(), art.Color, art.Accent)
+			updated++
+		}
+	}
+
+	fmt.Printf("\n%s\n", strings.Repeat("=", 50))
+	fmt.Printf("Summary:\n")
+	fmt.Printf("  Pages updated: %d\n", updated)
+	if skipped > 0 {
+		fmt.Printf("  Already done: %d\n", skipped)
+	}
+	if failed > 0 {
+		fmt.Printf("  Failed: %d\n", failed)
+	}
+	return nil
+}
+
+// legacyArtworkPattern matches artwork named from the title alone,
+// the_kingdom_poster.jpg, capturing the slug and the suffix
+var legacyArtworkPattern = regexp.MustCompile(`^(.+)_(poster|cover)$`)
+
+// renameArtworkCommand renames posters and covers named from the title
+// alone to the collision-free slug_key_poster.jpg names the download
+// scripts use now, with their dithered copies and variants, and updates
+// every reference to them
+func renameArtworkCommand(baseDir string, args []string) error {
+	fs := flag.NewFlagSet("rename-artwork", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "Show the renames without changing anything")
+	if rest := parseArgs(fs, args); len(rest) > 0 {
+		return fmt.Errorf("rename-artwork takes no arguments")
+	}
+
+	contentDir := filepath.Join(baseDir, "content")
+	pagesByImg := make(map[string][]*consumed.Page)
+	for _, kind := range []string{"book", "movie", "music"} {
+		pages, err := consumed.LoadAll(contentDir, kind)
+		if err != nil {
+			return err
+		}
+		for _, page := range pages {
+			img := page.String("img")
+			if img == "" || strings.Contains(img, "://") {
+				continue
+			}
+			pagesByImg[img] = append(pagesByImg[img], page)
+		}
+	}
+	imgs := make([]string, 0, len(pagesByImg))
+	for img := range pagesByImg {
+		imgs = append(imgs, img)
+	}
+	sort.Strings(imgs)
+
+	refs, err := imageset.FindRefs(baseDir)
+	if err != nil {
+		return err
+	}
+
+	var renames []artworkRename
+	skipped := 0
+	for _, img := range imgs {
+		pages := pagesByImg[img]
+		newImg, err := artworkName(img, pages)
+		if err != nil {
+			fmt.Printf("⚠ %s: %v\n", img, err)
+			skipped++
+			continue
+		}
+		if newImg == "" {
+			continue
+		}
+		if lockedImg(pages) {
+			fmt.Printf("🔒 %s: img is locked, not renamed\n", img)
+			skipped++
+			continue
+		}
+		file, ok := imageset.Locate(baseDir, img)
+		if !ok {
+			fmt.Printf("⚠ %s: file not found\n", img)
+			skipped++
+			continue
+		}
+		newFile := filepath.Join(filepath.Dir(file), path.Base(newImg))
+		if _, err := os.Stat(newFile); err == nil {
+			fmt.Printf("⚠ %s: %s already exists\n", img, path.Base(newImg))
+			skipped++
+			continue
+		}
+		generated, err := generatedFiles(file)
+		if err != nil {
+			return err
+		}
+
+		r := artworkRename{img: img, newImg: newImg, files: append([]string{file}, generated...), edits: make(map[string]map[string]string)}
+		for _, ref := range refs {
+			if resolved, ok := ref.Resolve(baseDir); !ok || !sameFile(resolved, file) {
+				continue
+			}
+			if r.edits[ref.File] == nil {
+				r.edits[ref.File] = make(map[string]string)
+			}
+			r.edits[ref.File][ref.Value] = strings.TrimSuffix(ref.Value, path.Base(ref.Value)) + path.Base(newImg)
+		}
+		renames = append(renames, r)
+	}
+
+	// Recorded like a download run, so consumed undo reverts it
+	var run *journal.Journal
+	if !*dryRun && len(renames) > 0 {
+		entries := make([]journal.Entry, len(renames))
+		for i, r := range renames {
+			entries[i] = journal.Entry{Key: r.img, Title: r.img}
+		}
+		if run, err = journal.New(baseDir, "rename-artwork", entries); err != nil {
+			return fmt.Errorf("starting the run journal: %w", err)
+		}
+	}
+
+	// Each image's references are updated right after it is renamed, so
+	// an error leaves no page pointing at a name that's gone
+	updated := make(map[string]bool)
+	for _, r := range renames {
+		fmt.Printf("✓ %s → %s", r.img, r.newImg)
+		if len(r.files) > 1 {
+			fmt.Printf(" (+%d generated)", len(r.files)-1)
+		}
+		fmt.Println()
+		for _, file := range sortedKeys(r.edits) {
+			rel, _ := filepath.Rel(baseDir, file)
+			fmt.Printf("  📝 %s\n", rel)
+			updated[file] = true
+		}
+		if *dryRun {
+			continue
+		}
+		if err := r.apply(run); err != nil {
+			return fmt.Errorf("%s: %w (undo what was done with consumed undo %s)", r.img, err, run.ID)
+		}
+		if err := run.Mark(r.img, journal.Done); err != nil {
+			return err
+		}
+	}
+	if run != nil {
+		if err := run.Finish(); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("\n%s\n", strings.Repeat("=", 50))
+	fmt.Printf("Summary:\n")
+	fmt.Printf("  Renamed: %d\n", len(renames))
+	fmt.Printf("  Files updated: %d\n", len(updated))
+	if skipped > 0 {
+		fmt.Printf("  Skipped: %d\n", skipped)
+	}
+	if *dryRun {
+		fmt.Println("\nDry run, nothing was changed.")
+	} else if run != nil {
+		fmt.Printf("\nUndo with: go run scripts/consumed.go undo %s\n", run.ID)
+	}
+	return nil
+}
+
+// artworkRename is an image to rename, with its generated files (the first
+// of files is the image) and the references to rewrite in each file
+type artworkRename struct {
+	img, newImg string
+	files       []string
+	edits       map[string]map[string]string
+}
+
+// apply renames the image and its generated files and rewrites the
+// references to it, recording every file in run
+func (r artworkRename) apply(run *journal.Journal) error {
+	oldStem := strings.TrimSuffix(filepath.Base(r.files[0]), filepath.Ext(r.files[0]))
+	newStem := strings.TrimSuffix(path.Base(r.newImg), path.Ext(r.newImg))
+	for _, f := range r.files {
+		target := filepath.Join(filepath.Dir(f), newStem+strings.TrimPrefix(filepath.Base(f), oldStem))
+		err := recordWrite(run, []string{f, target}, func() error {
+			return os.Rename(f, target)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	for _, file := range sortedKeys(r.edits) {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		text := string(content)
+		for from, to := range r.edits[file] {
+			text = strings.ReplaceAll(text, `"`+from+`"`, `"`+to+`"`)
+			text = strings.ReplaceAll(text, "("+from+")", "("+to+")")
+			text = strings.ReplaceAll(text, "("+from+" ", "("+to+" ")
+		}
+		err = recordWrite(run, []string{file}, func() error {
+			return consumed.WriteFile(file, []byte(text))
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordWrite backs up paths in run, changes them with write and records
+// what write left there
+func recordWrite(run *journal.Journal, paths []string, write func() error) error {
+	for _, p := range paths {
+		if err := run.Backup(p); err != nil {
+			return err
+		}
+	}
+	if err := write(); err != nil {
+		return err
+	}
+	for _, p := range paths {
+		if err := run.Written(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sortedKeys(m map[string]map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// undoCommand restores what a download run replaced, from the copies kept
+// in its journal. Files changed since the run are left alone unless
+// -force is given.
+func undoCommand(baseDir string, args []string) error {
+	fs := flag.NewFlagSet("undo", flag.ExitOnError)
+	force := fs.Bool("force", false, "Also restore files that were changed after the run")
+	list := fs.Bool("list", false, "List the recorded runs")
+	rest := parseArgs(fs, args)
+	if len(rest) > 1 {
+		return fmt.Errorf("undo takes at most one run ID")
+	}
+
+	ids, err := journal.Runs(baseDir)
+	if err != nil {
+		return err
+	}
+	if *list {
+		return listRuns(baseDir, ids)
+	}
+
+	var run *journal.Journal
+	if len(rest) == 1 {
+		if run, err = journal.Load(baseDir, rest[0]); err != nil {
+			return fmt.Errorf("no run %s", rest[0])
+		}
+	} else {
+		// The last run that changed anything and hasn't been undone
+		for i := len(ids) - 1; i >= 0 && run == nil; i-- {
+			j, err := journal.Load(baseDir, ids[i])
+			if err != nil {
+				return err
+			}
+			if !j.Undone && len(j.Backups) > 0 {
+				run = j
+			}
+		}
+		if run == nil {
+			fmt.Println("No runs to undo")
+			return nil
+		}
+	}
+	if run.Undone {
+		return fmt.Errorf("run %s was already undone", run.ID)
+	}
+	if len(run.Backups) == 0 {
+		fmt.Printf("Run %s didn't change any files\n", run.ID)
+		return nil
+	}
+
+	fmt.Printf("Undoing run %s (%s, %d file(s))\n\n", run.ID, run.Script, len(run.Backups))
+	restored, skipped := 0, 0
+	for _, b := range run.Backups {
+		changed, err := run.Changed(b)
+		if err != nil {
+			return err
+		}
+		// A file that's gone and was new anyway needs nothing
+		if changed && !*force && (b.Existed || fileExists(filepath.Join(baseDir, b.Path))) {
+			fmt.Printf("⚠ %s was changed after the run, skipped (-force to restore it anyway)\n", b.Path)
+			skipped++
+			continue
+		}
+		if err := run.Restore(b); err != nil {
+			return fmt.Errorf("%s: %w", b.Path, err)
+		}
+		if b.Existed {
+			fmt.Printf("✓ Restored %s\n", b.Path)
+		} else {
+			fmt.Printf("🗑️  Removed %s\n", b.Path)
+		}
+		restored++
+	}
+
+	fmt.Printf("\n%s\n", strings.Repeat("=", 50))
+	fmt.Printf("Summary:\n")
+	fmt.Printf("  Restored: %d\n", restored)
+	fmt.Printf("  Skipped: %d\n", skipped)
+	if skipped > 0 {
+		// Leave the run undoable so -force can finish the job
+		return nil
+	}
+	return run.MarkUndone()
+}
+
+// listRuns prints the recorded runs, newest first
+func listRuns(baseDir string, ids []string) error {
+	if len(ids) == 0 {
+		fmt.Println("No recorded runs")
+		return nil
+	}
+	for i := len(ids) - 1; i >= 0; i-- {
+		run, err := journal.Load(baseDir, ids[i])
+		if err != nil {
+			fmt.Printf("%s  ⚠ %v\n", ids[i], err)
+			continue
+		}
+		state := "finished"
+		switch {
+		case run.Undone:
+			state = "undone"
+		case !run.Finished:
+			state = fmt.Sprintf("%d pending", len(run.Pending()))
+		}
+		fmt.Printf("%s  %-7s %3d title(s) %3d file(s) changed  %s\n", run.ID, run.Script, len(run.Entries), len(run.Backups), state)
+	}
+	return nil
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// lockedImg reports whether any of the pages sharing an image locks img
+func lockedImg(pages []*consumed.Page) bool {
+	for _, page := range pages {
+		if page.Locked("img") {
+			return true
+		}
+	}
+	return false
+}
+
+// artworkName returns the new URL of an image named from the title alone,
+// keyed by the year or provider ID of the pages using it, or "" if it
+// already has a key or isn't a poster or cover. Pages of different works
+// sharing one image (the collision this fixes) are an error.
+func artworkName(img string, pages []*consumed.Page) (string, error) {
+	ext := path.Ext(img)
+	m := legacyArtworkPattern.FindStringSubmatch(strings.TrimSuffix(path.Base(img), ext))
+	if m == nil {
+		return "", nil
+	}
+	slug, suffix := m[1], m[2]
+
+	key := ""
+	for _, page := range pages {
+		year, _ := page.Raw("year")
+		id := ""
+		for _, field := range []string{"tmdb", "discogs", "openlibrary"} {
+			if id = artwork.ProviderID(page.String(field)); id != "" {
+				break
+			}
+		}
+		pageKey := artwork.Key(year, id)
+		if pageKey == "" {
+			continue
+		}
+		if key != "" && pageKey != key {
+			return "", fmt.Errorf("used by different works (%s and %s); download one of them again", key, pageKey)
+		}
+		key = pageKey
+	}
+	if key == "" {
+		return "", fmt.Errorf("no year or provider ID on %s/%s", pages[0].Lang, pages[0].Slug())
+	}
+	if strings.HasSuffix(slug, "_"+key) {
+		return "", nil
+	}
+	return strings.TrimSuffix(img, path.Base(img)) + artwork.Filename(slug, key, suffix, ext), nil
+}
+
+// generatedFiles lists the dithered copies, responsive variants and dither
+// sidecars of an image
+func generatedFiles(file string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Dir(file))
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, entry := range entries {
+		f := filepath.Join(filepath.Dir(file), entry.Name())
+		if f != file && imageset.Source(f) == file {
+			files = append(files, f)
+		}
+	}
+	return files, nil
+}
+
+func sameFile(a, b string) bool {
+	ia, errA := os.Stat(a)
+	ib, errB := os.Stat(b)
+	return errA == nil && errB == nil && os.SameFile(ia, ib)
+}
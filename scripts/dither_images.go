@@ -0,0 +1,447 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/consumed"
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/dither"
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/imageset"
+)
+
+// Lo-fi image dithering. By default this is the original hard look: 4x4
+// ordered dithering with high contrast and posterization, for a chunky,
+// high-contrast black & white image. Writes <name>_dithered.<ext> next to
+// every image.
+//
+// Usage:
+//
+//	go run scripts/dither_images.go [directory] [--recursive] [--overwrite]
+//	    [--algorithm hard] [--palette 1-bit] [--contrast 5]
+//
+// A manifest (.cache/dither-manifest.json, not committed) records the source hash
+// and settings of every output, so only new or changed images (or changed
+// settings) are redone, and outputs whose original was deleted are pruned.
+//
+// Single images can override the algorithm, palette and contrast from the
+// frontmatter of the page that uses them (dither, dither_palette,
+// dither_contrast) or from a <image>.dither.toml sidecar file, which wins.
+
+func main() {
+	var recursive, overwrite bool
+	var algorithm, palette string
+	var contrast float64
+	var jobs int
+	var noPrune bool
+	flag.BoolVar(&recursive, "recursive", false, "Include subdirectories")
+	flag.BoolVar(&recursive, "r", false, "Shorthand for -recursive")
+	flag.BoolVar(&overwrite, "overwrite", false, "Re-create dithered images that already exist")
+	flag.BoolVar(&overwrite, "o", false, "Shorthand for -overwrite")
+	flag.StringVar(&algorithm, "algorithm", string(dither.DefaultOptions.Algorithm), "Dithering algorithm: hard, floyd-steinberg, atkinson, bayer2, bayer4, bayer8, blue-noise")
+	flag.StringVar(&palette, "palette", dither.DefaultOptions.Palette.Name, "Palette: 1-bit, duotone (theme colors) or grayN (e.g. gray4)")
+	flag.Float64Var(&contrast, "contrast", dither.DefaultOptions.Contrast, "Percent clipped at each end before dithering (ignored by hard)")
+	flag.IntVar(&jobs, "jobs", runtime.NumCPU(), "Number of images to process at once")
+	flag.BoolVar(&noPrune, "no-prune", false, "Keep dithered copies whose original is gone")
+	args := parseArgs(flag.CommandLine, os.Args[1:])
+
+	defaults, err := (dither.Override{
+		Algorithm: algorithm,
+		Palette:   palette,
+		Contrast:  fmt.Sprint(contrast),
+		Source:    "flags",
+	}).Apply(dither.DefaultOptions)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		os.Exit(1)
+	}
+
+	baseDir := getBaseDir()
+	overrides, err := frontmatterOverrides(baseDir)
+	if err != nil {
+		fmt.Printf("❌ Reading frontmatter: %v\n", err)
+		os.Exit(1)
+	}
+	manifest, err := dither.LoadManifest(filepath.Join(baseDir, dither.ManifestFile))
+	if err != nil {
+		fmt.Printf("❌ Reading %s: %v\n", dither.ManifestFile, err)
+		os.Exit(1)
+	}
+
+	directory := "."
+	if len(args) > 0 {
+		directory = args[0]
+	}
+	if info, err := os.Stat(directory); err != nil || !info.IsDir() {
+		fmt.Printf("❌ Directory not found: %s\n", directory)
+		os.Exit(1)
+	}
+
+	fmt.Printf("\n🔍 Searching for images in: %s\n", directory)
+	if recursive {
+		fmt.Println("   (including subdirectories)")
+	}
+	fmt.Println()
+
+	files, err := findImages(directory, recursive)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		os.Exit(1)
+	}
+
+	// Process in parallel, but report in file order
+	if jobs < 1 {
+		jobs = 1
+	}
+	work := make(chan int)
+	results := make(chan ditherResult)
+	var wg sync.WaitGroup
+	for w := 0; w < jobs; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				results <- ditherImage(i, files[i], baseDir, defaults, overrides, manifest, overwrite)
+			}
+		}()
+	}
+	go func() {
+		for i := range files {
+			work <- i
+		}
+		close(work)
+		wg.Wait()
+		close(results)
+	}()
+
+	processed, upToDate, failed := 0, 0, 0
+	pending := make(map[int]ditherResult)
+	next := 0
+	for result := range results {
+		pending[result.index] = result
+		for {
+			r, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+
+			name := filepath.Base(r.file)
+			switch r.status {
+			case statusUpToDate:
+				fmt.Printf("⏭️  Skipping %s (up to date)\n", name)
+				upToDate++
+			case statusAdopted:
+				fmt.Printf("⏭️  Skipping %s (dithered version exists, now tracked)\n", name)
+				upToDate++
+			case statusFailed:
+				fmt.Printf("✗ %s: %v\n", name, r.err)
+				failed++
+			case statusProcessed:
+				fmt.Printf("🎨 Processed %s", name)
+				if r.opts != defaults {
+					fmt.Printf(" (%s, %s)", r.opts.Algorithm, r.opts.Palette.Name)
+				}
+				fmt.Printf("\n✅ Created %s\n", filepath.Base(dither.DitheredPath(r.file)))
+				processed++
+			}
+		}
+	}
+
+	pruned := 0
+	if !noPrune {
+		pruned = prune(manifest, baseDir, directory, recursive)
+	}
+
+	if err := manifest.Save(); err != nil {
+		fmt.Printf("✗ Could not save %s: %v\n", dither.ManifestFile, err)
+		failed++
+	}
+
+	fmt.Println()
+	if len(files) == 0 && pruned == 0 {
+		fmt.Println("❌ No images found")
+		return
+	}
+	fmt.Printf("✨ Done! Processed %d of %d image(s)", processed, len(files))
+	if upToDate > 0 {
+		fmt.Printf(", %d up to date", upToDate)
+	}
+	if pruned > 0 {
+		fmt.Printf(", pruned %d", pruned)
+	}
+	fmt.Println()
+	if defaults.Algorithm == dither.AlgorithmHard {
+		fmt.Printf("   Dithering: HARD lo-fi 4x4 ordered (high contrast, posterized), %s\n", defaults.Palette.Name)
+	} else {
+		fmt.Printf("   Dithering: %s, %s\n", defaults.Algorithm, defaults.Palette.Name)
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+type ditherStatus int
+
+const (
+	statusProcessed ditherStatus = iota
+	statusUpToDate
+	statusAdopted
+	statusFailed
+)
+
+type ditherResult struct {
+	index  int
+	file   string
+	status ditherStatus
+	opts   dither.Options
+	err    error
+}
+
+// ditherImage brings the dithered copy of one image up to date. Outputs made
+// before the manifest existed are kept and recorded as long as they use the
+// default settings; --overwrite redoes everything.
+func ditherImage(index int, file, baseDir string, defaults dither.Options, overrides map[string]dither.Override, manifest *dither.Manifest, overwrite bool) ditherResult {
+	result := ditherResult{index: index, file: file}
+	fail := func(err error) ditherResult {
+		result.status, result.err = statusFailed, err
+		return result
+	}
+
+	output := dither.DitheredPath(file)
+	_, statErr := os.Stat(output)
+	outputExists := statErr == nil
+	opts, err := imageOptions(file, defaults, overrides)
+	if err != nil {
+		return fail(err)
+	}
+	result.opts = opts
+
+	source, err := siteRelative(baseDir, file)
+	if err != nil {
+		return fail(err)
+	}
+	relOutput, err := siteRelative(baseDir, output)
+	if err != nil {
+		return fail(err)
+	}
+	hash, err := dither.HashFile(file)
+	if err != nil {
+		return fail(err)
+	}
+	entry := dither.NewEntry(source, hash, opts)
+
+	if !overwrite {
+		if manifest.UpToDate(relOutput, output, entry) {
+			result.status = statusUpToDate
+			return result
+		}
+		if _, tracked := manifest.Lookup(relOutput); !tracked && outputExists && opts == dither.DefaultOptions {
+			manifest.Set(relOutput, entry)
+			result.status = statusAdopted
+			return result
+		}
+	}
+
+	if _, err := dither.File(file, opts); err != nil {
+		return fail(err)
+	}
+	manifest.Set(relOutput, entry)
+	result.status = statusProcessed
+	return result
+}
+
+// siteRelative turns a path into the slash-separated form the manifest uses
+func siteRelative(baseDir, path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(baseDir, abs)
+	if err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// prune deletes the recorded outputs under directory whose source is gone
+func prune(manifest *dither.Manifest, baseDir, directory string, recursive bool) int {
+	dir, err := siteRelative(baseDir, directory)
+	if err != nil {
+		return 0
+	}
+	pruned := 0
+	for _, output := range manifest.Paths() {
+		parent := path.Dir(output)
+		if recursive {
+			if dir != "." && parent != dir && !strings.HasPrefix(parent, dir+"/") {
+				continue
+			}
+		} else if parent != dir {
+			continue
+		}
+
+		entry, _ := manifest.Lookup(output)
+		if _, err := os.Stat(filepath.Join(baseDir, filepath.FromSlash(entry.Source))); err == nil {
+			continue
+		}
+		if err := os.Remove(filepath.Join(baseDir, filepath.FromSlash(output))); err != nil && !os.IsNotExist(err) {
+			fmt.Printf("✗ Could not prune %s: %v\n", output, err)
+			continue
+		}
+		manifest.Delete(output)
+		fmt.Printf("🗑️  Pruned %s (%s is gone)\n", output, entry.Source)
+		pruned++
+	}
+	return pruned
+}
+
+// parseArgs parses flags that may come before or after the positional
+// arguments, e.g. "assets --recursive"
+func parseArgs(fs *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		fs.Parse(args)
+		if fs.NArg() == 0 {
+			return positional
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// findImages lists the images in a directory that aren't dithered copies,
+// in lexical order
+func findImages(directory string, recursive bool) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(directory, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != directory && !recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if dither.IsImage(path) && !dither.IsDithered(path) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files, err
+}
+
+func getBaseDir() string {
+	// Start from current working directory and walk up to find project root
+	wd, _ := os.Getwd()
+	startWd := wd
+	for {
+		if _, err := os.Stat(filepath.Join(wd, "content")); err == nil {
+			return wd
+		}
+		parent := filepath.Dir(wd)
+		if parent == wd {
+			break
+		}
+		wd = parent
+	}
+	return startWd
+}
+
+// imageOptions resolves the settings for one image: flags, then the
+// frontmatter of the page using it, then its sidecar file. Responsive
+// variants take the settings of their original.
+func imageOptions(file string, defaults dither.Options, overrides map[string]dither.Override) (dither.Options, error) {
+	if original, _, ok := imageset.Original(file); ok {
+		if _, err := os.Stat(original); err == nil {
+			file = original
+		}
+	}
+	opts := defaults
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return opts, err
+	}
+	if o, ok := overrides[abs]; ok {
+		if opts, err = o.Apply(opts); err != nil {
+			return opts, err
+		}
+	}
+	sidecar, ok, err := dither.ReadSidecar(file)
+	if err != nil || !ok {
+		return opts, err
+	}
+	return sidecar.Apply(opts)
+}
+
+// imageRefPattern finds images used in a page body: img shortcode src
+// attributes and markdown images
+var imageRefPattern = regexp.MustCompile(`(?:src="([^"]+)"|!\[[^\]]*\]\(([^)\s]+))`)
+
+// frontmatterOverrides maps the absolute path of every image used by a page
+// with dither settings in its frontmatter to those settings. Images are the
+// page's img field and the images in its body; paths starting with / are
+// looked up in static/ and assets/, others next to the page.
+func frontmatterOverrides(baseDir string) (map[string]dither.Override, error) {
+	overrides := make(map[string]dither.Override)
+	contentDir := filepath.Join(baseDir, "content")
+	err := filepath.WalkDir(contentDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(path, ".md") {
+			return err
+		}
+		page, err := consumed.Load(path)
+		if err != nil {
+			// Pages without TOML frontmatter can't carry overrides
+			return nil
+		}
+		o := dither.Override{
+			Algorithm: page.String("dither"),
+			Palette:   page.String("dither_palette"),
+			Contrast:  page.String("dither_contrast"),
+			Source:    path,
+		}
+		if raw, ok := page.Raw("dither_contrast"); ok && o.Contrast == "" {
+			o.Contrast = raw
+		}
+		if o.Algorithm == "" && o.Palette == "" && o.Contrast == "" {
+			return nil
+		}
+
+		refs := []string{page.String("img")}
+		for _, m := range imageRefPattern.FindAllStringSubmatch(page.Body, -1) {
+			refs = append(refs, m[1]+m[2])
+		}
+		for _, ref := range refs {
+			if ref == "" || strings.Contains(ref, "://") {
+				continue
+			}
+			var candidates []string
+			if strings.HasPrefix(ref, "/") {
+				candidates = []string{filepath.Join(baseDir, "static", ref), filepath.Join(baseDir, "assets", ref)}
+			} else {
+				candidates = []string{filepath.Join(filepath.Dir(path), ref)}
+			}
+			for _, candidate := range candidates {
+				abs, err := filepath.Abs(candidate)
+				if err != nil {
+					return err
+				}
+				overrides[abs] = o
+			}
+		}
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return overrides, nil
+	}
+	return overrides, err
+}
@@ -1,30 +1,53 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/artwork"
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/changes"
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/fakeprovider"
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/httpcache"
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/consumed"
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/imageset"
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/journal"
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/workpool"
 	"github.com/joho/godotenv"
 )
 
-const openLibraryAPIBase = "https://openlibrary.org"
-const googleBooksAPIBase = "https://www.googleapis.com/books/v1"
+// API bases, which OPENLIBRARY_API_BASE and GOOGLE_BOOKS_API_BASE override
+// (see overrideBase)
+var (
+	openLibraryAPIBase = "https://openlibrary.org"
+	googleBooksAPIBase = "https://www.googleapis.com/books/v1"
+)
 
 type BookSearchResult struct {
-	Key      string   `json:"key"`
-	Title    string   `json:"title"`
-	Author   []string `json:"author_name"`
-	Year     string   `json:"first_publish_year"`
-	ISBN     []string `json:"isbn"`
-	CoverKey string   `json:"cover_i"`
+	Key      string    `json:"key"`
+	Title    string    `json:"title"`
+	Subtitle string    `json:"subtitle"`
+	Author   []string  `json:"author_name"`
+	Year     int       `json:"first_publish_year"`
+	ISBN     []string  `json:"isbn"`
+	CoverKey int       `json:"cover_i"`
+	Pages    int       `json:"number_of_pages_median"`
+	Language []string  `json:"language"`
+	Subject  []string  `json:"subject"`
+	Fetched  time.Time `json:"-"` // when Open Library sent the result
 }
 
 type BookSearchResponse struct {
@@ -35,13 +58,25 @@ type BookSearchResponse struct {
 type GoogleBookItem struct {
 	ID         string              `json:"id"`
 	VolumeInfo GoogleVolumeInfo    `json:"volumeInfo"`
+	Fetched    time.Time           `json:"-"` // when Google sent the result
 }
 
 type GoogleVolumeInfo struct {
 	Title         string   `json:"title"`
+	Subtitle      string   `json:"subtitle"`
 	Authors       []string `json:"authors"`
 	PublishedDate string   `json:"publishedDate"`
 	Publisher     string   `json:"publisher"`
+	PageCount     int      `json:"pageCount"`
+	Language      string   `json:"language"`
+	Categories    []string `json:"categories"`
+	SeriesInfo    struct {
+		BookDisplayNumber string `json:"bookDisplayNumber"`
+		VolumeSeries      []struct {
+			SeriesID    string `json:"seriesId"`
+			OrderNumber int    `json:"orderNumber"`
+		} `json:"volumeSeries"`
+	} `json:"seriesInfo"`
 	IndustryIdentifiers []struct {
 		Type       string `json:"type"`
 		Identifier string `json:"identifier"`
@@ -58,6 +93,13 @@ type GoogleBooksResponse struct {
 	Items []GoogleBookItem `json:"items"`
 }
 
+type GoogleSeriesResponse struct {
+	Series []struct {
+		SeriesID string `json:"seriesId"`
+		Title    string `json:"title"`
+	} `json:"series"`
+}
+
 type BookDetails struct {
 	Title    string   `json:"title"`
 	Authors  []Author `json:"authors"`
@@ -65,6 +107,7 @@ type BookDetails struct {
 	ISBN10   []string `json:"isbn_10"`
 	ISBN13   []string `json:"isbn_13"`
 	Publishers []string `json:"publishers"`
+	Subjects []string `json:"subjects"`
 }
 
 type Author struct {
@@ -77,34 +120,115 @@ type AuthorDetails struct {
 
 type BookData struct {
 	Title    string
-	Author   string
+	Subtitle string
+	Author   string   // all authors joined, for the templates
+	Authors  []string
+	Series   string
+	SeriesNumber string
 	Year     string
 	Publisher string
+	PageCount int
+	Language string   // ISO 639-1 code, e.g. "en"
+	Subjects []string
 	OpenLibraryURL string
 	CoverURL string
 	CoverPath string
+	Artwork imageset.Artwork // placeholder and colors of the cover
+	Source consumed.Source   // where the data came from, for the provenance table
+	Refreshed bool           // looked up again by -refresh-stale
+}
+
+// maxSubjects caps the subject list; Open Library returns dozens of
+// loosely related ones
+const maxSubjects = 5
+
+// openLibraryLanguages maps the MARC codes Open Library uses to the ISO 639-1
+// codes Google Books uses
+var openLibraryLanguages = map[string]string{
+	"eng": "en",
+	"spa": "es",
+	"fre": "fr",
+	"ger": "de",
+	"ita": "it",
+	"por": "pt",
+	"slv": "sl",
+	"hrv": "hr",
+	"jpn": "ja",
+	"rus": "ru",
+}
+
+// newBookData fills in the fields shared by both providers: the joined author
+// string, the title/subtitle and series split, and trimmed subjects
+func newBookData(title, subtitle string, authors []string, subjects []string) *BookData {
+	title, series, number := consumed.SplitSeries(title)
+	if subtitle == "" {
+		title, subtitle = consumed.SplitTitle(title)
+	}
+
+	var cleaned []string
+	seen := make(map[string]bool)
+	for _, subject := range subjects {
+		subject = strings.TrimSpace(subject)
+		key := strings.ToLower(subject)
+		if subject == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		cleaned = append(cleaned, subject)
+		if len(cleaned) == maxSubjects {
+			break
+		}
+	}
+
+	return &BookData{
+		Title:        title,
+		Subtitle:     subtitle,
+		Author:       strings.Join(authors, ", "),
+		Authors:      authors,
+		Series:       series,
+		SeriesNumber: number,
+		Subjects:     cleaned,
+	}
+}
+
+// overrideBase replaces an API base with the one in the environment
+// variable env, if it is set, e.g. to point the script at the provider fakes
+// (see scripts/internal/fakeprovider)
+func overrideBase(base *string, env string) {
+	if value := os.Getenv(env); value != "" {
+		*base = strings.TrimSuffix(value, "/")
+	}
 }
 
 func getBaseDir() string {
 	wd, _ := os.Getwd()
+	startWd := wd
 	for {
 		if _, err := os.Stat(filepath.Join(wd, "data", "books", "books.toml")); err == nil {
 			return wd
 		}
+		if _, err := os.Stat(filepath.Join(wd, "content")); err == nil {
+			return wd
+		}
 		parent := filepath.Dir(wd)
 		if parent == wd {
 			break
 		}
 		wd = parent
 	}
-	return wd
+	return startWd
 }
 
-func searchBookGoogle(title string) (*GoogleBookItem, error) {
-	client := &http.Client{Timeout: 30 * time.Second}
+// googleSearchURL is the Google Books search for a title
+func googleSearchURL(title string) string {
+	return fmt.Sprintf("%s/volumes?q=%s&maxResults=5", googleBooksAPIBase, strings.ReplaceAll(title, " ", "+"))
+}
+
+func searchBookGoogle(ctx context.Context, title string, out io.Writer) (*GoogleBookItem, error) {
+	client := &http.Client{Timeout: 30 * time.Second, Transport: apiCache}
 	
 	// Search for the book using Google Books API
-	searchURL := fmt.Sprintf("%s/volumes?q=%s&maxResults=5", googleBooksAPIBase, strings.ReplaceAll(title, " ", "+"))
+	searchURL := googleSearchURL(title)
 	
 	// Retry up to 3 times for 503 errors
 	maxRetries := 3
@@ -113,11 +237,19 @@ func searchBookGoogle(title string) (*GoogleBookItem, error) {
 		if attempt > 0 {
 			// Wait before retry (exponential backoff)
 			waitTime := time.Duration(attempt) * 2 * time.Second
-			fmt.Printf("    Retrying in %v...\n", waitTime)
-			time.Sleep(waitTime)
+			fmt.Fprintf(out, "    Retrying in %v...\n", waitTime)
+			select {
+			case <-time.After(waitTime):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
 		}
 		
-		resp, err := client.Get(searchURL)
+		req, _ := http.NewRequestWithContext(ctx, "GET", searchURL, nil)
+		resp, err := client.Do(req)
+		if errors.Is(err, httpcache.ErrOffline) {
+			return nil, err
+		}
 		if err != nil {
 			lastErr = fmt.Errorf("failed to search Google Books: %w", err)
 			continue
@@ -146,19 +278,52 @@ func searchBookGoogle(title string) (*GoogleBookItem, error) {
 		}
 		
 		// Return the first result
-		return &searchResp.Items[0], nil
+		item := &searchResp.Items[0]
+		item.Fetched = httpcache.Fetched(resp)
+		return item, nil
 	}
 	
 	return nil, lastErr
 }
 
-func searchBook(title string) (*BookSearchResult, error) {
-	client := &http.Client{Timeout: 10 * time.Second}
+// getGoogleSeriesTitle looks up the name of a series; volumes only carry its ID
+func getGoogleSeriesTitle(ctx context.Context, seriesID string) (string, error) {
+	client := &http.Client{Timeout: 10 * time.Second, Transport: apiCache}
+
+	seriesURL := fmt.Sprintf("%s/series/get?series_id=%s", googleBooksAPIBase, url.QueryEscape(seriesID))
+
+	req, _ := http.NewRequestWithContext(ctx, "GET", seriesURL, nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to get series: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("series request failed with status: %d", resp.StatusCode)
+	}
+
+	var seriesResp GoogleSeriesResponse
+	if err := json.NewDecoder(resp.Body).Decode(&seriesResp); err != nil {
+		return "", fmt.Errorf("failed to decode series: %w", err)
+	}
+
+	for _, series := range seriesResp.Series {
+		if series.SeriesID == seriesID {
+			return series.Title, nil
+		}
+	}
+	return "", fmt.Errorf("series %s not found", seriesID)
+}
+
+func searchBook(ctx context.Context, title string) (*BookSearchResult, error) {
+	client := &http.Client{Timeout: 10 * time.Second, Transport: apiCache}
 	
 	// Search for the book
 	searchURL := fmt.Sprintf("%s/search.json?title=%s&limit=5", openLibraryAPIBase, strings.ReplaceAll(title, " ", "+"))
 	
-	resp, err := client.Get(searchURL)
+	req, _ := http.NewRequestWithContext(ctx, "GET", searchURL, nil)
+	resp, err := client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to search: %w", err)
 	}
@@ -178,16 +343,19 @@ func searchBook(title string) (*BookSearchResult, error) {
 	}
 	
 	// Return the first result
-	return &searchResp.Docs[0], nil
+	result := &searchResp.Docs[0]
+	result.Fetched = httpcache.Fetched(resp)
+	return result, nil
 }
 
-func getBookDetails(workKey string) (*BookDetails, error) {
-	client := &http.Client{Timeout: 10 * time.Second}
+func getBookDetails(ctx context.Context, workKey string) (*BookDetails, error) {
+	client := &http.Client{Timeout: 10 * time.Second, Transport: apiCache}
 	
 	// Get work details
 	detailsURL := fmt.Sprintf("%s%s.json", openLibraryAPIBase, workKey)
 	
-	resp, err := client.Get(detailsURL)
+	req, _ := http.NewRequestWithContext(ctx, "GET", detailsURL, nil)
+	resp, err := client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get details: %w", err)
 	}
@@ -205,12 +373,13 @@ func getBookDetails(workKey string) (*BookDetails, error) {
 	return &details, nil
 }
 
-func getAuthorName(authorKey string) (string, error) {
-	client := &http.Client{Timeout: 10 * time.Second}
+func getAuthorName(ctx context.Context, authorKey string) (string, error) {
+	client := &http.Client{Timeout: 10 * time.Second, Transport: apiCache}
 	
 	authorURL := fmt.Sprintf("%s%s.json", openLibraryAPIBase, authorKey)
 	
-	resp, err := client.Get(authorURL)
+	req, _ := http.NewRequestWithContext(ctx, "GET", authorURL, nil)
+	resp, err := client.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("failed to get author: %w", err)
 	}
@@ -228,22 +397,26 @@ func getAuthorName(authorKey string) (string, error) {
 	return author.Name, nil
 }
 
-func processBookGoogle(title string) (*BookData, error) {
-	fmt.Printf("Searching Google Books for: %s\n", title)
+// googleCoverURL cleans up a Google Books image link: https instead of the
+// http Google sends, and no &edge=curl page curl. Links elsewhere, such as
+// to the provider fakes, keep their scheme.
+func googleCoverURL(link string) string {
+	if strings.HasPrefix(link, "http://books.google.") {
+		link = "https://" + strings.TrimPrefix(link, "http://")
+	}
+	return strings.ReplaceAll(link, "&edge=curl", "")
+}
+
+func processBookGoogle(ctx context.Context, title string, out io.Writer) (*BookData, error) {
+	fmt.Fprintf(out, "Searching Google Books for: %s\n", title)
 	
-	googleBook, err := searchBookGoogle(title)
+	googleBook, err := searchBookGoogle(ctx, title, out)
 	if err != nil {
 		return nil, fmt.Errorf("Google Books search failed: %w", err)
 	}
 	
 	volumeInfo := googleBook.VolumeInfo
-	fmt.Printf("  Found: %s\n", volumeInfo.Title)
-	
-	// Get author name
-	authorName := ""
-	if len(volumeInfo.Authors) > 0 {
-		authorName = volumeInfo.Authors[0]
-	}
+	fmt.Fprintf(out, "  Found: %s\n", volumeInfo.Title)
 	
 	// Extract year from publishedDate
 	year := ""
@@ -282,68 +455,87 @@ func processBookGoogle(title string) (*BookData, error) {
 	// Get cover image URL (prefer thumbnail, fallback to small)
 	coverURL := ""
 	if volumeInfo.ImageLinks.Thumbnail != "" {
-		coverURL = volumeInfo.ImageLinks.Thumbnail
-		// Replace http:// with https:// and remove &edge=curl parameter if present
-		coverURL = strings.ReplaceAll(coverURL, "http://", "https://")
-		coverURL = strings.ReplaceAll(coverURL, "&edge=curl", "")
+		coverURL = googleCoverURL(volumeInfo.ImageLinks.Thumbnail)
 	} else if volumeInfo.ImageLinks.Small != "" {
-		coverURL = volumeInfo.ImageLinks.Small
-		coverURL = strings.ReplaceAll(coverURL, "http://", "https://")
-		coverURL = strings.ReplaceAll(coverURL, "&edge=curl", "")
+		coverURL = googleCoverURL(volumeInfo.ImageLinks.Small)
 	}
 	
-	return &BookData{
-		Title:         volumeInfo.Title,
-		Author:        authorName,
-		Year:          year,
-		Publisher:     publisher,
-		OpenLibraryURL: openLibraryURL,
-		CoverURL:      coverURL,
-	}, nil
+	// Categories look like "Biography & Autobiography / Personal Memoirs"
+	var subjects []string
+	for _, category := range volumeInfo.Categories {
+		subjects = append(subjects, strings.Split(category, " / ")...)
+	}
+
+	data := newBookData(volumeInfo.Title, volumeInfo.Subtitle, volumeInfo.Authors, subjects)
+	data.Year = year
+	data.Publisher = publisher
+	data.PageCount = volumeInfo.PageCount
+	data.Language = volumeInfo.Language
+	data.OpenLibraryURL = openLibraryURL
+	data.CoverURL = coverURL
+	data.Source = consumed.Source{Provider: "google-books", ID: googleBook.ID, Fetched: googleBook.Fetched}
+
+	// Google only reports the series ID and the book's number in it
+	if volumes := volumeInfo.SeriesInfo.VolumeSeries; len(volumes) > 0 {
+		seriesTitle, err := getGoogleSeriesTitle(ctx, volumes[0].SeriesID)
+		if err != nil {
+			fmt.Fprintf(out, "  Warning: Could not get series: %v\n", err)
+		} else {
+			data.Series = seriesTitle
+			data.SeriesNumber = volumeInfo.SeriesInfo.BookDisplayNumber
+			if data.SeriesNumber == "" && volumes[0].OrderNumber > 0 {
+				data.SeriesNumber = strconv.Itoa(volumes[0].OrderNumber)
+			}
+		}
+	}
+
+	return data, nil
 }
 
-func processBook(title string) (*BookData, error) {
+func processBook(ctx context.Context, title string, out io.Writer) (*BookData, error) {
 	// Try Google Books first (more reliable)
-	fmt.Printf("Searching for: %s\n", title)
+	fmt.Fprintf(out, "Searching for: %s\n", title)
 	
-	googleData, err := processBookGoogle(title)
+	googleData, err := processBookGoogle(ctx, title, out)
 	if err == nil {
 		return googleData, nil
 	}
 	
-	fmt.Printf("  Google Books failed: %v, trying Open Library...\n", err)
+	fmt.Fprintf(out, "  Google Books failed: %v, trying Open Library...\n", err)
 	
 	// Fallback to Open Library
-	searchResult, err := searchBook(title)
+	searchResult, err := searchBook(ctx, title)
 	if err != nil {
 		return nil, fmt.Errorf("both Google Books and Open Library searches failed. Last error: %w", err)
 	}
 	
-	fmt.Printf("  Found on Open Library: %s\n", searchResult.Title)
+	fmt.Fprintf(out, "  Found on Open Library: %s\n", searchResult.Title)
 	
 	// Get work details for more info
-	details, err := getBookDetails(searchResult.Key)
+	details, err := getBookDetails(ctx, searchResult.Key)
 	if err != nil {
-		fmt.Printf("  Warning: Could not get details: %v\n", err)
+		fmt.Fprintf(out, "  Warning: Could not get details: %v\n", err)
 		details = &BookDetails{}
 	}
 	
-	// Get author name
-	authorName := ""
-	if len(searchResult.Author) > 0 {
-		authorName = searchResult.Author[0]
-	} else if len(details.Authors) > 0 {
-		// Try to get author name from details
-		authorName, err = getAuthorName(details.Authors[0].Key)
-		if err != nil {
-			fmt.Printf("  Warning: Could not get author name: %v\n", err)
+	// Get author names
+	authors := searchResult.Author
+	if len(authors) == 0 {
+		// Try to get author names from details
+		for _, author := range details.Authors {
+			name, err := getAuthorName(ctx, author.Key)
+			if err != nil {
+				fmt.Fprintf(out, "  Warning: Could not get author name: %v\n", err)
+				continue
+			}
+			authors = append(authors, name)
 		}
 	}
 	
 	// Extract year
 	year := ""
-	if searchResult.Year != "" {
-		year = searchResult.Year
+	if searchResult.Year > 0 {
+		year = strconv.Itoa(searchResult.Year)
 	} else if len(details.Publish) > 0 {
 		// Try to extract year from publish date
 		yearMatch := regexp.MustCompile(`\d{4}`).FindString(details.Publish[0])
@@ -361,13 +553,31 @@ func processBook(title string) (*BookData, error) {
 	// Build Open Library URL
 	openLibraryURL := fmt.Sprintf("https://openlibrary.org%s", searchResult.Key)
 	
-	return &BookData{
-		Title:         searchResult.Title,
-		Author:        authorName,
-		Year:          year,
-		Publisher:     publisher,
-		OpenLibraryURL: openLibraryURL,
-	}, nil
+	subjects := searchResult.Subject
+	if len(subjects) == 0 {
+		subjects = details.Subjects
+	}
+
+	data := newBookData(searchResult.Title, searchResult.Subtitle, authors, subjects)
+	data.Year = year
+	data.Publisher = publisher
+	data.PageCount = searchResult.Pages
+	data.OpenLibraryURL = openLibraryURL
+	data.Source = consumed.Source{
+		Provider: "openlibrary",
+		ID:       strings.TrimPrefix(searchResult.Key, "/works/"),
+		Fetched:  searchResult.Fetched,
+	}
+
+	// Only trust the language when the work has a single one
+	if len(searchResult.Language) == 1 {
+		data.Language = openLibraryLanguages[searchResult.Language[0]]
+		if data.Language == "" {
+			data.Language = searchResult.Language[0]
+		}
+	}
+
+	return data, nil
 }
 
 func parseConsumedToml(filepath string) ([]map[string]string, error) {
@@ -399,36 +609,7 @@ func parseConsumedToml(filepath string) ([]map[string]string, error) {
 			continue
 		}
 		
-		book := make(map[string]string)
-		
-		// Extract title
-		titleMatch := regexp.MustCompile(`title\s*=\s*"([^"]+)"`).FindStringSubmatch(block)
-		if len(titleMatch) > 1 {
-			book["title"] = titleMatch[1]
-		}
-		
-		// Extract existing fields
-		authorMatch := regexp.MustCompile(`author\s*=\s*"([^"]*)"`).FindStringSubmatch(block)
-		if len(authorMatch) > 1 {
-			book["author"] = authorMatch[1]
-		}
-		
-		yearMatch := regexp.MustCompile(`year\s*=\s*"([^"]*)"`).FindStringSubmatch(block)
-		if len(yearMatch) > 1 {
-			book["year"] = yearMatch[1]
-		}
-		
-		publisherMatch := regexp.MustCompile(`publisher\s*=\s*"([^"]*)"`).FindStringSubmatch(block)
-		if len(publisherMatch) > 1 {
-			book["publisher"] = publisherMatch[1]
-		}
-		
-		// Extract processed flag
-		processedMatch := regexp.MustCompile(`processed\s*=\s*(true|yes)`).FindStringSubmatch(block)
-		if len(processedMatch) > 1 {
-			book["processed"] = "true"
-		}
-		
+		book := bookEntry(&consumed.Page{Frontmatter: block})
 		if book["title"] != "" {
 			books = append(books, book)
 		}
@@ -437,178 +618,222 @@ func parseConsumedToml(filepath string) ([]map[string]string, error) {
 	return books, nil
 }
 
-func updateConsumedToml(bookUpdates map[string]*BookData) error {
-	baseDir := getBaseDir()
-	filepath := filepath.Join(baseDir, "data", "books", "books.toml")
-	
-	content, err := os.ReadFile(filepath)
+// parseBookPages reads the book pages of every language, with the fields
+// parseConsumedToml reads plus the page's path
+func parseBookPages(contentDir string, includeDrafts bool) ([]map[string]string, error) {
+	pages, err := consumed.LoadAll(contentDir, "book")
 	if err != nil {
-		return err
+		return nil, err
 	}
-	
-	updated := false
-	
-	for originalTitle, data := range bookUpdates {
-		// Find the collection block using index-based approach (no lookahead)
-		contentStr := string(content)
-		re := regexp.MustCompile(`\[\[collection\]\]\s*\n`)
-		indices := re.FindAllStringIndex(contentStr, -1)
-		
-		var currentBlock string
-		var blockStart, blockEnd int
-		found := false
-		
-		for i, idx := range indices {
-			start := idx[1] // Start after [[collection]]\n
-			var end int
-			if i+1 < len(indices) {
-				end = indices[i+1][0] // Next [[
-			} else {
-				end = len(contentStr) // End of file
-			}
-			
-			candidateBlock := contentStr[start:end]
-			// Check if this block contains the title we're looking for
-			titlePattern := regexp.MustCompile(fmt.Sprintf(`title\s*=\s*"%s"`, regexp.QuoteMeta(originalTitle)))
-			if titlePattern.MatchString(candidateBlock) {
-				currentBlock = candidateBlock
-				blockStart = start
-				blockEnd = end
-				found = true
-				break
-			}
-		}
-		
-		if !found {
+	var books []map[string]string
+	for _, page := range pages {
+		if page.Bool("draft") && !includeDrafts {
 			continue
 		}
-		
-		modifiedBlock := currentBlock
-		
-		// Update author
-		if data.Author != "" {
-			if regexp.MustCompile(`author\s*=\s*"[^"]*"`).MatchString(modifiedBlock) {
-				modifiedBlock = regexp.MustCompile(`(author\s*=\s*)"[^"]*"`).ReplaceAllString(modifiedBlock, fmt.Sprintf(`$1"%s"`, data.Author))
-			} else {
-				// Add after title, preserving newline
-				if regexp.MustCompile(`title\s*=\s*"[^"]+"`).MatchString(modifiedBlock) {
-					modifiedBlock = regexp.MustCompile(`(title\s*=\s*"[^"]+")`).ReplaceAllString(modifiedBlock, fmt.Sprintf("$1\nauthor = \"%s\"", data.Author))
-				} else {
-					modifiedBlock = strings.TrimRight(modifiedBlock, "\n") + fmt.Sprintf("\nauthor = \"%s\"", data.Author)
-				}
-			}
+		book := bookEntry(page)
+		book["path"] = page.Path
+		if book["title"] != "" {
+			books = append(books, book)
 		}
-		
-		// Update year
-		if data.Year != "" {
-			if regexp.MustCompile(`year\s*=\s*"[^"]*"`).MatchString(modifiedBlock) {
-				modifiedBlock = regexp.MustCompile(`(year\s*=\s*)"[^"]*"`).ReplaceAllString(modifiedBlock, fmt.Sprintf(`$1"%s"`, data.Year))
-			} else {
-				// Try to insert after author, then title
-				if regexp.MustCompile(`author\s*=\s*"[^"]+"`).MatchString(modifiedBlock) {
-					modifiedBlock = regexp.MustCompile(`(author\s*=\s*"[^"]+")`).ReplaceAllString(modifiedBlock, fmt.Sprintf("$1\nyear = \"%s\"", data.Year))
-				} else if regexp.MustCompile(`title\s*=\s*"[^"]+"`).MatchString(modifiedBlock) {
-					modifiedBlock = regexp.MustCompile(`(title\s*=\s*"[^"]+")`).ReplaceAllString(modifiedBlock, fmt.Sprintf("$1\nyear = \"%s\"", data.Year))
-				} else {
-					modifiedBlock = strings.TrimRight(modifiedBlock, "\n") + fmt.Sprintf("\nyear = \"%s\"", data.Year)
-				}
-			}
+	}
+	return books, nil
+}
+
+// bookEntry reads the fields of a book the fetcher looks at, from a
+// books.toml block or a page's frontmatter
+func bookEntry(entry *consumed.Page) map[string]string {
+	book := make(map[string]string)
+	for _, key := range []string{"title", "author", "year", "publisher"} {
+		if value := entry.String(key); value != "" {
+			book[key] = value
 		}
-		
-		// Update publisher
-		if data.Publisher != "" {
-			if regexp.MustCompile(`publisher\s*=\s*"[^"]*"`).MatchString(modifiedBlock) {
-				modifiedBlock = regexp.MustCompile(`(publisher\s*=\s*)"[^"]*"`).ReplaceAllString(modifiedBlock, fmt.Sprintf(`$1"%s"`, data.Publisher))
-			} else {
-				// Try to insert after year, author, or title (in that order)
-				if regexp.MustCompile(`year\s*=\s*"[^"]+"`).MatchString(modifiedBlock) {
-					modifiedBlock = regexp.MustCompile(`(year\s*=\s*"[^"]+")`).ReplaceAllString(modifiedBlock, fmt.Sprintf("$1\npublisher = \"%s\"", data.Publisher))
-				} else if regexp.MustCompile(`author\s*=\s*"[^"]+"`).MatchString(modifiedBlock) {
-					modifiedBlock = regexp.MustCompile(`(author\s*=\s*"[^"]+")`).ReplaceAllString(modifiedBlock, fmt.Sprintf("$1\npublisher = \"%s\"", data.Publisher))
-				} else if regexp.MustCompile(`title\s*=\s*"[^"]+"`).MatchString(modifiedBlock) {
-					modifiedBlock = regexp.MustCompile(`(title\s*=\s*"[^"]+")`).ReplaceAllString(modifiedBlock, fmt.Sprintf("$1\npublisher = \"%s\"", data.Publisher))
-				} else {
-					modifiedBlock = strings.TrimRight(modifiedBlock, "\n") + fmt.Sprintf("\npublisher = \"%s\"", data.Publisher)
-				}
-			}
+	}
+	if entry.Bool("processed") {
+		book["processed"] = "true"
+	}
+
+	// Fields the download must leave alone, comma-separated
+	if locked := entry.Strings("locked"); len(locked) > 0 {
+		book["locked"] = strings.Join(locked, ",")
+	}
+
+	// Where and when the data was fetched, for -refresh-stale
+	if src, ok := entry.OldestSource(); ok {
+		book["source"] = src.Provider
+		book["source_id"] = src.ID
+		book["fetched"] = src.Fetched.Format(time.RFC3339)
+	}
+	return book
+}
+
+// bookKey is a book's key in the run journal: its page relative to the
+// site root, or its title in books.toml
+func bookKey(baseDir string, book map[string]string) string {
+	return journal.Key(baseDir, book["path"], book["title"])
+}
+
+// booksToml returns the content of books.toml with the collection blocks of
+// bookUpdates updated, without writing anything
+func booksToml(contentStr string, bookUpdates map[string]*BookData) string {
+	for originalTitle, data := range bookUpdates {
+		blockStart, blockEnd, found := findCollection(contentStr, originalTitle)
+		if !found {
+			continue
 		}
-		
-		// Update or add Open Library URL
-		if data.OpenLibraryURL != "" {
-			if regexp.MustCompile(`openlibrary\s*=\s*"[^"]*"`).MatchString(modifiedBlock) {
-				modifiedBlock = regexp.MustCompile(`(openlibrary\s*=\s*)"[^"]*"`).ReplaceAllString(modifiedBlock, fmt.Sprintf(`$1"%s"`, data.OpenLibraryURL))
-			} else {
-				// Try to insert after publisher, year, author, or title (in that order)
-				if regexp.MustCompile(`publisher\s*=\s*"[^"]+"`).MatchString(modifiedBlock) {
-					modifiedBlock = regexp.MustCompile(`(publisher\s*=\s*"[^"]+")`).ReplaceAllString(modifiedBlock, fmt.Sprintf("$1\nopenlibrary = \"%s\"", data.OpenLibraryURL))
-				} else if regexp.MustCompile(`year\s*=\s*"[^"]+"`).MatchString(modifiedBlock) {
-					modifiedBlock = regexp.MustCompile(`(year\s*=\s*"[^"]+")`).ReplaceAllString(modifiedBlock, fmt.Sprintf("$1\nopenlibrary = \"%s\"", data.OpenLibraryURL))
-				} else if regexp.MustCompile(`author\s*=\s*"[^"]+"`).MatchString(modifiedBlock) {
-					modifiedBlock = regexp.MustCompile(`(author\s*=\s*"[^"]+")`).ReplaceAllString(modifiedBlock, fmt.Sprintf("$1\nopenlibrary = \"%s\"", data.OpenLibraryURL))
-				} else if regexp.MustCompile(`title\s*=\s*"[^"]+"`).MatchString(modifiedBlock) {
-					modifiedBlock = regexp.MustCompile(`(title\s*=\s*"[^"]+")`).ReplaceAllString(modifiedBlock, fmt.Sprintf("$1\nopenlibrary = \"%s\"", data.OpenLibraryURL))
-				} else {
-					modifiedBlock = strings.TrimRight(modifiedBlock, "\n") + fmt.Sprintf("\nopenlibrary = \"%s\"", data.OpenLibraryURL)
-				}
-			}
+		block := bookFields(contentStr[blockStart:blockEnd], originalTitle, *data)
+		contentStr = contentStr[:blockStart] + block + contentStr[blockEnd:]
+	}
+	return contentStr
+}
+
+// bookPage returns a book page's content with its frontmatter updated with
+// data, without writing anything
+func bookPage(content, originalTitle string, data BookData) (string, error) {
+	page, err := consumed.Parse(content)
+	if err != nil {
+		return "", err
+	}
+	page.Frontmatter = bookFields(page.Frontmatter, originalTitle, data)
+	return string(page.Bytes()), nil
+}
+
+// bookFields returns the fields of a book, a books.toml block or a page's
+// frontmatter, updated with data. Locked fields are left alone.
+func bookFields(frontmatter, originalTitle string, data BookData) string {
+	before := &consumed.Page{Frontmatter: frontmatter}
+	before.ClearLocked(map[string]*string{
+		"author":        &data.Author,
+		"subtitle":      &data.Subtitle,
+		"series":        &data.Series,
+		"series_number": &data.SeriesNumber,
+		"year":          &data.Year,
+		"publisher":     &data.Publisher,
+		"language":      &data.Language,
+		"openlibrary":   &data.OpenLibraryURL,
+		"img":           &data.CoverPath,
+	})
+	if before.Locked("authors") {
+		data.Authors = nil
+	}
+	if before.Locked("pages") {
+		data.PageCount = 0
+	}
+	if before.Locked("subjects") {
+		data.Subjects = nil
+	}
+	if before.Locked("img") {
+		data.Artwork = imageset.Artwork{}
+	}
+
+	book := &consumed.Page{Frontmatter: frontmatter}
+	set := func(key, value string, after ...string) {
+		if value != "" {
+			book.SetString(key, value, after...)
 		}
-		
-		// Update or add cover image path
-		if data.CoverPath != "" {
-			if regexp.MustCompile(`img\s*=\s*"[^"]*"`).MatchString(modifiedBlock) {
-				modifiedBlock = regexp.MustCompile(`(img\s*=\s*)"[^"]*"`).ReplaceAllString(modifiedBlock, fmt.Sprintf(`$1"%s"`, data.CoverPath))
-			} else {
-				// Try to insert after title or at the beginning of the block
-				if regexp.MustCompile(`title\s*=\s*"[^"]+"`).MatchString(modifiedBlock) {
-					modifiedBlock = regexp.MustCompile(`(title\s*=\s*"[^"]+")`).ReplaceAllString(modifiedBlock, fmt.Sprintf("$1\nimg = \"%s\"", data.CoverPath))
-				} else {
-					modifiedBlock = strings.TrimRight(modifiedBlock, "\n") + fmt.Sprintf("\nimg = \"%s\"", data.CoverPath)
-				}
-			}
+	}
+
+	// Split the title
+	title, subtitle := consumed.SplitTitle(originalTitle)
+	if subtitle == "" {
+		subtitle = data.Subtitle
+	}
+	if title != originalTitle && !book.Locked("title") {
+		book.SetString("title", title)
+	}
+	if !book.Locked("subtitle") {
+		set("subtitle", subtitle, "title")
+	}
+
+	set("author", data.Author, "subtitle", "title")
+	if len(data.Authors) > 0 {
+		book.SetStrings("authors", data.Authors, "author", "title")
+	}
+	if data.Series != "" {
+		set("series", data.Series, "authors", "author", "title")
+		if _, err := strconv.ParseFloat(data.SeriesNumber, 64); err == nil {
+			book.Set("series_number", data.SeriesNumber, "series")
+		} else {
+			set("series_number", data.SeriesNumber, "series")
 		}
-		
-		// Add processed flag to mark book as processed
-		if !regexp.MustCompile(`processed\s*=\s*(true|yes)`).MatchString(modifiedBlock) {
-			// Insert after img, openlibrary, publisher, year, author, or title (in that order)
-			if regexp.MustCompile(`img\s*=\s*"[^"]+"`).MatchString(modifiedBlock) {
-				modifiedBlock = regexp.MustCompile(`(img\s*=\s*"[^"]+")`).ReplaceAllString(modifiedBlock, fmt.Sprintf("$1\nprocessed = true"))
-			} else if regexp.MustCompile(`openlibrary\s*=\s*"[^"]+"`).MatchString(modifiedBlock) {
-				modifiedBlock = regexp.MustCompile(`(openlibrary\s*=\s*"[^"]+")`).ReplaceAllString(modifiedBlock, fmt.Sprintf("$1\nprocessed = true"))
-			} else if regexp.MustCompile(`publisher\s*=\s*"[^"]+"`).MatchString(modifiedBlock) {
-				modifiedBlock = regexp.MustCompile(`(publisher\s*=\s*"[^"]+")`).ReplaceAllString(modifiedBlock, fmt.Sprintf("$1\nprocessed = true"))
-			} else if regexp.MustCompile(`year\s*=\s*"[^"]+"`).MatchString(modifiedBlock) {
-				modifiedBlock = regexp.MustCompile(`(year\s*=\s*"[^"]+")`).ReplaceAllString(modifiedBlock, fmt.Sprintf("$1\nprocessed = true"))
-			} else if regexp.MustCompile(`author\s*=\s*"[^"]+"`).MatchString(modifiedBlock) {
-				modifiedBlock = regexp.MustCompile(`(author\s*=\s*"[^"]+")`).ReplaceAllString(modifiedBlock, fmt.Sprintf("$1\nprocessed = true"))
-			} else if regexp.MustCompile(`title\s*=\s*"[^"]+"`).MatchString(modifiedBlock) {
-				modifiedBlock = regexp.MustCompile(`(title\s*=\s*"[^"]+")`).ReplaceAllString(modifiedBlock, fmt.Sprintf("$1\nprocessed = true"))
-			} else {
-				modifiedBlock = strings.TrimRight(modifiedBlock, "\n") + fmt.Sprintf("\nprocessed = true")
-			}
+	}
+	set("year", data.Year, "series_number", "series", "authors", "author", "title")
+	set("publisher", data.Publisher, "year", "authors", "author", "title")
+	if data.PageCount > 0 {
+		book.Set("pages", strconv.Itoa(data.PageCount), "publisher", "year", "series_number", "series", "authors", "author", "title")
+	}
+	set("language", data.Language, "pages", "publisher", "year", "series_number", "series", "authors", "author", "title")
+	if len(data.Subjects) > 0 {
+		book.SetStrings("subjects", data.Subjects, "language", "pages", "publisher", "year", "series_number", "series", "authors", "author", "title")
+	}
+	set("openlibrary", data.OpenLibraryURL, "subjects", "language", "pages", "publisher", "year", "author", "title")
+	set("img", data.CoverPath, "openlibrary", "title")
+	if data.Artwork.Placeholder != "" {
+		data.Artwork.Apply(book)
+	}
+
+	origin := before
+	if data.Refreshed {
+		// Every value came from the provider, even those that didn't change
+		origin = &consumed.Page{}
+	}
+	book.RecordProvenance(origin, data.Source, "author", "authors", "subtitle", "series", "series_number",
+		"year", "publisher", "pages", "language", "subjects", "openlibrary", "img")
+
+	// Mark the book as processed
+	if !book.Bool("processed") {
+		book.Set("processed", "true", "accent", "img", "openlibrary", "publisher", "year", "author", "title")
+	}
+	return book.Frontmatter
+}
+
+// findCollection finds the [[collection]] block of a title in books.toml,
+// returning where its fields start and end
+func findCollection(contentStr, title string) (int, int, bool) {
+	// Index-based, as Go regexps have no lookahead
+	indices := regexp.MustCompile(`\[\[collection\]\]\s*\n`).FindAllStringIndex(contentStr, -1)
+	titlePattern := regexp.MustCompile(fmt.Sprintf(`title\s*=\s*"%s"`, regexp.QuoteMeta(title)))
+	for i, idx := range indices {
+		start := idx[1] // Start after [[collection]]\n
+		end := len(contentStr)
+		if i+1 < len(indices) {
+			end = indices[i+1][0] // Next [[
 		}
-		
-		if modifiedBlock != currentBlock {
-			// Replace the block in the original content
-			contentStr = contentStr[:blockStart] + modifiedBlock + contentStr[blockEnd:]
-			content = []byte(contentStr)
-			updated = true
-			fmt.Printf("  ✓ Updated %s in books.toml\n", originalTitle)
-			// Recalculate indices after modification
-			indices = re.FindAllStringIndex(contentStr, -1)
+		if titlePattern.MatchString(contentStr[start:end]) {
+			return start, end, true
 		}
 	}
-	
-	if updated {
-		return os.WriteFile(filepath, content, 0644)
-	}
-	
-	return nil
+	return 0, 0, false
 }
 
+// apiCache answers repeated Google Books and Open Library requests from
+// .cache/http
+var apiCache *httpcache.Cache
+
 func main() {
-	updateToml := flag.Bool("update-toml", false, "Update consumed.toml with fetched metadata")
+	updatePages := flag.Bool("update-pages", true, "Update book pages with fetched metadata (default: true)")
+	updateToml := flag.Bool("update-toml", false, "Update books.toml with fetched metadata, on sites that still have one")
+	includeDrafts := flag.Bool("include-drafts", false, "Include draft books when processing")
 	skipExisting := flag.Bool("skip-existing", false, "Skip books that already have author and year")
+	refresh := flag.Bool("refresh", false, "Ignore cached Google Books and Open Library responses and fetch them again")
+	offline := flag.Bool("offline", false, "Only use cached API responses, never the network")
+	workers := flag.Int("workers", workpool.Workers, "Number of books to process at once")
+	resume := flag.Bool("resume", false, "Continue the last run from the first book it didn't finish")
+	dryRun := flag.Bool("dry-run", false, "Show the changes to each page (or books.toml) as a diff without writing anything")
+	interactive := flag.Bool("interactive", false, "Show the changes to each page (or books.toml) and ask before applying each one")
+	refreshStale := flag.Bool("refresh-stale", false, "Look up processed books again whose data is older than -max-age or changed at the provider")
+	maxAge := flag.Int("max-age", 180, "Days after which -refresh-stale looks a book up again")
+	record := flag.String("record", "", "Save the Google Books and Open Library responses of this run as fixtures in a directory")
+	replay := flag.String("replay", "", "Answer Google Books and Open Library requests from the fixtures in a directory, never the network")
 	flag.Parse()
+
+	if *dryRun && *interactive {
+		fmt.Fprintln(os.Stderr, "Error: -dry-run and -interactive can't be combined")
+		os.Exit(1)
+	}
+	if *record != "" && (*replay != "" || *offline) {
+		fmt.Fprintln(os.Stderr, "Error: -record fetches from the providers and can't be combined with -replay or -offline")
+		os.Exit(1)
+	}
 	
 	// Load .env file if it exists (before checking environment)
 	baseDir := getBaseDir()
@@ -618,23 +843,84 @@ func main() {
 			// .env loaded successfully
 		}
 	}
+	var fake *fakeprovider.Server
+	if *replay != "" {
+		var stop func()
+		var err error
+		fake, stop, err = fakeprovider.Replay(*replay)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading fixtures: %v\n", err)
+			os.Exit(1)
+		}
+		defer stop()
+	}
+	overrideBase(&openLibraryAPIBase, "OPENLIBRARY_API_BASE")
+	overrideBase(&googleBooksAPIBase, "GOOGLE_BOOKS_API_BASE")
 	
+	apiCache = httpcache.New(baseDir)
+	apiCache.Refresh, apiCache.Offline, apiCache.NoStore = *refresh, *offline, *dryRun
+	apiCache.Transport = workpool.NewTransport(http.DefaultTransport, workpool.Limits)
+	var recorder *fakeprovider.Recorder
+	if *record != "" {
+		// Fetch every response, so every one is recorded
+		recorder = &fakeprovider.Recorder{Dir: *record, Transport: apiCache.Transport}
+		apiCache.Transport, apiCache.Refresh = recorder, true
+	}
+	artwork.Client.Transport = apiCache
+
+	// The book pages hold the metadata. Sites from before the pages keep it
+	// in books.toml, which is used instead when it exists.
+	contentDir := filepath.Join(baseDir, "content")
 	booksFile := filepath.Join(baseDir, "data", "books", "books.toml")
-	
-	books, err := parseConsumedToml(booksFile)
+	_, err := os.Stat(booksFile)
+	useToml := err == nil
+
+	var books []map[string]string
+	if useToml {
+		books, err = parseConsumedToml(booksFile)
+	} else {
+		books, err = parseBookPages(contentDir, *includeDrafts)
+	}
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error reading consumed.toml: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Error reading books: %v\n", err)
 		os.Exit(1)
 	}
 	
 	if len(books) == 0 {
-		fmt.Println("No books found in books.toml")
+		fmt.Println("No books found")
 		return
 	}
 	
 	// Filter by command-line arguments if provided
 	args := flag.Args()
-	if len(args) > 0 {
+	var run *journal.Journal
+	if *resume {
+		if len(args) > 0 {
+			fmt.Fprintln(os.Stderr, "Error: -resume continues the last run and takes no titles")
+			os.Exit(1)
+		}
+		run, err = journal.Latest(baseDir, "books")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Nothing to resume: %v\n", err)
+			os.Exit(1)
+		}
+		if run.Finished {
+			fmt.Printf("Run %s finished, nothing to resume\n", run.ID)
+			return
+		}
+		pending := make(map[string]bool)
+		for _, e := range run.Pending() {
+			pending[e.Key] = true
+		}
+		var filtered []map[string]string
+		for _, book := range books {
+			if pending[bookKey(baseDir, book)] {
+				filtered = append(filtered, book)
+			}
+		}
+		books = filtered
+		fmt.Printf("Resuming run %s: %d of %d book(s) left\n", run.ID, len(books), len(run.Entries))
+	} else if len(args) > 0 {
 		var filtered []map[string]string
 		for _, book := range books {
 			for _, arg := range args {
@@ -646,107 +932,352 @@ func main() {
 		}
 		books = filtered
 	}
+	if *refreshStale && !*resume {
+		var processed []map[string]string
+		for _, book := range books {
+			if book["processed"] == "true" {
+				processed = append(processed, book)
+			}
+		}
+		books = processed
+	}
 	
 	fmt.Printf("Processing %d book(s)...\n\n", len(books))
+
+	if run == nil && !*dryRun {
+		entries := make([]journal.Entry, len(books))
+		for i, book := range books {
+			entries[i] = journal.Entry{Key: bookKey(baseDir, book), Title: book["title"]}
+		}
+		if run, err = journal.New(baseDir, "books", entries); err != nil {
+			fmt.Fprintf(os.Stderr, "Error starting the run journal: %v\n", err)
+			os.Exit(1)
+		}
+	}
 	
-	bookUpdates := make(map[string]*BookData)
 	imagesDir := filepath.Join(baseDir, "static", "images", "books")
-	os.MkdirAll(imagesDir, 0755)
+	if !*dryRun {
+		os.MkdirAll(imagesDir, 0755)
+	}
 	
-	for _, book := range books {
-		title := book["title"]
-		
-		// Skip if marked as processed
-		if book["processed"] == "true" {
-			fmt.Printf("Skipping %s (already processed)\n", title)
-			continue
-		}
-		
-		// Skip if already has all metadata (when using --skip-existing flag)
-		if *skipExisting && book["author"] != "" && book["year"] != "" {
-			fmt.Printf("Skipping %s (already has metadata)\n", title)
-			continue
-		}
-		
-		data, err := processBook(title)
-		if err != nil {
-			fmt.Printf("  ✗ Error: %v\n\n", err)
-			continue
-		}
-		
-		fmt.Printf("  Author: %s\n", data.Author)
-		fmt.Printf("  Year: %s\n", data.Year)
-		if data.Publisher != "" {
-			fmt.Printf("  Publisher: %s\n", data.Publisher)
+	ctx, stop := context.WithCancel(workpool.Interruptible())
+	defer stop()
+	reviewer := &changes.Reviewer{DryRun: *dryRun, Interactive: *interactive, BaseDir: baseDir}
+	if run != nil && !*dryRun {
+		reviewer.Recorder = run
+	}
+	opts := fetchOptions{
+		imagesDir:    imagesDir,
+		skipExisting: *skipExisting,
+		dryRun:       *dryRun,
+		refreshStale: *refreshStale,
+		named:        len(args) > 0,
+		maxAge:       time.Duration(*maxAge) * 24 * time.Hour,
+		locks:        &workpool.Locks{},
+	}
+	if useToml && *updateToml {
+		opts.booksFile = booksFile
+	}
+	opts.updatePages = !useToml && *updatePages
+	workpool.Run(ctx, len(books), *workers, func(i int) bookOutcome {
+		var out bytes.Buffer
+		data, change, status := fetchBook(ctx, books[i], opts, &out)
+		return bookOutcome{data: data, change: change, status: status, log: out.String()}
+	}, func(i int, o bookOutcome) {
+		fmt.Print(o.log)
+		status := o.status
+		if o.change != nil {
+			status = applyChange(reviewer, o.change, books[i]["title"], status)
+			if reviewer.Stopped() {
+				stop()
+			}
 		}
-		fmt.Printf("  Open Library: %s\n", data.OpenLibraryURL)
-		
-		// Download cover if available
-		if data.CoverURL != "" {
-			coverFilename := getCoverFilename(title)
-			coverPath := filepath.Join(imagesDir, coverFilename)
-			if downloadCover(data.CoverURL, coverPath) {
-				data.CoverPath = fmt.Sprintf("/images/books/%s", coverFilename)
-				fmt.Printf("  Cover: %s\n", data.CoverPath)
-			} else {
-				fmt.Printf("  Warning: Could not download cover\n")
+		if run != nil && !*dryRun {
+			if err := run.Mark(bookKey(baseDir, books[i]), status); err != nil {
+				fmt.Printf("⚠ Could not update the run journal: %v\n", err)
 			}
 		}
-		fmt.Println()
-		
-		bookUpdates[title] = data
-		
-		// Rate limiting
-		time.Sleep(1 * time.Second)
+	})
+
+	hits, fetched := apiCache.Stats()
+	fmt.Printf("API cache: %d hit(s), %d fetched\n", hits, fetched)
+	if recorder != nil {
+		fmt.Printf("Recorded: %d response(s) in %s\n", recorder.Recorded(), *record)
 	}
-	
-	if *updateToml && len(bookUpdates) > 0 {
-		fmt.Println("Updating books.toml...")
-		if err := updateConsumedToml(bookUpdates); err != nil {
-			fmt.Fprintf(os.Stderr, "Error updating books.toml: %v\n", err)
-			os.Exit(1)
+	if fake != nil {
+		for _, u := range fake.Missing() {
+			fmt.Printf("⚠ No fixture for %s\n", u)
 		}
-		fmt.Println("✓ books.toml updated")
 	}
+	if *dryRun {
+		fmt.Println("Dry run: nothing was written")
+		return
+	}
+
+	if err := run.Finish(); err != nil {
+		fmt.Printf("⚠ Could not update the run journal: %v\n", err)
+	}
+	if left := len(run.Pending()); left > 0 {
+		if reviewer.Stopped() {
+			fmt.Printf("Stopped: %d book(s) left, continue with -resume (run %s)\n", left, run.ID)
+			return
+		}
+		fmt.Printf("Interrupted: %d book(s) left, continue with -resume (run %s)\n", left, run.ID)
+		os.Exit(130)
+	}
+}
+
+// applyChange applies (or shows) a book's change once it is reported and
+// returns the book's journal status. A change rejected with "q" leaves the
+// book pending for -resume.
+func applyChange(reviewer *changes.Reviewer, change *changes.Change, title, status string) string {
+	if reviewer.Stopped() {
+		changes.Discard(change)
+		return journal.Pending
+	}
+	applied, err := reviewer.Apply(change)
+	if err != nil {
+		fmt.Printf("  ✗ Error updating %s: %v\n\n", filepath.Base(change.Path), err)
+		return journal.Failed
+	}
+	if applied && strings.HasSuffix(change.Path, ".md") {
+		fmt.Printf("  ✓ Updated markdown file: %s\n\n", filepath.Base(change.Path))
+	} else if applied && change.Path != "" {
+		fmt.Printf("  ✓ Updated %s in books.toml\n\n", title)
+	}
+	if reviewer.Stopped() {
+		return journal.Pending
+	}
+	return status
 }
 
-func slugify(title string) string {
-	// Convert to lowercase and replace spaces/special chars with underscores
-	title = strings.ToLower(title)
-	re := regexp.MustCompile(`[^\w\s-]`)
-	title = re.ReplaceAllString(title, "")
-	re = regexp.MustCompile(`[-\s]+`)
-	title = re.ReplaceAllString(title, "_")
-	return strings.Trim(title, "_")
+// fetchOptions are the settings every worker shares
+type fetchOptions struct {
+	imagesDir    string
+	booksFile    string // "" unless books.toml is updated
+	updatePages  bool
+	skipExisting bool
+	dryRun       bool
+	refreshStale bool          // only stale processed books
+	named        bool          // books named on the command line are looked up even when processed
+	maxAge       time.Duration // for refreshStale
+	locks        *workpool.Locks
 }
 
-func getCoverFilename(title string) string {
-	return slugify(title) + "_cover.jpg"
+// bookOutcome is what a worker hands back for reporting
+type bookOutcome struct {
+	data   *BookData
+	change *changes.Change // nil if there's nothing to write
+	status string          // journal status of the book
+	log    string
 }
 
-func downloadCover(coverURL, outputPath string) bool {
-	if coverURL == "" {
-		return false
+// fetchBook looks up one book and downloads its cover next to where it
+// goes, writing progress to out. It returns nil if the book was skipped,
+// not found, or its cover couldn't be downloaded, the change to its page
+// (or books.toml) and the cover, and the book's journal status: pending if
+// ctx was cancelled, in which case there is no change.
+func fetchBook(ctx context.Context, book map[string]string, opts fetchOptions, out io.Writer) (*BookData, *changes.Change, string) {
+	title := book["title"]
+
+	if opts.refreshStale {
+		stale, why := bookStale(ctx, book, opts)
+		if !stale {
+			fmt.Fprintf(out, "Up to date: %s (%s)\n\n", title, why)
+			return nil, nil, journal.Done
+		}
+		fmt.Fprintf(out, "Refreshing: %s (%s)\n", title, why)
+		ctx = httpcache.WithRefresh(ctx)
+	} else if book["processed"] == "true" && !opts.named {
+		// Skip if marked as processed, unless asked for by title
+		fmt.Fprintf(out, "Skipping %s (already processed)\n", title)
+		return nil, nil, journal.Done
 	}
 
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Get(coverURL)
+	// Skip if already has all metadata (when using --skip-existing flag)
+	if opts.skipExisting && !opts.refreshStale && book["author"] != "" && book["year"] != "" {
+		fmt.Fprintf(out, "Skipping %s (already has metadata)\n", title)
+		return nil, nil, journal.Done
+	}
+
+	data, err := processBook(ctx, title, out)
+	if err == nil {
+		data.Refreshed = opts.refreshStale
+	}
+	if ctx.Err() != nil {
+		fmt.Fprintf(out, "  ⏹️  Interrupted\n\n")
+		return nil, nil, journal.Pending
+	}
 	if err != nil {
-		return false
+		fmt.Fprintf(out, "  ✗ Error: %v\n\n", err)
+		return nil, nil, journal.Failed
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return false
+	if data.Subtitle != "" {
+		fmt.Fprintf(out, "  Subtitle: %s\n", data.Subtitle)
+	}
+	fmt.Fprintf(out, "  Author: %s\n", data.Author)
+	if data.Series != "" {
+		fmt.Fprintf(out, "  Series: %s #%s\n", data.Series, data.SeriesNumber)
+	}
+	fmt.Fprintf(out, "  Year: %s\n", data.Year)
+	if data.Publisher != "" {
+		fmt.Fprintf(out, "  Publisher: %s\n", data.Publisher)
+	}
+	if data.PageCount > 0 {
+		fmt.Fprintf(out, "  Pages: %d\n", data.PageCount)
+	}
+	if data.Language != "" {
+		fmt.Fprintf(out, "  Language: %s\n", data.Language)
+	}
+	if len(data.Subjects) > 0 {
+		fmt.Fprintf(out, "  Subjects: %s\n", strings.Join(data.Subjects, ", "))
 	}
+	fmt.Fprintf(out, "  Open Library: %s\n", data.OpenLibraryURL)
 
-	out, err := os.Create(outputPath)
+	// Download cover if available
+	change := &changes.Change{}
+	if slices.Contains(strings.Split(book["locked"], ","), "img") {
+		fmt.Fprintf(out, "  🔒 img is locked, cover not downloaded\n")
+	} else if data.CoverURL != "" {
+		coverFilename := getCoverFilename(title, data.Year, artwork.ProviderID(data.OpenLibraryURL))
+		cover := changes.File{Path: filepath.Join(opts.imagesDir, coverFilename)}
+		if !opts.dryRun {
+			cover.Staged = changes.StagedPath(cover.Path)
+			unlock := opts.locks.Lock(cover.Path)
+			err := downloadCover(ctx, data.CoverURL, cover)
+			unlock()
+			if ctx.Err() != nil {
+				changes.Discard(&changes.Change{Files: []changes.File{cover}})
+				fmt.Fprintf(out, "  ⏹️  Interrupted\n\n")
+				return nil, nil, journal.Pending
+			}
+			if err != nil {
+				// Leave the page alone so the next run tries again
+				fmt.Fprintf(out, "  ✗ Could not download cover: %v (not updated)\n\n", err)
+				return nil, nil, journal.Failed
+			}
+			if art, err := imageset.Analyze(cover.Current()); err != nil {
+				fmt.Fprintf(out, "  ⚠ No placeholder for cover: %v\n", err)
+			} else {
+				data.Artwork = art
+			}
+		}
+		data.CoverPath = fmt.Sprintf("/images/books/%s", coverFilename)
+		fmt.Fprintf(out, "  Cover: %s\n", data.CoverPath)
+		change.Files = append(change.Files, cover)
+	}
+	fmt.Fprintln(out)
+
+	switch {
+	case opts.booksFile != "":
+		update := map[string]*BookData{title: data}
+		change.Path = opts.booksFile
+		change.Update = func(content string) (string, error) {
+			return booksToml(content, update), nil
+		}
+	case opts.updatePages && book["path"] != "":
+		update := *data
+		change.Path = book["path"]
+		change.Update = func(content string) (string, error) {
+			return bookPage(content, title, update)
+		}
+	}
+	if opts.refreshStale && change.Path != "" {
+		describeBookChanges(out, change.Path, title, change.Update)
+	}
+	if change.Path == "" && len(change.Files) == 0 {
+		return data, nil, journal.Done
+	}
+	return data, change, journal.Done
+}
+
+// bookStale tells whether a book's data is due for a refresh: it was fetched
+// more than opts.maxAge ago, or the provider changed it since, and why
+func bookStale(ctx context.Context, book map[string]string, opts fetchOptions) (bool, string) {
+	fetched, err := time.Parse(time.RFC3339, book["fetched"])
+	if book["source"] == "" || err != nil {
+		return true, "no provenance recorded"
+	}
+	src := consumed.Source{Provider: book["source"], ID: book["source_id"], Fetched: fetched}
+	due, why := src.Due(opts.maxAge, time.Now())
+	if due {
+		return true, why
+	}
+
+	// Ask about the response the data came from
+	var u string
+	switch src.Provider {
+	case "google-books":
+		u = googleSearchURL(book["title"])
+	case "openlibrary":
+		u = fmt.Sprintf("%s/works/%s.json", openLibraryAPIBase, src.ID)
+	default:
+		return false, why
+	}
+	req, _ := http.NewRequestWithContext(ctx, "GET", u, nil)
+	changed, err := apiCache.Changed(req)
 	if err != nil {
-		return false
+		return false, fmt.Sprintf("%s; could not ask %s: %v", why, src.Provider, err)
+	}
+	if changed {
+		return true, "changed at " + src.Provider
 	}
-	defer out.Close()
+	return false, why
+}
+
+// describeBookChanges writes the fields an update would change on a book's
+// page, or its block in books.toml, for -refresh-stale to report
+func describeBookChanges(out io.Writer, path, title string, update func(string) (string, error)) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	updated, err := update(string(content))
+	if err != nil {
+		return
+	}
+	var before, after *consumed.Page
+	if strings.HasSuffix(path, ".md") {
+		if before, err = consumed.Parse(string(content)); err != nil {
+			return
+		}
+		if after, err = consumed.Parse(updated); err != nil {
+			return
+		}
+	} else {
+		start, end, ok := findCollection(string(content), title)
+		newStart, newEnd, newOK := findCollection(updated, title)
+		if !ok || !newOK {
+			return
+		}
+		before = &consumed.Page{Frontmatter: string(content)[start:end]}
+		after = &consumed.Page{Frontmatter: updated[newStart:newEnd]}
+	}
+	changed := after.ChangedFields(before)
+	if len(changed) == 0 {
+		fmt.Fprintf(out, "  ✓ Nothing changed\n")
+	}
+	for _, c := range changed {
+		fmt.Fprintf(out, "  ↻ %s\n", c)
+	}
+}
 
-	_, err = io.Copy(out, resp.Body)
-	return err == nil
+// getCoverFilename names a cover by title and year (or Open Library ID),
+// so books with the same title don't overwrite each other's cover
+func getCoverFilename(title, year, openLibraryID string) string {
+	return artwork.Filename(artwork.Slug(title), artwork.Key(year, openLibraryID), "cover", ".jpg")
 }
 
+// downloadCover saves a cover to cover.Staged, leaving it alone unless a
+// valid image arrived
+func downloadCover(ctx context.Context, coverURL string, cover changes.File) error {
+	if apiCache.Offline {
+		// Artwork isn't cached; keep the cover we have
+		if _, err := os.Stat(cover.Path); err == nil {
+			os.Remove(cover.Staged)
+			return nil
+		}
+	}
+	return artwork.Download(ctx, coverURL, cover.Staged)
+}
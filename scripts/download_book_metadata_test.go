@@ -0,0 +1,115 @@
+package main
+
+// Golden tests of the Google Books and Open Library lookups, against the
+// recorded responses in scripts/internal/fakeprovider/fixtures:
+//
+//	go test scripts/download_book_metadata.go scripts/download_book_metadata_test.go
+//
+// Add -args -update to rewrite testdata/golden/book after an intended
+// change.
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/fakeprovider"
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/golden"
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/httpcache"
+)
+
+// replayBooks points the Google Books and Open Library requests at the
+// fixtures and returns the server's URL
+func replayBooks(t *testing.T) string {
+	t.Helper()
+	s, err := fakeprovider.New(fakeprovider.Fixtures)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(s)
+	google, openLibrary, cache := googleBooksAPIBase, openLibraryAPIBase, apiCache
+	t.Cleanup(func() {
+		srv.Close()
+		googleBooksAPIBase, openLibraryAPIBase, apiCache = google, openLibrary, cache
+	})
+
+	bases := fakeprovider.Bases(srv.URL)
+	googleBooksAPIBase, openLibraryAPIBase = bases["GOOGLE_BOOKS_API_BASE"], bases["OPENLIBRARY_API_BASE"]
+	apiCache = httpcache.New(t.TempDir())
+	return srv.URL
+}
+
+func TestProcessBook(t *testing.T) {
+	serverURL := replayBooks(t)
+	tests := []struct {
+		name, title string
+	}{
+		{"google-books", "All About Love"},
+		{"open-library", "How to Take Smart Notes"},
+		{"not-found", "No Such Book"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			data, err := processBook(context.Background(), tt.title, &out)
+			if err != nil {
+				out.WriteString("error: " + err.Error() + "\n")
+			}
+			report := golden.Report(out.String(), data, serverURL, "http://fakeprovider")
+			golden.Check(t, filepath.Join("testdata", "golden", "book", tt.name+".txt"), report)
+		})
+	}
+}
+
+func TestBookPage(t *testing.T) {
+	serverURL := replayBooks(t)
+	page := `+++
+title = "All About Love"
+date = 2024-06-01
+draft = false
+
+category = "book"
+author = "bell hooks"
+publisher = "Harper"
+locked = ["publisher"]
+rating = 5
++++
+
+Review.
+`
+	var out bytes.Buffer
+	data, err := processBook(context.Background(), "All About Love", &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data.CoverPath = "/images/books/all_about_love_2001_cover.jpg"
+	updated, err := bookPage(page, "All About Love", *data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := strings.ReplaceAll(updated, serverURL, "http://fakeprovider")
+	golden.Check(t, filepath.Join("testdata", "golden", "book", "page.md"), report)
+}
+
+func TestFetchBookNamed(t *testing.T) {
+	replayBooks(t)
+	book := map[string]string{"title": "All About Love", "processed": "true", "path": "all-about-love.md"}
+	tests := []struct {
+		named   bool
+		changed bool
+	}{
+		{named: false, changed: false},
+		{named: true, changed: true},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		opts := fetchOptions{dryRun: true, updatePages: true, named: tt.named}
+		_, change, _ := fetchBook(context.Background(), book, opts, &out)
+		if changed := change != nil && change.Path == book["path"]; changed != tt.changed {
+			t.Errorf("named %v: page changed = %v, want %v\n%s", tt.named, changed, tt.changed, out.String())
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
@@ -8,14 +10,25 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
-	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/artwork"
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/changes"
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/consumed"
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/fakeprovider"
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/httpcache"
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/imageset"
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/journal"
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/secrets"
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/workpool"
 	"github.com/joho/godotenv"
 )
 
-const (
+// API bases, which TMDB_API_BASE and TMDB_IMAGE_BASE override (see
+// overrideBase)
+var (
 	tmdbAPIBase   = "https://api.themoviedb.org/3"
 	tmdbImageBase = "https://image.tmdb.org/t/p/w500"
 )
@@ -41,9 +54,12 @@ type CrewMember struct {
 }
 
 type MovieDetails struct {
-	ID      int     `json:"id"`
-	Title   string  `json:"title"`
-	Credits Credits `json:"credits"`
+	ID          int       `json:"id"`
+	Title       string    `json:"title"`
+	ReleaseDate string    `json:"release_date"`
+	PosterPath  string    `json:"poster_path"`
+	Credits     Credits   `json:"credits"`
+	Fetched     time.Time `json:"-"` // when TMDB sent the details
 }
 
 type Video struct {
@@ -59,38 +75,120 @@ type VideosResponse struct {
 
 // MovieData is now defined in markdown_helpers.go
 
+// apiCache answers repeated TMDB requests from .cache/http
+var apiCache *httpcache.Cache
+
 func main() {
-	var updatePages, skipExisting, includeDrafts bool
+	var updatePages, skipExisting, includeDrafts, refresh, offline, resume, dryRun, interactive, refreshStale bool
+	var workers, maxAge int
+	var record, replay string
 	flag.BoolVar(&updatePages, "update-pages", true, "Update markdown pages with fetched metadata (default: true)")
 	flag.BoolVar(&skipExisting, "skip-existing", false, "Skip movies that already have posters and directors")
 	flag.BoolVar(&includeDrafts, "include-drafts", false, "Include draft movies when processing")
+	flag.BoolVar(&refresh, "refresh", false, "Ignore cached TMDB responses and fetch them again")
+	flag.BoolVar(&offline, "offline", false, "Only use cached TMDB responses, never the network")
+	flag.IntVar(&workers, "workers", workpool.Workers, "Number of movies to process at once")
+	flag.BoolVar(&resume, "resume", false, "Continue the last run from the first movie it didn't finish")
+	flag.BoolVar(&dryRun, "dry-run", false, "Show the changes to each page as a diff without writing anything")
+	flag.BoolVar(&interactive, "interactive", false, "Show the changes to each page and ask before applying them")
+	flag.BoolVar(&refreshStale, "refresh-stale", false, "Look up processed movies again whose data is older than -max-age or changed on TMDB")
+	flag.IntVar(&maxAge, "max-age", 180, "Days after which -refresh-stale looks a movie up again")
+	flag.StringVar(&record, "record", "", "Save the TMDB responses of this run as fixtures in a directory, without the API key")
+	flag.StringVar(&replay, "replay", "", "Answer TMDB requests from the fixtures in a directory, never the network")
 	flag.Parse()
 
-	apiKey := getAPIKey()
-	if apiKey == "" {
+	if dryRun && interactive {
+		fmt.Println("Error: -dry-run and -interactive can't be combined")
+		os.Exit(1)
+	}
+	if record != "" && (replay != "" || offline) {
+		fmt.Println("Error: -record fetches from TMDB and can't be combined with -replay or -offline")
+		os.Exit(1)
+	}
+
+	// Replays need no key
+	apiKey := "replay"
+	var fake *fakeprovider.Server
+	if replay != "" {
+		var stop func()
+		var err error
+		fake, stop, err = fakeprovider.Replay(replay)
+		if err != nil {
+			fmt.Printf("Error loading fixtures: %v\n", err)
+			os.Exit(1)
+		}
+		defer stop()
+	} else if apiKey = getAPIKey(); apiKey == "" {
 		os.Exit(1)
 	}
+	overrideBase(&tmdbAPIBase, "TMDB_API_BASE")
+	overrideBase(&tmdbImageBase, "TMDB_IMAGE_BASE")
 
 	baseDir := getBaseDir()
 	imagesDir := filepath.Join(baseDir, "static", "images", "movies")
+	apiCache = httpcache.New(baseDir)
+	apiCache.Refresh, apiCache.Offline, apiCache.NoStore = refresh, offline, dryRun
+	apiCache.Transport = workpool.NewTransport(http.DefaultTransport, workpool.Limits)
+	var recorder *fakeprovider.Recorder
+	if record != "" {
+		// Fetch every response, so every one is recorded
+		recorder = &fakeprovider.Recorder{Dir: record, Transport: apiCache.Transport}
+		apiCache.Transport, apiCache.Refresh = recorder, true
+	}
+	artwork.Client.Transport = apiCache
 	contentDir := filepath.Join(baseDir, "content")
 
-	os.MkdirAll(imagesDir, 0755)
+	if !dryRun {
+		os.MkdirAll(imagesDir, 0755)
+	}
 
 	// Get movies to process
 	var movies []MovieInfo
-	if flag.NArg() > 0 {
+	var run *journal.Journal
+	if resume {
+		if flag.NArg() > 0 {
+			fmt.Println("Error: -resume continues the last run and takes no titles")
+			os.Exit(1)
+		}
+		var err error
+		run, err = journal.Latest(baseDir, "movies")
+		if err != nil {
+			fmt.Printf("Nothing to resume: %v\n", err)
+			os.Exit(1)
+		}
+		if run.Finished {
+			fmt.Printf("Run %s finished, nothing to resume\n", run.ID)
+			os.Exit(0)
+		}
+		for _, e := range run.Pending() {
+			if path, ok := e.Page(baseDir); ok {
+				movies = append(movies, loadMovieInfo(path, e.Title))
+			} else {
+				movies = append(movies, MovieInfo{Title: e.Title})
+			}
+		}
+		fmt.Printf("Resuming run %s: %d of %d movies left\n", run.ID, len(movies), len(run.Entries))
+	} else if flag.NArg() > 0 {
 		// If titles provided as args, find corresponding markdown files
 		for _, title := range flag.Args() {
-			// Try to find the file by title
-			slug := slugify(title)
-			filePath := filepath.Join(contentDir, "consumed", "movie", slug+".md")
-			if _, err := os.Stat(filePath); err == nil {
+			// Try to find the file by slug or title
+			if filePath, ok := findPageFile(contentDir, "movie", title); ok && refreshStale {
+				movies = append(movies, loadMovieInfo(filePath, title))
+			} else if ok {
 				movies = append(movies, MovieInfo{Title: title, FilePath: filePath})
 			} else {
 				fmt.Printf("Warning: Could not find file for movie: %s\n", title)
 			}
 		}
+	} else if refreshStale {
+		paths, err := processedPages(contentDir, "movie", includeDrafts)
+		if err != nil {
+			fmt.Printf("Error reading markdown files: %v\n", err)
+			os.Exit(1)
+		}
+		for _, path := range paths {
+			movies = append(movies, loadMovieInfo(path, strings.TrimSuffix(filepath.Base(path), ".md")))
+		}
 	} else {
 		var err error
 		movies, err = parseMarkdownFiles(contentDir, includeDrafts)
@@ -107,82 +205,62 @@ func main() {
 
 	fmt.Printf("Found %d movies to process\n\n", len(movies))
 
-	results := make(map[string]MovieData)
-
-	for _, movie := range movies {
-		// Skip if marked as processed and has all metadata (safety check)
-		// This should already be filtered in parseMarkdownFiles, but check again for safety
-		if skipExisting && movie.Director != "" {
-			posterFile := getPosterFilename(movie.Title)
-			posterPath := filepath.Join(imagesDir, posterFile)
-			if _, err := os.Stat(posterPath); err == nil {
-				fmt.Printf("\nSkipping %s (already has poster and director)\n", movie.Title)
-				continue
-			}
-		}
-		
-		// Show what's missing
-		missing := []string{}
-		if movie.Year == "" {
-			missing = append(missing, "year")
+	if run == nil && !dryRun {
+		entries := make([]journal.Entry, len(movies))
+		for i, movie := range movies {
+			entries[i] = journal.Entry{Key: journal.Key(baseDir, movie.FilePath, movie.Title), Title: movie.Title}
 		}
-		if movie.Director == "" {
-			missing = append(missing, "director")
+		var err error
+		if run, err = journal.New(baseDir, "movies", entries); err != nil {
+			fmt.Printf("Error starting the run journal: %v\n", err)
+			os.Exit(1)
 		}
-		if len(missing) > 0 {
-			fmt.Printf("\nProcessing: %s (missing: %s)\n", movie.Title, strings.Join(missing, ", "))
-		} else {
-			fmt.Printf("\nProcessing: %s\n", movie.Title)
-		}
-
-		result := processMovie(apiKey, movie.Title, movie.Year, movie.Director)
-		if result != nil {
-			results[movie.Title] = *result
-
-			// Download poster
-			posterDownloaded := false
-			if result.PosterPath != "" {
-				posterFile := getPosterFilename(movie.Title)
-				posterPath := filepath.Join(imagesDir, posterFile)
-				if downloadPoster(result.PosterURL, posterPath) {
-					result.ImagePath = fmt.Sprintf("/images/movies/%s", posterFile)
-					posterDownloaded = true
-					fmt.Printf("  ✓ Downloaded poster: %s\n", posterFile)
-				} else {
-					fmt.Printf("  ⚠ Failed to download poster\n")
-				}
-			} else {
-				fmt.Printf("  ⚠ No poster available\n")
-			}
-
-			// Mark as draft if no poster was downloaded
-			if !posterDownloaded {
-				result.Draft = true
-			}
+	}
 
-			// Update markdown file
-			if updatePages && movie.FilePath != "" {
-				if err := updateMarkdownFrontmatter(movie.FilePath, *result); err != nil {
-					fmt.Printf("  ✗ Error updating markdown file: %v\n", err)
-				} else {
-					fmt.Printf("  ✓ Updated markdown file: %s\n", filepath.Base(movie.FilePath))
-				}
+	ctx, stop := context.WithCancel(workpool.Interruptible())
+	defer stop()
+	results := make(map[string]MovieData)
+	reviewer := &changes.Reviewer{DryRun: dryRun, Interactive: interactive, BaseDir: baseDir}
+	if run != nil && !dryRun {
+		reviewer.Recorder = run
+	}
+	opts := fetchOptions{
+		apiKey:       apiKey,
+		imagesDir:    imagesDir,
+		skipExisting: skipExisting,
+		updatePages:  updatePages,
+		offline:      offline,
+		dryRun:       dryRun,
+		refreshStale: refreshStale,
+		maxAge:       time.Duration(maxAge) * 24 * time.Hour,
+		locks:        &workpool.Locks{},
+	}
+	workpool.Run(ctx, len(movies), workers, func(i int) movieOutcome {
+		var out bytes.Buffer
+		result, change, status := fetchMovie(ctx, movies[i], opts, &out)
+		return movieOutcome{result: result, change: change, status: status, log: out.String()}
+	}, func(i int, o movieOutcome) {
+		fmt.Print(o.log)
+		if o.result != nil {
+			results[movies[i].Title] = *o.result
+		}
+		status := o.status
+		if o.change != nil {
+			status = applyChange(reviewer, o.change, status)
+			if reviewer.Stopped() {
+				stop()
 			}
-		} else {
-			// Movie not found - mark as draft
-			fmt.Printf("  ⚠ Movie not found, marking as draft\n")
-			if updatePages && movie.FilePath != "" {
-				draftData := MovieData{
-					Title: movie.Title,
-					Draft: true,
-				}
-				if err := updateMarkdownFrontmatter(movie.FilePath, draftData); err != nil {
-					fmt.Printf("  ✗ Error updating markdown file: %v\n", err)
-				} else {
-					fmt.Printf("  ✓ Updated markdown file (marked as draft)\n")
-				}
+		}
+		if run != nil && !dryRun {
+			if err := run.Mark(journal.Key(baseDir, movies[i].FilePath, movies[i].Title), status); err != nil {
+				fmt.Printf("  ⚠ Could not update the run journal: %v\n", err)
 			}
 		}
+	})
+	if run != nil && !dryRun {
+		if err := run.Finish(); err != nil {
+			fmt.Printf("⚠ Could not update the run journal: %v\n", err)
+		}
 	}
 
 	// Summary
@@ -206,8 +284,261 @@ func main() {
 	fmt.Printf("  Posters downloaded: %d\n", posterCount)
 	fmt.Printf("  Directors found: %d\n", directorCount)
 	fmt.Printf("  Trailers found: %d\n", trailerCount)
+	hits, fetched := apiCache.Stats()
+	fmt.Printf("  API cache: %d hit(s), %d fetched\n", hits, fetched)
+	if recorder != nil {
+		fmt.Printf("  Recorded: %d response(s) in %s\n", recorder.Recorded(), record)
+	}
+	if fake != nil {
+		for _, u := range fake.Missing() {
+			fmt.Printf("  ⚠ No fixture for %s\n", u)
+		}
+	}
+	if dryRun {
+		fmt.Printf("  Dry run: nothing was written\n")
+		return
+	}
+	if left := len(run.Pending()); left > 0 {
+		if reviewer.Stopped() {
+			fmt.Printf("  Stopped: %d movie(s) left, continue with -resume (run %s)\n", left, run.ID)
+			return
+		}
+		fmt.Printf("  Interrupted: %d movie(s) left, continue with -resume (run %s)\n", left, run.ID)
+		os.Exit(130)
+	}
+}
+
+// applyChange applies (or shows) a movie's change once it is reported and
+// returns the movie's journal status. A change rejected with "q" leaves
+// the movie pending for -resume.
+func applyChange(reviewer *changes.Reviewer, change *movieChange, status string) string {
+	if reviewer.Stopped() {
+		changes.Discard(&change.Change)
+		return journal.Pending
+	}
+	applied, err := reviewer.Apply(&change.Change)
+	if err != nil {
+		fmt.Printf("  ✗ Error updating markdown file: %v\n", err)
+		return journal.Failed
+	}
+	if applied && change.Path != "" {
+		fmt.Printf("  ✓ %s\n", change.done)
+	}
+	if reviewer.Stopped() {
+		return journal.Pending
+	}
+	return status
+}
+
+// fetchOptions are the settings every worker shares
+type fetchOptions struct {
+	apiKey       string
+	imagesDir    string
+	skipExisting bool
+	updatePages  bool
+	offline      bool
+	dryRun       bool
+	refreshStale bool          // only stale processed movies, from TMDB's data
+	maxAge       time.Duration // for refreshStale
+	locks        *workpool.Locks
+}
+
+// movieOutcome is what a worker hands back for reporting
+type movieOutcome struct {
+	result *MovieData
+	change *movieChange // nil if there's nothing to write
+	status string       // journal status of the movie
+	log    string
+}
+
+// movieChange is a movie's page update and poster, applied when the movie
+// is reported
+type movieChange struct {
+	changes.Change
+	done string // what to print once it's applied
+}
+
+// fetchMovie looks up one movie and downloads its poster next to where it
+// goes, writing progress to out. It returns the TMDB data, or nil if the
+// movie was skipped or not found, the change to its page and poster, and
+// the movie's journal status: pending if ctx was cancelled, in which case
+// there is no change.
+func fetchMovie(ctx context.Context, movie MovieInfo, opts fetchOptions, out io.Writer) (*MovieData, *movieChange, string) {
+	tmdbID := 0
+	if opts.refreshStale {
+		stale, why, id := movieStale(ctx, movie.FilePath, opts)
+		if !stale {
+			fmt.Fprintf(out, "\nUp to date: %s (%s)\n", movie.Title, why)
+			return nil, nil, journal.Done
+		}
+		fmt.Fprintf(out, "\nRefreshing: %s (%s)\n", movie.Title, why)
+		ctx = httpcache.WithRefresh(ctx)
+		// Take TMDB's values, except where the page locks them. The year
+		// only narrows the search when there's no TMDB ID to go by.
+		tmdbID, movie.Director = id, ""
+		if id != 0 {
+			movie.Year = ""
+		}
+	}
+
+	// Skip if marked as processed and has all metadata (safety check)
+	// This should already be filtered in parseMarkdownFiles, but check again for safety
+	if opts.skipExisting && !opts.refreshStale && movie.Director != "" {
+		if _, err := os.Stat(existingPoster(movie, opts.imagesDir)); err == nil {
+			fmt.Fprintf(out, "\nSkipping %s (already has poster and director)\n", movie.Title)
+			return nil, nil, journal.Done
+		}
+	}
+
+	// Show what's missing
+	missing := []string{}
+	if movie.Year == "" {
+		missing = append(missing, "year")
+	}
+	if movie.Director == "" {
+		missing = append(missing, "director")
+	}
+	if opts.refreshStale {
+		// Already said
+	} else if len(missing) > 0 {
+		fmt.Fprintf(out, "\nProcessing: %s (missing: %s)\n", movie.Title, strings.Join(missing, ", "))
+	} else {
+		fmt.Fprintf(out, "\nProcessing: %s\n", movie.Title)
+	}
+
+	result := processMovie(ctx, opts.apiKey, tmdbID, movie.Title, movie.Year, movie.Director, out)
+	if ctx.Err() != nil {
+		// Whatever was found may be incomplete; -resume looks it up again
+		fmt.Fprintf(out, "  ⏹️  Interrupted, page not updated\n")
+		return nil, nil, journal.Pending
+	}
+
+	change := &movieChange{}
+	if opts.updatePages && movie.FilePath != "" {
+		change.Path = movie.FilePath
+	}
+
+	if result == nil {
+		if opts.offline {
+			// Not cached; that doesn't mean TMDB doesn't know it
+			fmt.Fprintf(out, "  ⏭️  Not in the cache, skipped\n")
+			return nil, nil, journal.Failed
+		}
+		// Movie not found - mark as draft
+		fmt.Fprintf(out, "  ⚠ Movie not found, marking as draft\n")
+		if change.Path == "" {
+			return nil, nil, journal.Failed
+		}
+		draftData := MovieData{
+			Title: movie.Title,
+			Draft: true,
+		}
+		change.Update = func(content string) (string, error) {
+			return movieFrontmatter(content, draftData)
+		}
+		change.done = "Updated markdown file (marked as draft)"
+		return nil, change, journal.Failed
+	}
+
+	// Download poster
+	posterDownloaded := false
+	if change.Path != "" && pageLocks(change.Path, "img") {
+		fmt.Fprintf(out, "  🔒 img is locked, poster not downloaded\n")
+		posterDownloaded = true
+	} else if result.PosterPath != "" {
+		posterFile := getPosterFilename(movie.Title, result.Year, result.TMDBID)
+		poster := changes.File{Path: filepath.Join(opts.imagesDir, posterFile)}
+		if opts.dryRun {
+			posterDownloaded = true
+		} else {
+			poster.Staged = changes.StagedPath(poster.Path)
+			unlock := opts.locks.Lock(poster.Path)
+			err := downloadPoster(ctx, result.PosterURL, poster)
+			unlock()
+			if ctx.Err() != nil {
+				changes.Discard(&changes.Change{Files: []changes.File{poster}})
+				fmt.Fprintf(out, "  ⏹️  Interrupted, page not updated\n")
+				return nil, nil, journal.Pending
+			}
+			if err != nil {
+				// Leave the page alone so the next run tries again
+				fmt.Fprintf(out, "  ✗ Failed to download poster: %v (page not updated)\n", err)
+				return result, nil, journal.Failed
+			}
+			posterDownloaded = true
+			fmt.Fprintf(out, "  ✓ Downloaded poster: %s\n", posterFile)
+			if art, err := imageset.Analyze(poster.Current()); err != nil {
+				fmt.Fprintf(out, "  ⚠ No placeholder for poster: %v\n", err)
+			} else {
+				result.Artwork = art
+			}
+		}
+		result.ImagePath = fmt.Sprintf("/images/movies/%s", posterFile)
+		change.Files = append(change.Files, poster)
+	} else {
+		fmt.Fprintf(out, "  ⚠ No poster available\n")
+	}
+
+	// Mark as draft if no poster was downloaded
+	if !posterDownloaded {
+		result.Draft = true
+	}
+	result.Refreshed = opts.refreshStale && tmdbID != 0
+
+	// Update markdown file
+	data := *result
+	change.Update = func(content string) (string, error) {
+		return movieFrontmatter(content, data)
+	}
+	change.done = "Updated markdown file: " + filepath.Base(movie.FilePath)
+	if opts.refreshStale && change.Path != "" {
+		describeChanges(out, change.Path, change.Update)
+	}
+	return result, change, journal.Done
+}
+
+// movieStale tells whether a page's TMDB data is due for a refresh: it was
+// fetched more than opts.maxAge ago, or TMDB changed it since. It returns
+// why, and the TMDB ID in the page's tmdb link (0 if it has none).
+func movieStale(ctx context.Context, path string, opts fetchOptions) (bool, string, int) {
+	page, err := consumed.Load(path)
+	if err != nil {
+		return true, err.Error(), 0
+	}
+	id, _ := strconv.Atoi(artwork.ProviderID(page.String("tmdb")))
+	due, why := page.DueForRefresh(opts.maxAge, time.Now())
+	if due || id == 0 {
+		return due, why, id
+	}
+	changed, err := apiCache.Changed(movieDetailsRequest(ctx, opts.apiKey, id))
+	if err != nil {
+		return false, fmt.Sprintf("%s; could not ask TMDB: %v", why, secrets.Error(err)), id
+	}
+	if changed {
+		return true, "changed on TMDB", id
+	}
+	return false, why, id
+}
+
+// loadMovieInfo reads what a page already has, for movies taken from the
+// run journal. A page that can't be read is looked up by its title.
+func loadMovieInfo(path, title string) MovieInfo {
+	movie := MovieInfo{Title: title, FilePath: path}
+	page, err := consumed.Load(path)
+	if err != nil {
+		return movie
+	}
+	if t := page.String("title"); t != "" {
+		movie.Title = t
+	}
+	movie.Year = page.String("year")
+	movie.Director = page.String("director")
+	return movie
 }
 
+// getAPIKey returns the TMDB credential: the read access token in
+// TMDB_ACCESS_TOKEN or the API key in TMDB_API_KEY, each of which can also
+// come from a file or a command (see the secrets package)
 func getAPIKey() string {
 	// Try .env file first (before checking environment)
 	baseDir := getBaseDir()
@@ -219,14 +550,20 @@ func getAPIKey() string {
 	}
 
 	// Now check environment (either from .env or system)
-	apiKey := os.Getenv("TMDB_API_KEY")
-	if apiKey != "" {
-		return apiKey
+	for _, name := range []string{"TMDB_ACCESS_TOKEN", "TMDB_API_KEY"} {
+		apiKey, err := secrets.Lookup(name)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return ""
+		}
+		if apiKey != "" {
+			return apiKey
+		}
 	}
 
-	fmt.Println("Error: TMDB_API_KEY not found. Set it as environment variable or in .env file")
+	fmt.Println("Error: TMDB_ACCESS_TOKEN or TMDB_API_KEY not found. Set one as environment variable or in .env file")
 	fmt.Printf("  Looking for .env at: %s\n", envFile)
-	fmt.Println("Get your API key from: https://www.themoviedb.org/settings/api")
+	fmt.Println("Get your API read access token from: https://www.themoviedb.org/settings/api")
 	return ""
 }
 
@@ -258,22 +595,22 @@ func getBaseDir() string {
 	return startWd
 }
 
-func searchMovie(apiKey, title, year string) (*MovieResult, error) {
+func searchMovie(ctx context.Context, apiKey, title, year string) (*MovieResult, error) {
 	u := fmt.Sprintf("%s/search/movie", tmdbAPIBase)
-	req, _ := http.NewRequest("GET", u, nil)
+	req, _ := http.NewRequestWithContext(ctx, "GET", u, nil)
 	q := req.URL.Query()
-	q.Set("api_key", apiKey)
 	q.Set("query", title)
 	q.Set("language", "en-US")
 	if year != "" {
 		q.Set("year", year)
 	}
 	req.URL.RawQuery = q.Encode()
+	tmdbAuthorize(req, apiKey)
 
-	client := &http.Client{Timeout: 10 * time.Second}
+	client := &http.Client{Timeout: 10 * time.Second, Transport: apiCache}
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, secrets.Error(err)
 	}
 	defer resp.Body.Close()
 
@@ -288,19 +625,38 @@ func searchMovie(apiKey, title, year string) (*MovieResult, error) {
 	return nil, nil
 }
 
-func getMovieDetails(apiKey string, movieID int) (*MovieDetails, error) {
+// movieDetailsRequest is the request for a movie's details and credits
+func movieDetailsRequest(ctx context.Context, apiKey string, movieID int) *http.Request {
 	u := fmt.Sprintf("%s/movie/%d", tmdbAPIBase, movieID)
-	req, _ := http.NewRequest("GET", u, nil)
+	req, _ := http.NewRequestWithContext(ctx, "GET", u, nil)
 	q := req.URL.Query()
-	q.Set("api_key", apiKey)
 	q.Set("language", "en-US")
 	q.Set("append_to_response", "credits")
 	req.URL.RawQuery = q.Encode()
+	tmdbAuthorize(req, apiKey)
+	return req
+}
+
+// tmdbAuthorize adds a credential to a TMDB request. Read access tokens
+// (JWTs: header.payload.signature) go in the Authorization header. API
+// keys can only go in the query, where they end up in any printed URL.
+func tmdbAuthorize(req *http.Request, credential string) {
+	if strings.Count(credential, ".") == 2 {
+		req.Header.Set("Authorization", "Bearer "+credential)
+		return
+	}
+	q := req.URL.Query()
+	q.Set("api_key", credential)
+	req.URL.RawQuery = q.Encode()
+}
+
+func getMovieDetails(ctx context.Context, apiKey string, movieID int) (*MovieDetails, error) {
+	req := movieDetailsRequest(ctx, apiKey, movieID)
 
-	client := &http.Client{Timeout: 10 * time.Second}
+	client := &http.Client{Timeout: 10 * time.Second, Transport: apiCache}
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, secrets.Error(err)
 	}
 	defer resp.Body.Close()
 
@@ -308,22 +664,23 @@ func getMovieDetails(apiKey string, movieID int) (*MovieDetails, error) {
 	if err := json.NewDecoder(resp.Body).Decode(&details); err != nil {
 		return nil, err
 	}
+	details.Fetched = httpcache.Fetched(resp)
 
 	return &details, nil
 }
 
-func getMovieVideos(apiKey string, movieID int) (string, error) {
+func getMovieVideos(ctx context.Context, apiKey string, movieID int) (string, error) {
 	u := fmt.Sprintf("%s/movie/%d/videos", tmdbAPIBase, movieID)
-	req, _ := http.NewRequest("GET", u, nil)
+	req, _ := http.NewRequestWithContext(ctx, "GET", u, nil)
 	q := req.URL.Query()
-	q.Set("api_key", apiKey)
 	q.Set("language", "en-US")
 	req.URL.RawQuery = q.Encode()
+	tmdbAuthorize(req, apiKey)
 
-	client := &http.Client{Timeout: 10 * time.Second}
+	client := &http.Client{Timeout: 10 * time.Second, Transport: apiCache}
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", err
+		return "", secrets.Error(err)
 	}
 	defer resp.Body.Close()
 
@@ -358,79 +715,94 @@ func getDirector(credits Credits) string {
 	return ""
 }
 
-func downloadPoster(posterURL, outputPath string) bool {
-	if posterURL == "" {
-		return false
-	}
-
-	resp, err := http.Get(posterURL)
-	if err != nil {
-		return false
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return false
-	}
-
-	out, err := os.Create(outputPath)
-	if err != nil {
-		return false
+// downloadPoster saves a poster to poster.Staged, leaving it alone unless
+// a valid image arrived
+func downloadPoster(ctx context.Context, posterURL string, poster changes.File) error {
+	if apiCache.Offline {
+		// Artwork isn't cached; keep the poster we have
+		if _, err := os.Stat(poster.Path); err == nil {
+			os.Remove(poster.Staged)
+			return nil
+		}
 	}
-	defer out.Close()
-
-	_, err = io.Copy(out, resp.Body)
-	return err == nil
+	return artwork.Download(ctx, posterURL, poster.Staged)
 }
 
-func slugify(title string) string {
-	// Convert to lowercase and replace spaces/special chars with underscores
-	title = strings.ToLower(title)
-	re := regexp.MustCompile(`[^\w\s-]`)
-	title = re.ReplaceAllString(title, "")
-	re = regexp.MustCompile(`[-\s]+`)
-	title = re.ReplaceAllString(title, "_")
-	return strings.Trim(title, "_")
+// existingPoster returns where a movie's poster is: the file its page's
+// img names, or else the name a download would give it, from the year or
+// the page's TMDB ID
+func existingPoster(movie MovieInfo, imagesDir string) string {
+	tmdbID := 0
+	if movie.FilePath != "" {
+		if page, err := consumed.Load(movie.FilePath); err == nil {
+			if img := page.String("img"); img != "" {
+				return filepath.Join(imagesDir, filepath.Base(filepath.FromSlash(img)))
+			}
+			tmdbID, _ = strconv.Atoi(artwork.ProviderID(page.String("tmdb")))
+		}
+	}
+	return filepath.Join(imagesDir, getPosterFilename(movie.Title, movie.Year, tmdbID))
 }
 
-func getPosterFilename(title string) string {
-	return slugify(title) + "_poster.jpg"
+// getPosterFilename names a poster by title and year (or TMDB ID), so films
+// with the same title don't overwrite each other's poster
+func getPosterFilename(title, year string, tmdbID int) string {
+	id := ""
+	if tmdbID != 0 {
+		id = strconv.Itoa(tmdbID)
+	}
+	return artwork.Filename(artwork.Slug(title), artwork.Key(year, id), "poster", ".jpg")
 }
 
 // MovieInfo is now defined in markdown_helpers.go
 // Old parseConsumedToml function removed - use parseMarkdownFiles instead
 
-func processMovie(apiKey, title, year, existingDirector string) *MovieData {
-	fmt.Printf("\nProcessing: %s", title)
+// processMovie looks a movie up by its TMDB ID, or searches for it by
+// title and year when tmdbID is 0
+func processMovie(ctx context.Context, apiKey string, tmdbID int, title, year, existingDirector string, out io.Writer) *MovieData {
+	fmt.Fprintf(out, "\nProcessing: %s", title)
 	if year != "" {
-		fmt.Printf(" (%s)", year)
+		fmt.Fprintf(out, " (%s)", year)
 	}
-	fmt.Println()
+	fmt.Fprintln(out)
 
-	movie, err := searchMovie(apiKey, title, year)
-	if err != nil || movie == nil {
-		fmt.Printf("  ✗ Movie not found on TMDB\n")
-		return nil
-	}
+	var movie *MovieResult
+	if tmdbID == 0 {
+		var err error
+		movie, err = searchMovie(ctx, apiKey, title, year)
+		if err != nil || movie == nil {
+			fmt.Fprintf(out, "  ✗ Movie not found on TMDB\n")
+			return nil
+		}
 
-	fmt.Printf("  ✓ Found: %s", movie.Title)
-	if movie.ReleaseDate != "" && len(movie.ReleaseDate) >= 4 {
-		releaseYear := movie.ReleaseDate[:4]
-		fmt.Printf(" (%s)", releaseYear)
+		fmt.Fprintf(out, "  ✓ Found: %s", movie.Title)
+		if movie.ReleaseDate != "" && len(movie.ReleaseDate) >= 4 {
+			releaseYear := movie.ReleaseDate[:4]
+			fmt.Fprintf(out, " (%s)", releaseYear)
+		}
+		fmt.Fprintln(out)
+		tmdbID = movie.ID
 	}
-	fmt.Println()
 
-	details, err := getMovieDetails(apiKey, movie.ID)
+	details, err := getMovieDetails(ctx, apiKey, tmdbID)
 	if err != nil {
-		fmt.Printf("  ✗ Could not fetch movie details\n")
+		fmt.Fprintf(out, "  ✗ Could not fetch movie details\n")
 		return nil
 	}
+	if movie == nil {
+		movie = &MovieResult{ID: details.ID, Title: details.Title, ReleaseDate: details.ReleaseDate, PosterPath: details.PosterPath}
+		if movie.ID == 0 {
+			fmt.Fprintf(out, "  ✗ Movie %d not found on TMDB\n", tmdbID)
+			return nil
+		}
+		fmt.Fprintf(out, "  ✓ TMDB %d: %s\n", movie.ID, movie.Title)
+	}
 
 	director := existingDirector
 	if director == "" {
 		director = getDirector(details.Credits)
 		if director != "" {
-			fmt.Printf("  ✓ Director: %s\n", director)
+			fmt.Fprintf(out, "  ✓ Director: %s\n", director)
 		}
 	}
 
@@ -448,10 +820,10 @@ func processMovie(apiKey, title, year, existingDirector string) *MovieData {
 
 	// Fetch trailer
 	trailerURL := ""
-	trailer, err := getMovieVideos(apiKey, movie.ID)
+	trailer, err := getMovieVideos(ctx, apiKey, movie.ID)
 	if err == nil && trailer != "" {
 		trailerURL = trailer
-		fmt.Printf("  ✓ Trailer found\n")
+		fmt.Fprintf(out, "  ✓ Trailer found\n")
 	}
 
 	return &MovieData{
@@ -463,6 +835,11 @@ func processMovie(apiKey, title, year, existingDirector string) *MovieData {
 		TMDBID:     movie.ID,
 		TMDBURL:    tmdbURL,
 		TrailerURL: trailerURL,
+		Source: consumed.Source{
+			Provider: "tmdb",
+			ID:       strconv.Itoa(movie.ID),
+			Fetched:  details.Fetched,
+		},
 	}
 }
 
@@ -0,0 +1,171 @@
+package main
+
+// Golden tests of the TMDB lookups, against the recorded responses in
+// scripts/internal/fakeprovider/fixtures:
+//
+//	go test scripts/download_movie_metadata.go scripts/markdown_helpers.go scripts/download_movie_metadata_test.go
+//
+// Add -args -update to rewrite testdata/golden/movie after an intended
+// change.
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/fakeprovider"
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/golden"
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/httpcache"
+)
+
+// requestLog keeps the requests the fake server received
+type requestLog struct {
+	sync.Mutex
+	requests []*http.Request
+}
+
+func (l *requestLog) all() []*http.Request {
+	l.Lock()
+	defer l.Unlock()
+	return append([]*http.Request(nil), l.requests...)
+}
+
+// replayTMDB points the TMDB requests at the fixtures and returns the
+// server's URL, and the requests it gets
+func replayTMDB(t *testing.T) (string, *requestLog) {
+	t.Helper()
+	s, err := fakeprovider.New(fakeprovider.Fixtures)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := &requestLog{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log.Lock()
+		log.requests = append(log.requests, r.Clone(context.Background()))
+		log.Unlock()
+		s.ServeHTTP(w, r)
+	}))
+	api, images, cache := tmdbAPIBase, tmdbImageBase, apiCache
+	t.Cleanup(func() {
+		srv.Close()
+		tmdbAPIBase, tmdbImageBase, apiCache = api, images, cache
+	})
+
+	bases := fakeprovider.Bases(srv.URL)
+	tmdbAPIBase, tmdbImageBase = bases["TMDB_API_BASE"], bases["TMDB_IMAGE_BASE"]
+	apiCache = httpcache.New(t.TempDir())
+	return srv.URL, log
+}
+
+func TestProcessMovie(t *testing.T) {
+	serverURL, _ := replayTMDB(t)
+	tests := []struct {
+		name                  string
+		tmdbID                int
+		title, year, director string
+	}{
+		{name: "search", title: "Bunny", year: "2025"},
+		{name: "search-without-year", title: "Bunny"},
+		{name: "by-id", tmdbID: 1422004, title: "Bunny"},
+		{name: "keeps-director", title: "Bunny", year: "2025", director: "Someone Else"},
+		{name: "not-found", title: "No Such Movie", year: "1999"},
+		{name: "unknown-id", tmdbID: 1, title: "Bunny"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			data := processMovie(context.Background(), "test-key", tt.tmdbID, tt.title, tt.year, tt.director, &out)
+			report := golden.Report(out.String(), data, serverURL, "http://fakeprovider")
+			golden.Check(t, filepath.Join("testdata", "golden", "movie", tt.name+".txt"), report)
+		})
+	}
+}
+
+// TestCredentials checks where each kind of credential goes: read access
+// tokens in the Authorization header only, API keys in the query only
+func TestCredentials(t *testing.T) {
+	tests := []struct {
+		name, credential string
+		bearer           bool
+	}{
+		{name: "api-key", credential: "test-key"},
+		{name: "access-token", credential: "header.payload.signature", bearer: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, log := replayTMDB(t)
+			var out bytes.Buffer
+			if data := processMovie(context.Background(), tt.credential, 0, "Bunny", "2025", "", &out); data == nil {
+				t.Fatalf("lookup failed:\n%s", out.String())
+			}
+			requests := log.all()
+			if len(requests) == 0 {
+				t.Fatal("no requests reached the server")
+			}
+			for _, r := range requests {
+				auth := r.Header.Get("Authorization")
+				key, hasKey := r.URL.Query()["api_key"]
+				if tt.bearer {
+					if auth != "Bearer "+tt.credential {
+						t.Errorf("%s: Authorization = %q, want the bearer token", r.URL.Path, auth)
+					}
+					if hasKey {
+						t.Errorf("%s: api_key = %q, want none", r.URL.Path, key)
+					}
+					continue
+				}
+				if auth != "" {
+					t.Errorf("%s: Authorization = %q, want none", r.URL.Path, auth)
+				}
+				if r.URL.Query().Get("api_key") != tt.credential {
+					t.Errorf("%s: api_key = %q, want %q", r.URL.Path, key, tt.credential)
+				}
+			}
+		})
+	}
+}
+
+func TestExistingPoster(t *testing.T) {
+	dir := t.TempDir()
+	page := func(name, frontmatter string) string {
+		path := filepath.Join(dir, name+".md")
+		if err := os.WriteFile(path, []byte("+++\n"+frontmatter+"+++\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	tests := []struct {
+		name  string
+		movie MovieInfo
+		want  string
+	}{
+		{
+			name:  "img",
+			movie: MovieInfo{Title: "Bunny", Year: "2025", FilePath: page("img", "title = \"Bunny\"\nimg = \"/images/movies/renamed_poster.jpg\"\n")},
+			want:  "renamed_poster.jpg",
+		},
+		{
+			name:  "year",
+			movie: MovieInfo{Title: "Bunny", Year: "2025", FilePath: page("year", "title = \"Bunny\"\n")},
+			want:  "bunny_2025_poster.jpg",
+		},
+		{
+			name:  "tmdb-id",
+			movie: MovieInfo{Title: "Bunny", FilePath: page("tmdb", "title = \"Bunny\"\ntmdb = \"https://www.themoviedb.org/movie/1422004-bunny\"\n")},
+			want:  "bunny_1422004_poster.jpg",
+		},
+		{name: "no-page", movie: MovieInfo{Title: "Bunny"}, want: "bunny_poster.jpg"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := existingPoster(tt.movie, "images"); got != filepath.Join("images", tt.want) {
+				t.Errorf("existingPoster = %s, want %s", got, filepath.Join("images", tt.want))
+			}
+		})
+	}
+}
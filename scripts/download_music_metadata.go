@@ -1,6 +1,8 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
@@ -8,14 +10,25 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
-	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/artwork"
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/changes"
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/consumed"
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/fakeprovider"
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/httpcache"
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/imageset"
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/journal"
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/secrets"
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/workpool"
 	"github.com/joho/godotenv"
 )
 
-const discogsAPIBase = "https://api.discogs.com"
+// discogsAPIBase is the API base, which DISCOGS_API_BASE overrides (see
+// overrideBase)
+var discogsAPIBase = "https://api.discogs.com"
 
 type ReleaseResult struct {
 	ID    int    `json:"id"`
@@ -47,49 +60,132 @@ type Image struct {
 }
 
 type ReleaseDetails struct {
-	ID      int      `json:"id"`
-	Title   string   `json:"title"`
-	Year    int      `json:"year"`
-	Artists []Artist `json:"artists"`
-	Labels  []Label  `json:"labels"`
-	URI     string   `json:"uri"`
-	Images  []Image  `json:"images"`
+	ID      int       `json:"id"`
+	Title   string    `json:"title"`
+	Year    int       `json:"year"`
+	Artists []Artist  `json:"artists"`
+	Labels  []Label   `json:"labels"`
+	URI     string    `json:"uri"`
+	Images  []Image   `json:"images"`
+	Fetched time.Time `json:"-"` // when Discogs sent the release
 }
 
 // AlbumData and AlbumInfo are now defined in markdown_helpers.go
 
+// apiCache answers repeated Discogs requests from .cache/http
+var apiCache *httpcache.Cache
+
 func main() {
-	var updatePages, skipExisting, includeDrafts bool
+	var updatePages, skipExisting, includeDrafts, refresh, offline, resume, dryRun, interactive, refreshStale bool
+	var workers, maxAge int
+	var record, replay string
 	flag.BoolVar(&updatePages, "update-pages", true, "Update markdown pages with fetched metadata (default: true)")
 	flag.BoolVar(&skipExisting, "skip-existing", false, "Skip albums that already have all metadata")
 	flag.BoolVar(&includeDrafts, "include-drafts", false, "Include draft albums when processing")
+	flag.BoolVar(&refresh, "refresh", false, "Ignore cached Discogs responses and fetch them again")
+	flag.BoolVar(&offline, "offline", false, "Only use cached Discogs responses, never the network")
+	flag.IntVar(&workers, "workers", workpool.Workers, "Number of albums to process at once")
+	flag.BoolVar(&resume, "resume", false, "Continue the last run from the first album it didn't finish")
+	flag.BoolVar(&dryRun, "dry-run", false, "Show the changes to each page as a diff without writing anything")
+	flag.BoolVar(&interactive, "interactive", false, "Show the changes to each page and ask before applying them")
+	flag.BoolVar(&refreshStale, "refresh-stale", false, "Look up processed albums again whose data is older than -max-age or changed on Discogs")
+	flag.IntVar(&maxAge, "max-age", 180, "Days after which -refresh-stale looks an album up again")
+	flag.StringVar(&record, "record", "", "Save the Discogs responses of this run as fixtures in a directory, without the token")
+	flag.StringVar(&replay, "replay", "", "Answer Discogs requests from the fixtures in a directory, never the network")
 	flag.Parse()
 
-	token := getUserToken()
-	if token == "" {
+	if dryRun && interactive {
+		fmt.Println("Error: -dry-run and -interactive can't be combined")
+		os.Exit(1)
+	}
+
+	if record != "" && (replay != "" || offline) {
+		fmt.Println("Error: -record fetches from Discogs and can't be combined with -replay or -offline")
+		os.Exit(1)
+	}
+
+	// Replays need no token
+	token := "replay"
+	var fake *fakeprovider.Server
+	if replay != "" {
+		var stop func()
+		var err error
+		fake, stop, err = fakeprovider.Replay(replay)
+		if err != nil {
+			fmt.Printf("Error loading fixtures: %v\n", err)
+			os.Exit(1)
+		}
+		defer stop()
+	} else if token = getUserToken(); token == "" {
 		os.Exit(1)
 	}
+	overrideBase(&discogsAPIBase, "DISCOGS_API_BASE")
 
 	baseDir := getBaseDir()
 	imagesDir := filepath.Join(baseDir, "static", "images", "music")
+	apiCache = httpcache.New(baseDir)
+	apiCache.Refresh, apiCache.Offline, apiCache.NoStore = refresh, offline, dryRun
+	apiCache.Transport = workpool.NewTransport(http.DefaultTransport, workpool.Limits)
+	var recorder *fakeprovider.Recorder
+	if record != "" {
+		// Fetch every response, so every one is recorded
+		recorder = &fakeprovider.Recorder{Dir: record, Transport: apiCache.Transport}
+		apiCache.Transport, apiCache.Refresh = recorder, true
+	}
+	artwork.Client.Transport = apiCache
 	contentDir := filepath.Join(baseDir, "content")
 	
-	os.MkdirAll(imagesDir, 0755)
+	if !dryRun {
+		os.MkdirAll(imagesDir, 0755)
+	}
 
 	// Get albums to process
 	var albums []AlbumInfo
-	if flag.NArg() > 0 {
+	var run *journal.Journal
+	if resume {
+		if flag.NArg() > 0 {
+			fmt.Println("Error: -resume continues the last run and takes no titles")
+			os.Exit(1)
+		}
+		var err error
+		run, err = journal.Latest(baseDir, "music")
+		if err != nil {
+			fmt.Printf("Nothing to resume: %v\n", err)
+			os.Exit(1)
+		}
+		if run.Finished {
+			fmt.Printf("Run %s finished, nothing to resume\n", run.ID)
+			os.Exit(0)
+		}
+		for _, e := range run.Pending() {
+			if path, ok := e.Page(baseDir); ok {
+				albums = append(albums, loadAlbumInfo(path, e.Title))
+			} else {
+				albums = append(albums, AlbumInfo{Title: e.Title})
+			}
+		}
+		fmt.Printf("Resuming run %s: %d of %d albums left\n", run.ID, len(albums), len(run.Entries))
+	} else if flag.NArg() > 0 {
 		// If titles provided as args, find corresponding markdown files
 		for _, title := range flag.Args() {
-			// Try to find the file by title
-			slug := slugify(title)
-			filePath := filepath.Join(contentDir, "consumed", "music", slug+".md")
-			if _, err := os.Stat(filePath); err == nil {
+			// Try to find the file by slug or title
+			if filePath, ok := findPageFile(contentDir, "music", title); ok && refreshStale {
+				albums = append(albums, loadAlbumInfo(filePath, title))
+			} else if ok {
 				albums = append(albums, AlbumInfo{Title: title, FilePath: filePath})
 			} else {
 				fmt.Printf("Warning: Could not find file for album: %s\n", title)
 			}
 		}
+	} else if refreshStale {
+		paths, err := processedPages(contentDir, "music", includeDrafts)
+		if err != nil {
+			fmt.Printf("Error reading markdown files: %v\n", err)
+			os.Exit(1)
+		}
+		for _, path := range paths {
+			albums = append(albums, loadAlbumInfo(path, strings.TrimSuffix(filepath.Base(path), ".md")))
+		}
 	} else {
 		var err error
 		albums, err = parseMarkdownMusicFiles(contentDir, includeDrafts)
@@ -106,42 +202,62 @@ func main() {
 
 	fmt.Printf("Found %d albums to process\n\n", len(albums))
 
-	results := make(map[string]AlbumData)
-
-	for _, album := range albums {
-		if skipExisting && album.Artist != "" && album.Year != "" && album.Label != "" {
-			fmt.Printf("\nSkipping %s (already has all metadata)\n", album.Title)
-			continue
+	if run == nil && !dryRun {
+		entries := make([]journal.Entry, len(albums))
+		for i, album := range albums {
+			entries[i] = journal.Entry{Key: journal.Key(baseDir, album.FilePath, album.Title), Title: album.Title}
 		}
+		var err error
+		if run, err = journal.New(baseDir, "music", entries); err != nil {
+			fmt.Printf("Error starting the run journal: %v\n", err)
+			os.Exit(1)
+		}
+	}
 
-		result := processAlbum(token, album.Title, album.Artist, album.Year)
-		if result != nil {
-			results[album.Title] = *result
-
-			// Download cover image
-			if result.CoverURL != "" {
-				coverFile := getCoverFilename(album.Title)
-				coverPath := filepath.Join(imagesDir, coverFile)
-				if downloadCover(result.CoverURL, coverPath) {
-					result.CoverPath = fmt.Sprintf("/images/music/%s", coverFile)
-					fmt.Printf("  ✓ Downloaded cover: %s\n", coverFile)
-				}
+	ctx, stop := context.WithCancel(workpool.Interruptible())
+	defer stop()
+	results := make(map[string]AlbumData)
+	reviewer := &changes.Reviewer{DryRun: dryRun, Interactive: interactive, BaseDir: baseDir}
+	if run != nil && !dryRun {
+		reviewer.Recorder = run
+	}
+	opts := fetchOptions{
+		token:        token,
+		imagesDir:    imagesDir,
+		skipExisting: skipExisting,
+		updatePages:  updatePages,
+		offline:      offline,
+		dryRun:       dryRun,
+		refreshStale: refreshStale,
+		maxAge:       time.Duration(maxAge) * 24 * time.Hour,
+		locks:        &workpool.Locks{},
+	}
+	workpool.Run(ctx, len(albums), workers, func(i int) albumOutcome {
+		var out bytes.Buffer
+		result, change, status := fetchAlbum(ctx, albums[i], opts, &out)
+		return albumOutcome{result: result, change: change, status: status, log: out.String()}
+	}, func(i int, o albumOutcome) {
+		fmt.Print(o.log)
+		if o.result != nil {
+			results[albums[i].Title] = *o.result
+		}
+		status := o.status
+		if o.change != nil {
+			status = applyChange(reviewer, o.change, status)
+			if reviewer.Stopped() {
+				stop()
 			}
-
-			// Update markdown file
-			if updatePages && album.FilePath != "" {
-				if err := updateMarkdownMusicFrontmatter(album.FilePath, *result); err != nil {
-					fmt.Printf("  ✗ Error updating markdown file: %v\n", err)
-				} else {
-					fmt.Printf("  ✓ Updated markdown file: %s\n", filepath.Base(album.FilePath))
-				}
+		}
+		if run != nil && !dryRun {
+			if err := run.Mark(journal.Key(baseDir, albums[i].FilePath, albums[i].Title), status); err != nil {
+				fmt.Printf("  ⚠ Could not update the run journal: %v\n", err)
 			}
-		} else {
-			fmt.Printf("  ⚠ Album not found\n")
 		}
-
-		// Rate limiting
-		time.Sleep(1 * time.Second)
+	})
+	if run != nil && !dryRun {
+		if err := run.Finish(); err != nil {
+			fmt.Printf("⚠ Could not update the run journal: %v\n", err)
+		}
 	}
 
 	// Summary
@@ -165,8 +281,212 @@ func main() {
 	fmt.Printf("  Artists found: %d\n", artistCount)
 	fmt.Printf("  Years found: %d\n", yearCount)
 	fmt.Printf("  Labels found: %d\n", labelCount)
+	hits, fetched := apiCache.Stats()
+	fmt.Printf("  API cache: %d hit(s), %d fetched\n", hits, fetched)
+	if recorder != nil {
+		fmt.Printf("  Recorded: %d response(s) in %s\n", recorder.Recorded(), record)
+	}
+	if fake != nil {
+		for _, u := range fake.Missing() {
+			fmt.Printf("  ⚠ No fixture for %s\n", u)
+		}
+	}
+	if dryRun {
+		fmt.Printf("  Dry run: nothing was written\n")
+		return
+	}
+	if left := len(run.Pending()); left > 0 {
+		if reviewer.Stopped() {
+			fmt.Printf("  Stopped: %d album(s) left, continue with -resume (run %s)\n", left, run.ID)
+			return
+		}
+		fmt.Printf("  Interrupted: %d album(s) left, continue with -resume (run %s)\n", left, run.ID)
+		os.Exit(130)
+	}
+}
+
+// applyChange applies (or shows) an album's change once it is reported and
+// returns the album's journal status. A change rejected with "q" leaves
+// the album pending for -resume.
+func applyChange(reviewer *changes.Reviewer, change *changes.Change, status string) string {
+	if reviewer.Stopped() {
+		changes.Discard(change)
+		return journal.Pending
+	}
+	applied, err := reviewer.Apply(change)
+	if err != nil {
+		fmt.Printf("  ✗ Error updating markdown file: %v\n", err)
+		return journal.Failed
+	}
+	if applied && change.Path != "" {
+		fmt.Printf("  ✓ Updated markdown file: %s\n", filepath.Base(change.Path))
+	}
+	if reviewer.Stopped() {
+		return journal.Pending
+	}
+	return status
+}
+
+// fetchOptions are the settings every worker shares
+type fetchOptions struct {
+	token        string
+	imagesDir    string
+	skipExisting bool
+	updatePages  bool
+	offline      bool
+	dryRun       bool
+	refreshStale bool          // only stale processed albums, from Discogs' data
+	maxAge       time.Duration // for refreshStale
+	locks        *workpool.Locks
+}
+
+// albumOutcome is what a worker hands back for reporting
+type albumOutcome struct {
+	result *AlbumData
+	change *changes.Change // nil if there's nothing to write
+	status string          // journal status of the album
+	log    string
 }
 
+// fetchAlbum looks up one album and downloads its cover next to where it
+// goes, writing progress to out. It returns the Discogs data, or nil if the
+// album was skipped or not found, the change to its page and cover, and the
+// album's journal status: pending if ctx was cancelled, in which case there
+// is no change.
+func fetchAlbum(ctx context.Context, album AlbumInfo, opts fetchOptions, out io.Writer) (*AlbumData, *changes.Change, string) {
+	releaseID := 0
+	if opts.refreshStale {
+		stale, why, id := albumStale(ctx, album.FilePath, opts)
+		if !stale {
+			fmt.Fprintf(out, "\nUp to date: %s (%s)\n", album.Title, why)
+			return nil, nil, journal.Done
+		}
+		fmt.Fprintf(out, "\nRefreshing: %s (%s)\n", album.Title, why)
+		ctx = httpcache.WithRefresh(ctx)
+		// Take Discogs' values, except where the page locks them. The
+		// artist only narrows the search when there's no release ID.
+		releaseID, album.Year = id, ""
+		if id != 0 {
+			album.Artist = ""
+		}
+	} else if opts.skipExisting && album.Artist != "" && album.Year != "" && album.Label != "" {
+		fmt.Fprintf(out, "\nSkipping %s (already has all metadata)\n", album.Title)
+		return nil, nil, journal.Done
+	}
+
+	result := processAlbum(ctx, opts.token, releaseID, album.Title, album.Artist, album.Year, out)
+	if ctx.Err() != nil {
+		// Whatever was found may be incomplete; -resume looks it up again
+		fmt.Fprintf(out, "  ⏹️  Interrupted, page not updated\n")
+		return nil, nil, journal.Pending
+	}
+	if result == nil {
+		if opts.offline {
+			fmt.Fprintf(out, "  ⏭️  Not in the cache, skipped\n")
+		} else {
+			fmt.Fprintf(out, "  ⚠ Album not found\n")
+		}
+		return nil, nil, journal.Failed
+	}
+
+	change := &changes.Change{}
+
+	// Download cover image
+	if album.FilePath != "" && pageLocks(album.FilePath, "img") {
+		fmt.Fprintf(out, "  🔒 img is locked, cover not downloaded\n")
+	} else if result.CoverURL != "" {
+		coverFile := getCoverFilename(album.Title, result.Year, result.DiscogsID)
+		cover := changes.File{Path: filepath.Join(opts.imagesDir, coverFile)}
+		if !opts.dryRun {
+			cover.Staged = changes.StagedPath(cover.Path)
+			unlock := opts.locks.Lock(cover.Path)
+			err := downloadCover(ctx, result.CoverURL, cover)
+			unlock()
+			if ctx.Err() != nil {
+				changes.Discard(&changes.Change{Files: []changes.File{cover}})
+				fmt.Fprintf(out, "  ⏹️  Interrupted, page not updated\n")
+				return nil, nil, journal.Pending
+			}
+			if err != nil {
+				// Leave the page alone so the next run tries again
+				fmt.Fprintf(out, "  ✗ Failed to download cover: %v (page not updated)\n", err)
+				return result, nil, journal.Failed
+			}
+			fmt.Fprintf(out, "  ✓ Downloaded cover: %s\n", coverFile)
+			if art, err := imageset.Analyze(cover.Current()); err != nil {
+				fmt.Fprintf(out, "  ⚠ No placeholder for cover: %v\n", err)
+			} else {
+				result.Artwork = art
+			}
+		}
+		result.CoverPath = fmt.Sprintf("/images/music/%s", coverFile)
+		change.Files = append(change.Files, cover)
+	}
+
+	// Update markdown file
+	result.Refreshed = opts.refreshStale && releaseID != 0
+	if opts.updatePages && album.FilePath != "" {
+		data := *result
+		change.Path = album.FilePath
+		change.Update = func(content string) (string, error) {
+			return musicFrontmatter(content, data)
+		}
+		if opts.refreshStale {
+			describeChanges(out, change.Path, change.Update)
+		}
+	}
+	if change.Path == "" && len(change.Files) == 0 {
+		return result, nil, journal.Done
+	}
+	return result, change, journal.Done
+}
+
+// albumStale tells whether a page's Discogs data is due for a refresh: it
+// was fetched more than opts.maxAge ago, or Discogs changed it since. It
+// returns why, and the release ID in the page's discogs link (0 if it links
+// to something else or nothing).
+func albumStale(ctx context.Context, path string, opts fetchOptions) (bool, string, int) {
+	page, err := consumed.Load(path)
+	if err != nil {
+		return true, err.Error(), 0
+	}
+	id := 0
+	if link := page.String("discogs"); strings.Contains(link, "/release/") {
+		id, _ = strconv.Atoi(artwork.ProviderID(link))
+	}
+	due, why := page.DueForRefresh(opts.maxAge, time.Now())
+	if due || id == 0 {
+		return due, why, id
+	}
+	changed, err := apiCache.Changed(releaseRequest(ctx, opts.token, id))
+	if err != nil {
+		return false, fmt.Sprintf("%s; could not ask Discogs: %v", why, secrets.Error(err)), id
+	}
+	if changed {
+		return true, "changed on Discogs", id
+	}
+	return false, why, id
+}
+
+// loadAlbumInfo reads what a page already has, for albums taken from the
+// run journal. A page that can't be read is looked up by its title.
+func loadAlbumInfo(path, title string) AlbumInfo {
+	album := AlbumInfo{Title: title, FilePath: path}
+	page, err := consumed.Load(path)
+	if err != nil {
+		return album
+	}
+	if t := page.String("title"); t != "" {
+		album.Title = t
+	}
+	album.Artist = page.String("artist")
+	album.Year = page.String("year")
+	album.Label = page.String("label")
+	return album
+}
+
+// getUserToken returns the Discogs token in DISCOGS_USER_TOKEN, which can
+// also come from a file or a command (see the secrets package)
 func getUserToken() string {
 	// Try .env file first (before checking environment)
 	baseDir := getBaseDir()
@@ -178,7 +498,11 @@ func getUserToken() string {
 	}
 
 	// Now check environment (either from .env or system)
-	token := os.Getenv("DISCOGS_USER_TOKEN")
+	token, err := secrets.Lookup("DISCOGS_USER_TOKEN")
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return ""
+	}
 	if token != "" {
 		return token
 	}
@@ -218,9 +542,9 @@ func getBaseDir() string {
 	return startWd
 }
 
-func searchAlbum(token, title, artist string) (*ReleaseResult, error) {
+func searchAlbum(ctx context.Context, token, title, artist string) (*ReleaseResult, error) {
 	u := fmt.Sprintf("%s/database/search", discogsAPIBase)
-	req, _ := http.NewRequest("GET", u, nil)
+	req, _ := http.NewRequestWithContext(ctx, "GET", u, nil)
 	req.Header.Set("User-Agent", "HugoSite/1.0")
 	req.Header.Set("Authorization", fmt.Sprintf("Discogs token=%s", token))
 
@@ -236,10 +560,10 @@ func searchAlbum(token, title, artist string) (*ReleaseResult, error) {
 	q.Set("per_page", "25") // Increase results to find better matches
 	req.URL.RawQuery = q.Encode()
 
-	client := &http.Client{Timeout: 10 * time.Second}
+	client := &http.Client{Timeout: 10 * time.Second, Transport: apiCache}
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, secrets.Error(err)
 	}
 	defer resp.Body.Close()
 
@@ -282,16 +606,22 @@ func searchAlbum(token, title, artist string) (*ReleaseResult, error) {
 	return nil, nil
 }
 
-func getReleaseDetails(token string, releaseID int) (*ReleaseDetails, error) {
+// releaseRequest is the request for a release's details
+func releaseRequest(ctx context.Context, token string, releaseID int) *http.Request {
 	u := fmt.Sprintf("%s/releases/%d", discogsAPIBase, releaseID)
-	req, _ := http.NewRequest("GET", u, nil)
+	req, _ := http.NewRequestWithContext(ctx, "GET", u, nil)
 	req.Header.Set("User-Agent", "HugoSite/1.0")
 	req.Header.Set("Authorization", fmt.Sprintf("Discogs token=%s", token))
+	return req
+}
 
-	client := &http.Client{Timeout: 10 * time.Second}
+func getReleaseDetails(ctx context.Context, token string, releaseID int) (*ReleaseDetails, error) {
+	req := releaseRequest(ctx, token, releaseID)
+
+	client := &http.Client{Timeout: 10 * time.Second, Transport: apiCache}
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, secrets.Error(err)
 	}
 	defer resp.Body.Close()
 
@@ -299,32 +629,48 @@ func getReleaseDetails(token string, releaseID int) (*ReleaseDetails, error) {
 	if err := json.NewDecoder(resp.Body).Decode(&details); err != nil {
 		return nil, err
 	}
+	details.Fetched = httpcache.Fetched(resp)
 
 	return &details, nil
 }
 
-func processAlbum(token, title, existingArtist, existingYear string) *AlbumData {
-	fmt.Printf("\nProcessing: %s\n", title)
+// processAlbum looks an album up by its Discogs release ID, or searches
+// for it by title and artist when releaseID is 0
+func processAlbum(ctx context.Context, token string, releaseID int, title, existingArtist, existingYear string, out io.Writer) *AlbumData {
+	fmt.Fprintf(out, "\nProcessing: %s\n", title)
 
-	album, err := searchAlbum(token, title, existingArtist)
-	if err != nil || album == nil {
-		fmt.Printf("  ✗ Album not found on Discogs\n")
-		return nil
-	}
+	var album *ReleaseResult
+	if releaseID == 0 {
+		var err error
+		album, err = searchAlbum(ctx, token, title, existingArtist)
+		if err != nil || album == nil {
+			fmt.Fprintf(out, "  ✗ Album not found on Discogs\n")
+			return nil
+		}
 
-	fmt.Printf("  ✓ Found: %s\n", album.Title)
+		fmt.Fprintf(out, "  ✓ Found: %s\n", album.Title)
+		releaseID = album.ID
+	}
 
-	details, err := getReleaseDetails(token, album.ID)
+	details, err := getReleaseDetails(ctx, token, releaseID)
 	if err != nil {
-		fmt.Printf("  ✗ Could not fetch release details\n")
+		fmt.Fprintf(out, "  ✗ Could not fetch release details\n")
 		return nil
 	}
+	if album == nil {
+		if details.ID == 0 {
+			fmt.Fprintf(out, "  ✗ Release %d not found on Discogs\n", releaseID)
+			return nil
+		}
+		album = &ReleaseResult{ID: details.ID, Title: details.Title}
+		fmt.Fprintf(out, "  ✓ Discogs release %d: %s\n", album.ID, album.Title)
+	}
 
 	artist := existingArtist
 	if artist == "" && len(details.Artists) > 0 {
 		artist = details.Artists[0].Name
 		if artist != "" {
-			fmt.Printf("  ✓ Artist: %s\n", artist)
+			fmt.Fprintf(out, "  ✓ Artist: %s\n", artist)
 		}
 	}
 
@@ -332,7 +678,7 @@ func processAlbum(token, title, existingArtist, existingYear string) *AlbumData
 	if year == "" && details.Year > 0 {
 		year = fmt.Sprintf("%d", details.Year)
 		if year != "" {
-			fmt.Printf("  ✓ Year: %s\n", year)
+			fmt.Fprintf(out, "  ✓ Year: %s\n", year)
 		}
 	}
 
@@ -341,12 +687,12 @@ func processAlbum(token, title, existingArtist, existingYear string) *AlbumData
 	if len(details.Labels) > 0 {
 		label = details.Labels[0].Name
 		if label != "" {
-			fmt.Printf("  ✓ Label: %s\n", label)
+			fmt.Fprintf(out, "  ✓ Label: %s\n", label)
 		}
 		// Construct label URL from label ID
 		if details.Labels[0].ID > 0 {
 			labelURL = fmt.Sprintf("https://www.discogs.com/label/%d", details.Labels[0].ID)
-			fmt.Printf("  ✓ Label URL: %s\n", labelURL)
+			fmt.Fprintf(out, "  ✓ Label URL: %s\n", labelURL)
 		} else if details.Labels[0].ResourceURL != "" {
 			// Fallback: try to extract ID from resource_url
 			// Resource URL format: https://api.discogs.com/labels/{id}
@@ -355,7 +701,7 @@ func processAlbum(token, title, existingArtist, existingYear string) *AlbumData
 				if len(parts) > 1 {
 					labelID := strings.TrimSuffix(parts[1], "/")
 					labelURL = fmt.Sprintf("https://www.discogs.com/label/%s", labelID)
-					fmt.Printf("  ✓ Label URL: %s\n", labelURL)
+					fmt.Fprintf(out, "  ✓ Label URL: %s\n", labelURL)
 				}
 			}
 		}
@@ -398,48 +744,37 @@ func processAlbum(token, title, existingArtist, existingYear string) *AlbumData
 		DiscogsURL: discogsURL,
 		DiscogsID:  details.ID,
 		CoverURL:   coverURL,
+		Source: consumed.Source{
+			Provider: "discogs",
+			ID:       strconv.Itoa(details.ID),
+			Fetched:  details.Fetched,
+		},
 	}
 }
 
 // Old parseConsumedToml function removed - use parseMarkdownMusicFiles instead
 
-func slugify(title string) string {
-	// Convert to lowercase and replace spaces/special chars with underscores
-	title = strings.ToLower(title)
-	re := regexp.MustCompile(`[^\w\s-]`)
-	title = re.ReplaceAllString(title, "")
-	re = regexp.MustCompile(`[-\s]+`)
-	title = re.ReplaceAllString(title, "_")
-	return strings.Trim(title, "_")
-}
-
-func getCoverFilename(title string) string {
-	return slugify(title) + "_cover.jpg"
-}
-
-func downloadCover(coverURL, outputPath string) bool {
-	if coverURL == "" {
-		return false
-	}
-
-	resp, err := http.Get(coverURL)
-	if err != nil {
-		return false
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return false
+// getCoverFilename names a cover by title and year (or Discogs ID), so
+// albums with the same title don't overwrite each other's cover
+func getCoverFilename(title, year string, discogsID int) string {
+	id := ""
+	if discogsID != 0 {
+		id = strconv.Itoa(discogsID)
 	}
+	return artwork.Filename(artwork.Slug(title), artwork.Key(year, id), "cover", ".jpg")
+}
 
-	out, err := os.Create(outputPath)
-	if err != nil {
-		return false
+// downloadCover saves a cover to cover.Staged, leaving it alone unless a
+// valid image arrived
+func downloadCover(ctx context.Context, coverURL string, cover changes.File) error {
+	if apiCache.Offline {
+		// Artwork isn't cached; keep the cover we have
+		if _, err := os.Stat(cover.Path); err == nil {
+			os.Remove(cover.Staged)
+			return nil
+		}
 	}
-	defer out.Close()
-
-	_, err = io.Copy(out, resp.Body)
-	return err == nil
+	return artwork.Download(ctx, coverURL, cover.Staged)
 }
 
 // Old updateConsumedToml function removed - use updateMarkdownMusicFrontmatter instead
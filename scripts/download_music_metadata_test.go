@@ -0,0 +1,64 @@
+package main
+
+// Golden tests of the Discogs lookups, against the recorded responses in
+// scripts/internal/fakeprovider/fixtures:
+//
+//	go test scripts/download_music_metadata.go scripts/markdown_helpers.go scripts/download_music_metadata_test.go
+//
+// Add -args -update to rewrite testdata/golden/music after an intended
+// change.
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/fakeprovider"
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/golden"
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/httpcache"
+)
+
+// replayDiscogs points the Discogs requests at the fixtures and returns
+// the server's URL
+func replayDiscogs(t *testing.T) string {
+	t.Helper()
+	s, err := fakeprovider.New(fakeprovider.Fixtures)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(s)
+	api, cache := discogsAPIBase, apiCache
+	t.Cleanup(func() {
+		srv.Close()
+		discogsAPIBase, apiCache = api, cache
+	})
+
+	discogsAPIBase = fakeprovider.Bases(srv.URL)["DISCOGS_API_BASE"]
+	apiCache = httpcache.New(t.TempDir())
+	return srv.URL
+}
+
+func TestProcessAlbum(t *testing.T) {
+	serverURL := replayDiscogs(t)
+	tests := []struct {
+		name                string
+		releaseID           int
+		title, artist, year string
+	}{
+		{name: "search", title: "768", artist: "Kryptic Minds"},
+		{name: "by-id", releaseID: 1941316, title: "768"},
+		{name: "keeps-artist-and-year", releaseID: 1941316, title: "768", artist: "Kryptic Minds", year: "2010"},
+		{name: "not-found", title: "No Such Album", artist: "Nobody"},
+		{name: "unknown-id", releaseID: 1, title: "768"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			data := processAlbum(context.Background(), "test-token", tt.releaseID, tt.title, tt.artist, tt.year, &out)
+			report := golden.Report(out.String(), data, serverURL, "http://fakeprovider")
+			golden.Check(t, filepath.Join("testdata", "golden", "music", tt.name+".txt"), report)
+		})
+	}
+}
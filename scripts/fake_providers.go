@@ -0,0 +1,79 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/fakeprovider"
+)
+
+// Local stand-in for TMDB, Discogs, Google Books and Open Library. Serves
+// recorded responses (the built-in fixtures, or the .json files under
+// -fixtures) and generated artwork, and prints the environment variables
+// that point the download scripts at it.
+//
+// Usage:
+//
+//	go run scripts/fake_providers.go [-addr 127.0.0.1:8089] [-fixtures dir]
+//
+// Then, in another shell:
+//
+//	eval "$(go run scripts/fake_providers.go -env)"
+//	TMDB_API_KEY=fake go run scripts/download_movie_metadata.go Bunny
+
+func main() {
+	var addr, fixtures string
+	var envOnly bool
+	flag.StringVar(&addr, "addr", "127.0.0.1:8089", "Address to listen on")
+	flag.StringVar(&fixtures, "fixtures", "", "Directory of recorded responses (default: the built-in ones)")
+	flag.BoolVar(&envOnly, "env", false, "Only print the environment variables for a server at -addr")
+	flag.Parse()
+
+	serverURL := "http://" + addr
+	if envOnly {
+		printEnv(serverURL)
+		return
+	}
+
+	var fsys fs.FS = fakeprovider.Fixtures
+	if fixtures != "" {
+		fsys = os.DirFS(fixtures)
+	}
+	server, err := fakeprovider.New(fsys)
+	if err != nil {
+		fmt.Printf("❌ Loading fixtures: %v\n", err)
+		os.Exit(1)
+	}
+	server.Log = os.Stdout
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Serving %d fixture(s) on %s\n\n", server.Len(), serverURL)
+	printEnv(serverURL)
+	fmt.Println()
+	if err := http.Serve(listener, server); err != nil {
+		fmt.Printf("❌ %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// printEnv prints the exports pointing the scripts at a server
+func printEnv(serverURL string) {
+	bases := fakeprovider.Bases(serverURL)
+	var names []string
+	for name := range bases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("export %s=%s\n", name, bases[name])
+	}
+}
@@ -0,0 +1,429 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/consumed"
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/dither"
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/imageset"
+)
+
+// Orphaned and missing image report. Cross-references every image used by
+// content/ (img/image fields, screenshots, shortcode src parameters,
+// markdown images and gallery data files) against the artwork and
+// screenshot directories, and lists images nothing uses and references to
+// files that don't exist.
+//
+// Usage:
+//
+//	go run scripts/image_report.go [directory...] [--delete-orphans [--yes]] [--refetch-missing]
+//
+// Directories default to static/images/{movies,music,books} and
+// assets/screenshots. Dithered copies, responsive variants and dither
+// sidecars go with their original. Images named in templates count as
+// used, and orphans under assets/ are only reported, never deleted, since
+// templates and shortcodes can build their paths for resources.Get. Exits
+// with status 1 while references are missing.
+
+// artworkDirs are the directories checked for orphans by default
+var artworkDirs = []string{
+	"static/images/movies",
+	"static/images/music",
+	"static/images/books",
+	"assets/screenshots",
+}
+
+// keptDirs hold images that templates and shortcodes can reach by a path
+// they build (resources.Get), which the report can't see: their orphans are
+// reported but not deleted
+var keptDirs = []string{"assets"}
+
+// downloadScripts re-fetch the artwork of a kind of page, given titles
+var downloadScripts = map[string][]string{
+	"movie": {"scripts/download_movie_metadata.go", "scripts/markdown_helpers.go"},
+	"music": {"scripts/download_music_metadata.go", "scripts/markdown_helpers.go"},
+	"book":  {"scripts/download_book_metadata.go"},
+}
+
+func main() {
+	var deleteOrphans, yes, refetchMissing bool
+	flag.BoolVar(&deleteOrphans, "delete-orphans", false, "Delete orphaned images and their dithered copies and variants, after asking")
+	flag.BoolVar(&yes, "yes", false, "Delete orphans without asking")
+	flag.BoolVar(&refetchMissing, "refetch-missing", false, "Download missing movie posters, album covers and book covers again")
+	args := parseArgs(flag.CommandLine, os.Args[1:])
+
+	baseDir := getBaseDir()
+	dirs := args
+	if len(dirs) == 0 {
+		for _, dir := range artworkDirs {
+			dirs = append(dirs, filepath.Join(baseDir, dir))
+		}
+	}
+
+	refs, err := imageset.FindRefs(baseDir)
+	if err != nil {
+		fmt.Printf("❌ Reading content: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Every file some reference could mean counts as used
+	used := make(map[string]bool)
+	var missing []imageset.Ref
+	for _, ref := range refs {
+		if _, ok := ref.Resolve(baseDir); !ok {
+			missing = append(missing, ref)
+		}
+		for _, candidate := range ref.Candidates(baseDir) {
+			if abs, err := filepath.Abs(candidate); err == nil {
+				used[abs] = true
+			}
+		}
+	}
+
+	if err := templateRefs(baseDir, used); err != nil {
+		fmt.Printf("❌ Reading templates: %v\n", err)
+		os.Exit(1)
+	}
+
+	orphans, err := findOrphans(dirs, used)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("\n🔍 Checked %d image reference(s) against %d director(ies)\n\n", len(refs), len(dirs))
+
+	if len(missing) > 0 {
+		fmt.Printf("Missing (%d):\n", len(missing))
+		for _, ref := range missing {
+			fmt.Printf("  ✗ %s: %s %q\n", relPath(baseDir, ref.File), ref.Field, ref.Value)
+		}
+		fmt.Println()
+	}
+
+	if len(orphans) > 0 {
+		fmt.Printf("Orphans (%d):\n", len(orphans))
+		for _, orphan := range orphans {
+			fmt.Printf("  ⚠ %s", relPath(baseDir, orphan.source))
+			if n := len(orphan.files) - 1; n > 0 {
+				fmt.Printf(" (+%d generated)", n)
+			}
+			fmt.Println()
+		}
+		fmt.Println()
+	}
+
+	deleted := 0
+	if deleteOrphans {
+		var deletable []orphan
+		files := 0
+		for _, orphan := range orphans {
+			if kept(baseDir, orphan.source) {
+				fmt.Printf("🔒 Keeping %s (templates may use it)\n", relPath(baseDir, orphan.source))
+				continue
+			}
+			deletable = append(deletable, orphan)
+			files += len(orphan.files)
+		}
+		if len(deletable) > 0 && !yes && !confirm(fmt.Sprintf("Delete %d orphan(s), %d file(s)?", len(deletable), files)) {
+			fmt.Println("Nothing deleted")
+			deletable = nil
+		}
+		for _, orphan := range deletable {
+			for _, file := range orphan.files {
+				if err := os.Remove(file); err != nil {
+					fmt.Printf("✗ Could not delete %s: %v\n", relPath(baseDir, file), err)
+					continue
+				}
+				deleted++
+			}
+			fmt.Printf("🗑️  Deleted %s\n", relPath(baseDir, orphan.source))
+		}
+	}
+
+	refetched := 0
+	if refetchMissing && len(missing) > 0 {
+		refetched, missing = refetch(baseDir, missing)
+	}
+
+	fmt.Printf("\n%s\n", strings.Repeat("=", 50))
+	fmt.Printf("Summary:\n")
+	fmt.Printf("  References: %d\n", len(refs))
+	fmt.Printf("  Missing: %d\n", len(missing))
+	fmt.Printf("  Orphans: %d\n", len(orphans))
+	if deleteOrphans {
+		fmt.Printf("  Files deleted: %d\n", deleted)
+	}
+	if refetchMissing {
+		fmt.Printf("  Re-fetched: %d\n", refetched)
+	}
+	if len(orphans) > 0 && !deleteOrphans {
+		fmt.Println("\nRun with --delete-orphans to remove the orphans.")
+	}
+
+	if len(missing) > 0 {
+		os.Exit(1)
+	}
+}
+
+// orphan is an unused original and the files generated from it
+type orphan struct {
+	source string
+	files  []string
+}
+
+// findOrphans lists the images under dirs whose original isn't used,
+// grouping dithered copies, variants and sidecars with their original
+func findOrphans(dirs []string, used map[string]bool) ([]orphan, error) {
+	groups := make(map[string][]string)
+	for _, dir := range dirs {
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			continue
+		}
+		err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			if !dither.IsImage(path) && !strings.HasSuffix(path, ".dither.toml") {
+				return nil
+			}
+			abs, err := filepath.Abs(path)
+			if err != nil {
+				return err
+			}
+			source := imageset.Source(abs)
+			groups[source] = append(groups[source], abs)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var orphans []orphan
+	for source, files := range groups {
+		if used[source] {
+			continue
+		}
+		sort.Strings(files)
+		orphans = append(orphans, orphan{source: source, files: files})
+	}
+	sort.Slice(orphans, func(i, j int) bool { return orphans[i].source < orphans[j].source })
+	return orphans, nil
+}
+
+// imagePathPattern matches quoted image paths in templates, e.g.
+// "images/placeholder-poster.jpg"
+var imagePathPattern = regexp.MustCompile(`["'` + "`" + `]/?([^"'` + "`" + `\s{}]+\.(?i:jpe?g|png|webp|gif))["'` + "`" + `]`)
+
+// templateRefs marks the images that templates and shortcodes name
+// outright as used, under assets/ (resources.Get) or static/
+func templateRefs(baseDir string, used map[string]bool) error {
+	dirs := []string{filepath.Join(baseDir, "layouts")}
+	themes, _ := filepath.Glob(filepath.Join(baseDir, "themes", "*", "layouts"))
+	dirs = append(dirs, themes...)
+	for _, dir := range dirs {
+		err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if os.IsNotExist(err) {
+				return filepath.SkipDir
+			}
+			if err != nil || d.IsDir() || filepath.Ext(path) != ".html" {
+				return err
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			for _, m := range imagePathPattern.FindAllStringSubmatch(string(data), -1) {
+				for _, root := range []string{"assets", "static"} {
+					if abs, err := filepath.Abs(filepath.Join(baseDir, root, filepath.FromSlash(m[1]))); err == nil {
+						used[abs] = true
+					}
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// kept reports whether an image is in one of keptDirs
+func kept(baseDir, path string) bool {
+	rel := filepath.ToSlash(relPath(baseDir, path))
+	for _, dir := range keptDirs {
+		if rel == dir || strings.HasPrefix(rel, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// confirm asks a yes/no question on the terminal; anything but yes, or no
+// answer at all, is no
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
+
+// refetch runs the download script of movie, music and book pages whose
+// img is missing, which downloads the artwork again and rewrites img, then
+// copies the new artwork to the other language versions. It returns how
+// many pages were fixed and the references that are still missing.
+func refetch(baseDir string, missing []imageset.Ref) (int, []imageset.Ref) {
+	contentDir := filepath.Join(baseDir, "content")
+	titles := make(map[string][]string)
+	slugs := make(map[string]string) // slug -> kind
+	var remaining []imageset.Ref
+	for _, ref := range missing {
+		kind := ""
+		if ref.Page != nil && ref.Field == "img" {
+			kind = ref.Page.String("category")
+		}
+		if _, ok := downloadScripts[kind]; !ok {
+			remaining = append(remaining, ref)
+			continue
+		}
+		slug := ref.Page.Slug()
+		if _, seen := slugs[slug]; seen {
+			continue
+		}
+		slugs[slug] = kind
+		titles[kind] = append(titles[kind], ref.Page.String("title"))
+	}
+
+	kinds := make([]string, 0, len(titles))
+	for kind := range titles {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		fmt.Printf("\n⬇️  Re-fetching %d %s page(s)...\n", len(titles[kind]), kind)
+		args := append(append([]string{"run"}, downloadScripts[kind]...), titles[kind]...)
+		cmd := exec.Command("go", args...)
+		cmd.Dir = baseDir
+		cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+		if err := cmd.Run(); err != nil {
+			fmt.Printf("✗ %s: %v\n", downloadScripts[kind][0], err)
+		}
+	}
+
+	// The scripts update the first language version they find; copy the
+	// new artwork to the others
+	sorted := make([]string, 0, len(slugs))
+	for slug := range slugs {
+		sorted = append(sorted, slug)
+	}
+	sort.Strings(sorted)
+	fixed := 0
+	fixedSlugs := make(map[string]bool)
+	for _, slug := range sorted {
+		kind := slugs[slug]
+		pages := pagesWithSlug(contentDir, kind, slug)
+		var source *consumed.Page
+		for _, page := range pages {
+			ref := imageset.Ref{File: page.Path, Field: "img", Value: page.String("img")}
+			if _, ok := ref.Resolve(baseDir); ok && ref.Value != "" {
+				source = page
+				break
+			}
+		}
+		if source == nil {
+			fmt.Printf("✗ %s/%s: still no artwork\n", kind, slug)
+			continue
+		}
+		for _, page := range pages {
+			if page == source {
+				continue
+			}
+			for _, key := range []string{"img", "placeholder", "color", "accent"} {
+				if raw, ok := source.Raw(key); ok && !page.Locked(key) {
+					page.Set(key, raw, "img", "category", "title")
+				}
+			}
+			if err := page.Save(); err != nil {
+				fmt.Printf("✗ %v\n", err)
+			}
+		}
+		fixedSlugs[slug] = true
+		fixed++
+	}
+
+	for _, ref := range missing {
+		if ref.Page != nil && ref.Field == "img" && !fixedSlugs[ref.Page.Slug()] {
+			if _, ok := slugs[ref.Page.Slug()]; ok {
+				remaining = append(remaining, ref)
+			}
+		}
+	}
+	return fixed, remaining
+}
+
+// pagesWithSlug loads every language version of a page
+func pagesWithSlug(contentDir, kind, slug string) []*consumed.Page {
+	pages, err := consumed.LoadAll(contentDir, kind)
+	if err != nil {
+		return nil
+	}
+	var matches []*consumed.Page
+	for _, page := range pages {
+		if page.Slug() == slug {
+			matches = append(matches, page)
+		}
+	}
+	return matches
+}
+
+func relPath(baseDir, path string) string {
+	if rel, err := filepath.Rel(baseDir, path); err == nil {
+		return rel
+	}
+	return path
+}
+
+// parseArgs parses flags that may come before or after the positional
+// arguments, e.g. "assets/screenshots --delete-orphans"
+func parseArgs(fs *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		fs.Parse(args)
+		if fs.NArg() == 0 {
+			return positional
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+func getBaseDir() string {
+	// Start from current working directory and walk up to find project root
+	wd, _ := os.Getwd()
+	startWd := wd
+	for {
+		if _, err := os.Stat(filepath.Join(wd, "content")); err == nil {
+			return wd
+		}
+		parent := filepath.Dir(wd)
+		if parent == wd {
+			break
+		}
+		wd = parent
+	}
+	return startWd
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/dither"
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/imageset"
+)
+
+// Responsive image variants. Writes <name>_<width>w.<ext> copies of every
+// cover and poster (160, 320 and 480 pixels wide by default, never wider
+// than the original) and records the dimensions of the original and its
+// variants in data/images.json, so templates can emit srcset, width and
+// height. Run it before dither_images, which gives every variant its own
+// dithered twin.
+//
+// Usage:
+//
+//	go run scripts/image_variants.go [directory] [--recursive] [--overwrite]
+//	    [--widths 160,320,480]
+//
+// The directory defaults to static/images and must be under static/ or
+// assets/. Only new or changed originals (by content hash) are redone, and
+// variants whose original was deleted are removed.
+
+func main() {
+	var recursive, overwrite, noPrune bool
+	var widthList string
+	flag.BoolVar(&recursive, "recursive", false, "Include subdirectories")
+	flag.BoolVar(&recursive, "r", false, "Shorthand for -recursive")
+	flag.BoolVar(&overwrite, "overwrite", false, "Re-create variants that are up to date")
+	flag.BoolVar(&overwrite, "o", false, "Shorthand for -overwrite")
+	flag.StringVar(&widthList, "widths", joinWidths(imageset.Widths), "Comma-separated variant widths in pixels")
+	flag.BoolVar(&noPrune, "no-prune", false, "Keep variants whose original is gone")
+	args := parseArgs(flag.CommandLine, os.Args[1:])
+
+	widths, err := imageset.ParseWidths(widthList)
+	if err != nil || len(widths) == 0 {
+		fmt.Printf("❌ Invalid -widths %q, expected e.g. 160,320,480\n", widthList)
+		os.Exit(1)
+	}
+
+	baseDir := getBaseDir()
+	manifest, err := imageset.LoadManifest(filepath.Join(baseDir, imageset.ManifestFile))
+	if err != nil {
+		fmt.Printf("❌ Reading %s: %v\n", imageset.ManifestFile, err)
+		os.Exit(1)
+	}
+
+	directory := filepath.Join(baseDir, "static", "images")
+	if len(args) > 0 {
+		directory = args[0]
+	}
+	if info, err := os.Stat(directory); err != nil || !info.IsDir() {
+		fmt.Printf("❌ Directory not found: %s\n", directory)
+		os.Exit(1)
+	}
+	rel, err := siteRelative(baseDir, directory)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		os.Exit(1)
+	}
+	dirURL, ok := imageset.URL(rel + "/")
+	if !ok {
+		fmt.Printf("❌ %s is not under static/ or assets/, so its images have no fixed URL\n", directory)
+		os.Exit(1)
+	}
+
+	fmt.Printf("\n🔍 Searching for images in: %s\n", directory)
+	if recursive {
+		fmt.Println("   (including subdirectories)")
+	}
+	fmt.Println()
+
+	files, err := findOriginals(directory, recursive)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		os.Exit(1)
+	}
+
+	processed, upToDate, failed := 0, 0, 0
+	for _, file := range files {
+		name := filepath.Base(file)
+		if !dither.Encodable(file) {
+			fmt.Printf("⚠  Skipping %s (no pure Go WebP encoder for its variants, convert it to PNG or JPEG)\n", name)
+			continue
+		}
+		url, created, err := updateImage(file, baseDir, widths, manifest, overwrite)
+		if err != nil {
+			fmt.Printf("✗ %s: %v\n", name, err)
+			failed++
+			continue
+		}
+		if !created {
+			fmt.Printf("⏭️  Skipping %s (up to date)\n", name)
+			upToDate++
+			continue
+		}
+		processed++
+		img := manifest.Images[url]
+		var sizes []string
+		for _, v := range img.Variants {
+			sizes = append(sizes, fmt.Sprint(v.Width))
+		}
+		if len(sizes) == 0 {
+			fmt.Printf("📐 %s: %dx%d (too small for variants)\n", name, img.Width, img.Height)
+		} else {
+			fmt.Printf("📐 %s: %dx%d, variants %s\n", name, img.Width, img.Height, strings.Join(sizes, ", "))
+		}
+	}
+
+	pruned := 0
+	if !noPrune {
+		pruned = prune(manifest, baseDir, strings.TrimSuffix(dirURL, "/"), recursive)
+	}
+
+	if err := manifest.Save(); err != nil {
+		fmt.Printf("✗ Could not save %s: %v\n", imageset.ManifestFile, err)
+		failed++
+	}
+
+	fmt.Println()
+	if len(files) == 0 && pruned == 0 {
+		fmt.Println("❌ No images found")
+		return
+	}
+	fmt.Printf("✨ Done! Processed %d of %d image(s)", processed, len(files))
+	if upToDate > 0 {
+		fmt.Printf(", %d up to date", upToDate)
+	}
+	if pruned > 0 {
+		fmt.Printf(", pruned %d", pruned)
+	}
+	fmt.Println()
+	fmt.Printf("   Widths: %s, manifest: %s\n", joinWidths(widths), imageset.ManifestFile)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// updateImage brings the variants and manifest entry of one original up to
+// date. It returns the original's URL and whether anything was written.
+func updateImage(file, baseDir string, widths []int, manifest *imageset.Manifest, overwrite bool) (string, bool, error) {
+	rel, err := siteRelative(baseDir, file)
+	if err != nil {
+		return "", false, err
+	}
+	url, ok := imageset.URL(rel)
+	if !ok {
+		return "", false, fmt.Errorf("not under static/ or assets/")
+	}
+	hash, err := dither.HashFile(file)
+	if err != nil {
+		return url, false, err
+	}
+
+	old, tracked := manifest.Images[url]
+	if !overwrite && tracked && old.Hash == hash && variantsExist(file, old, widths) {
+		return url, false, nil
+	}
+
+	img, err := dither.Load(file)
+	if err != nil {
+		return url, false, err
+	}
+	b := img.Bounds()
+	entry := imageset.Image{
+		Width:    b.Dx(),
+		Height:   b.Dy(),
+		Dithered: dither.DitheredPath(url),
+		Variants: []imageset.Variant{},
+		Hash:     hash,
+	}
+	keep := make(map[string]bool)
+	for _, width := range widths {
+		if width >= entry.Width {
+			continue
+		}
+		resized := imageset.Resize(img, width)
+		variantFile := imageset.VariantPath(file, width)
+		if err := dither.SaveQuality(variantFile, resized, imageset.Quality); err != nil {
+			return url, false, err
+		}
+		variantURL := imageset.VariantPath(url, width)
+		keep[variantURL] = true
+		entry.Variants = append(entry.Variants, imageset.Variant{
+			Src:      variantURL,
+			Width:    resized.Bounds().Dx(),
+			Height:   resized.Bounds().Dy(),
+			Dithered: dither.DitheredPath(variantURL),
+		})
+	}
+
+	// Widths that were dropped since the last run
+	for _, v := range old.Variants {
+		if !keep[v.Src] {
+			os.Remove(imageset.VariantPath(file, v.Width))
+		}
+	}
+
+	manifest.Images[url] = entry
+	return url, true, nil
+}
+
+// variantsExist reports whether an entry has exactly the variants the
+// current widths call for, and all of them are on disk
+func variantsExist(file string, img imageset.Image, widths []int) bool {
+	var want []int
+	for _, width := range widths {
+		if width < img.Width {
+			want = append(want, width)
+		}
+	}
+	if len(want) != len(img.Variants) {
+		return false
+	}
+	for i, v := range img.Variants {
+		if v.Width != want[i] {
+			return false
+		}
+		if _, err := os.Stat(imageset.VariantPath(file, v.Width)); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// prune removes the variants and entries of originals under dirURL that are
+// gone
+func prune(manifest *imageset.Manifest, baseDir, dirURL string, recursive bool) int {
+	if dirURL == "" {
+		dirURL = "/"
+	}
+	pruned := 0
+	for _, url := range manifest.URLs() {
+		parent := path.Dir(url)
+		if recursive {
+			if dirURL != "/" && parent != dirURL && !strings.HasPrefix(parent, dirURL+"/") {
+				continue
+			}
+		} else if parent != dirURL {
+			continue
+		}
+		if _, ok := imageset.Locate(baseDir, url); ok {
+			continue
+		}
+		for _, v := range manifest.Images[url].Variants {
+			if file, ok := imageset.Locate(baseDir, v.Src); ok {
+				if err := os.Remove(file); err != nil {
+					fmt.Printf("✗ Could not prune %s: %v\n", v.Src, err)
+				}
+			}
+		}
+		delete(manifest.Images, url)
+		fmt.Printf("🗑️  Pruned variants of %s (original is gone)\n", url)
+		pruned++
+	}
+	return pruned
+}
+
+func joinWidths(widths []int) string {
+	s := make([]string, len(widths))
+	for i, w := range widths {
+		s[i] = fmt.Sprint(w)
+	}
+	return strings.Join(s, ",")
+}
+
+// siteRelative turns a path into a slash-separated path from the site root
+func siteRelative(baseDir, path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(baseDir, abs)
+	if err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// parseArgs parses flags that may come before or after the positional
+// arguments, e.g. "static/images --recursive"
+func parseArgs(fs *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		fs.Parse(args)
+		if fs.NArg() == 0 {
+			return positional
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// findOriginals lists the images in a directory that aren't dithered copies
+// or variants, in lexical order
+func findOriginals(directory string, recursive bool) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(directory, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != directory && !recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if dither.IsImage(path) && !dither.IsDithered(path) && !imageset.IsVariant(path) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files, err
+}
+
+func getBaseDir() string {
+	// Start from current working directory and walk up to find project root
+	wd, _ := os.Getwd()
+	startWd := wd
+	for {
+		if _, err := os.Stat(filepath.Join(wd, "content")); err == nil {
+			return wd
+		}
+		parent := filepath.Dir(wd)
+		if parent == wd {
+			break
+		}
+		wd = parent
+	}
+	return startWd
+}
@@ -0,0 +1,429 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/artwork"
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/consumed"
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/dither"
+)
+
+// opfPackage is the subset of an OPF package document we read, shared by
+// EPUB container files and Calibre's metadata.opf
+type opfPackage struct {
+	Metadata struct {
+		Titles      []string        `xml:"title"`
+		Creators    []opfCreator    `xml:"creator"`
+		Identifiers []opfIdentifier `xml:"identifier"`
+		Publisher   string          `xml:"publisher"`
+		Date        string          `xml:"date"`
+		Metas       []struct {
+			Name    string `xml:"name,attr"`
+			Content string `xml:"content,attr"`
+		} `xml:"meta"`
+	} `xml:"metadata"`
+	Manifest struct {
+		Items []struct {
+			ID         string `xml:"id,attr"`
+			Href       string `xml:"href,attr"`
+			MediaType  string `xml:"media-type,attr"`
+			Properties string `xml:"properties,attr"`
+		} `xml:"item"`
+	} `xml:"manifest"`
+	Guide struct {
+		References []struct {
+			Type string `xml:"type,attr"`
+			Href string `xml:"href,attr"`
+		} `xml:"reference"`
+	} `xml:"guide"`
+}
+
+type opfCreator struct {
+	Role string `xml:"role,attr"`
+	Name string `xml:",chardata"`
+}
+
+type opfIdentifier struct {
+	Scheme string `xml:"scheme,attr"`
+	Value  string `xml:",chardata"`
+}
+
+// EbookMetadata is what we keep from an OPF document
+type EbookMetadata struct {
+	Source    string
+	Title     string
+	Authors   []string
+	ISBN      string
+	Publisher string
+	Year      string
+	Cover     []byte
+	CoverExt  string
+}
+
+func main() {
+	var langs string
+	var noDither, force bool
+	flag.StringVar(&langs, "lang", "", "Comma-separated languages to create pages for (default: every content/<lang>/consumed)")
+	flag.BoolVar(&noDither, "no-dither", false, "Don't create the _dithered copy of extracted covers")
+	flag.BoolVar(&force, "force", false, "Replace covers that already exist")
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		fmt.Println("Usage: go run scripts/import_epub.go [flags] <book.epub | calibre-library-dir> ...")
+		flag.PrintDefaults()
+		os.Exit(1)
+	}
+
+	baseDir := getBaseDir()
+	contentDir := filepath.Join(baseDir, "content")
+	imagesDir := filepath.Join(baseDir, "static", "images", "books")
+
+	languages, err := consumed.Languages(contentDir)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if langs != "" {
+		languages = nil
+		for _, l := range strings.Split(langs, ",") {
+			languages = append(languages, strings.TrimSpace(l))
+		}
+	}
+
+	existing, err := consumed.LoadAll(contentDir, "book")
+	if err != nil {
+		fmt.Printf("Error reading book pages: %v\n", err)
+		os.Exit(1)
+	}
+	index := consumed.NewBookIndex(existing)
+
+	var sources []string
+	for _, arg := range flag.Args() {
+		found, err := findEbookSources(arg)
+		if err != nil {
+			fmt.Printf("Warning: %v\n", err)
+			continue
+		}
+		sources = append(sources, found...)
+	}
+	fmt.Printf("Found %d books\n\n", len(sources))
+
+	if err := os.MkdirAll(imagesDir, 0755); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	created, duplicates, failed := 0, 0, 0
+
+	for _, source := range sources {
+		meta, err := readEbookMetadata(source)
+		if err != nil {
+			fmt.Printf("✗ %s: %v\n", source, err)
+			failed++
+			continue
+		}
+
+		if match := index.Find(meta.ISBN, meta.Title); match != "" {
+			fmt.Printf("⏭️  %s (duplicate of %s)\n", meta.Title, match)
+			duplicates++
+			continue
+		}
+
+		slug := consumed.PageSlug(meta.Title)
+		if slug == "" {
+			fmt.Printf("✗ %s: title %q gives an empty page name\n", source, meta.Title)
+			failed++
+			continue
+		}
+		book := consumed.NewBook{
+			Title:     meta.Title,
+			Author:    strings.Join(meta.Authors, ", "),
+			Year:      meta.Year,
+			Publisher: meta.Publisher,
+			ISBN:      meta.ISBN,
+			Status:    consumed.WantToRead,
+			Draft:     true,
+		}
+
+		if len(meta.Cover) > 0 {
+			coverFile := getCoverFilename(meta.Title, meta.Year, meta.ISBN, meta.CoverExt)
+			coverPath := filepath.Join(imagesDir, coverFile)
+			if _, err := os.Stat(coverPath); err == nil && !force {
+				fmt.Printf("  ⚠ %s already exists, keeping it (-force replaces it)\n", coverFile)
+				book.Img = fmt.Sprintf("/images/books/%s", coverFile)
+			} else if err := artwork.Save(coverPath, meta.Cover); err != nil {
+				fmt.Printf("  ⚠ Could not write cover: %v\n", err)
+			} else {
+				book.Img = fmt.Sprintf("/images/books/%s", coverFile)
+				if !noDither {
+					if _, err := dither.File(coverPath, dither.DefaultOptions); err != nil {
+						fmt.Printf("  ⚠ Cover not dithered (%v); build.sh will dither it\n", err)
+					}
+				}
+			}
+		}
+
+		for _, lang := range languages {
+			pagePath := filepath.Join(consumed.Dir(contentDir, lang, "book"), slug+".md")
+			if _, err := os.Stat(pagePath); err == nil {
+				fmt.Printf("  ⚠ %s already exists, leaving it alone\n", pagePath)
+				continue
+			}
+			if err := os.MkdirAll(filepath.Dir(pagePath), 0755); err != nil {
+				fmt.Printf("  ✗ Error writing %s: %v\n", pagePath, err)
+				continue
+			}
+			if err := os.WriteFile(pagePath, []byte(book.Render(lang)), 0644); err != nil {
+				fmt.Printf("  ✗ Error writing %s: %v\n", pagePath, err)
+			}
+		}
+		index.Add(meta.ISBN, meta.Title, slug)
+		fmt.Printf("✓ %s", meta.Title)
+		if book.Img != "" {
+			fmt.Printf(" (with cover)")
+		}
+		fmt.Println()
+		created++
+	}
+
+	// Summary
+	fmt.Printf("\n%s\n", strings.Repeat("=", 50))
+	fmt.Printf("Summary:\n")
+	fmt.Printf("  Created: %d books (as drafts)\n", created)
+	fmt.Printf("  Duplicates: %d\n", duplicates)
+	fmt.Printf("  Failed: %d\n", failed)
+}
+
+func getBaseDir() string {
+	// Start from current working directory and walk up to find project root
+	wd, _ := os.Getwd()
+	startWd := wd
+	for {
+		if _, err := os.Stat(filepath.Join(wd, "content")); err == nil {
+			return wd
+		}
+		parent := filepath.Dir(wd)
+		if parent == wd {
+			break
+		}
+		wd = parent
+	}
+	return startWd
+}
+
+// findEbookSources expands a path into .epub files and Calibre metadata.opf
+// files. In a Calibre book folder the metadata.opf wins over the .epub next
+// to it.
+func findEbookSources(root string) ([]string, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{root}, nil
+	}
+
+	var sources []string
+	err = filepath.WalkDir(root, func(p string, d os.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return err
+		}
+		if _, err := os.Stat(filepath.Join(p, "metadata.opf")); err == nil {
+			sources = append(sources, filepath.Join(p, "metadata.opf"))
+			return nil
+		}
+		epubs, _ := filepath.Glob(filepath.Join(p, "*.epub"))
+		sources = append(sources, epubs...)
+		return nil
+	})
+	return sources, err
+}
+
+// readEbookMetadata reads metadata and the cover from an .epub or
+// metadata.opf. A book without a title is an error, as pages are named
+// after it.
+func readEbookMetadata(source string) (*EbookMetadata, error) {
+	read := readEPUB
+	if strings.EqualFold(filepath.Ext(source), ".opf") {
+		read = readCalibreOPF
+	}
+	meta, err := read(source)
+	if err != nil {
+		return nil, err
+	}
+	if meta.Title == "" {
+		return nil, fmt.Errorf("no title in the metadata")
+	}
+	return meta, nil
+}
+
+func readCalibreOPF(opfPath string) (*EbookMetadata, error) {
+	data, err := os.ReadFile(opfPath)
+	if err != nil {
+		return nil, err
+	}
+	var pkg opfPackage
+	if err := xml.Unmarshal(data, &pkg); err != nil {
+		return nil, fmt.Errorf("invalid metadata.opf: %w", err)
+	}
+	meta := metadataFromOPF(&pkg)
+	meta.Source = opfPath
+
+	// Calibre stores the cover as cover.jpg next to metadata.opf
+	dir := filepath.Dir(opfPath)
+	coverHref := "cover.jpg"
+	for _, ref := range pkg.Guide.References {
+		if ref.Type == "cover" {
+			coverHref = ref.Href
+		}
+	}
+	if cover, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(coverHref))); err == nil {
+		meta.Cover, meta.CoverExt = cover, imageExt(coverHref)
+	}
+	return meta, nil
+}
+
+func readEPUB(epubPath string) (*EbookMetadata, error) {
+	zr, err := zip.OpenReader(epubPath)
+	if err != nil {
+		return nil, fmt.Errorf("not a valid epub: %w", err)
+	}
+	defer zr.Close()
+
+	files := make(map[string]*zip.File)
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	var container struct {
+		Rootfiles []struct {
+			FullPath string `xml:"full-path,attr"`
+		} `xml:"rootfiles>rootfile"`
+	}
+	if err := unmarshalZipFile(files["META-INF/container.xml"], &container); err != nil {
+		return nil, fmt.Errorf("reading container.xml: %w", err)
+	}
+	if len(container.Rootfiles) == 0 {
+		return nil, fmt.Errorf("container.xml has no rootfile")
+	}
+
+	opfPath := container.Rootfiles[0].FullPath
+	var pkg opfPackage
+	if err := unmarshalZipFile(files[opfPath], &pkg); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", opfPath, err)
+	}
+	meta := metadataFromOPF(&pkg)
+	meta.Source = epubPath
+
+	if href := epubCoverHref(&pkg); href != "" {
+		unescaped, err := url.PathUnescape(href)
+		if err == nil {
+			href = unescaped
+		}
+		if f := files[path.Join(path.Dir(opfPath), href)]; f != nil {
+			if cover, err := readZipFile(f); err == nil {
+				meta.Cover, meta.CoverExt = cover, imageExt(href)
+			}
+		}
+	}
+	return meta, nil
+}
+
+func readZipFile(f *zip.File) ([]byte, error) {
+	if f == nil {
+		return nil, fmt.Errorf("file not found in archive")
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+func unmarshalZipFile(f *zip.File, v interface{}) error {
+	data, err := readZipFile(f)
+	if err != nil {
+		return err
+	}
+	return xml.Unmarshal(data, v)
+}
+
+// epubCoverHref finds the cover image using the EPUB 3 cover-image property,
+// then the EPUB 2 <meta name="cover">, then any image item named like a cover
+func epubCoverHref(pkg *opfPackage) string {
+	items := pkg.Manifest.Items
+	for _, item := range items {
+		if strings.Contains(item.Properties, "cover-image") {
+			return item.Href
+		}
+	}
+	for _, m := range pkg.Metadata.Metas {
+		if m.Name != "cover" {
+			continue
+		}
+		for _, item := range items {
+			if item.ID == m.Content {
+				return item.Href
+			}
+		}
+	}
+	for _, item := range items {
+		if strings.HasPrefix(item.MediaType, "image/") && strings.Contains(strings.ToLower(item.ID+item.Href), "cover") {
+			return item.Href
+		}
+	}
+	return ""
+}
+
+func metadataFromOPF(pkg *opfPackage) *EbookMetadata {
+	md := pkg.Metadata
+	meta := &EbookMetadata{Publisher: strings.TrimSpace(md.Publisher)}
+
+	if len(md.Titles) > 0 {
+		meta.Title = strings.TrimSpace(md.Titles[0])
+	}
+	for _, c := range md.Creators {
+		if c.Role == "" || c.Role == "aut" {
+			if name := strings.TrimSpace(c.Name); name != "" {
+				meta.Authors = append(meta.Authors, name)
+			}
+		}
+	}
+	for _, id := range md.Identifiers {
+		if isbn := consumed.NormalizeISBN(id.Value); isbn != "" && (strings.EqualFold(id.Scheme, "isbn") || strings.Contains(strings.ToLower(id.Value), "isbn") || meta.ISBN == "") {
+			meta.ISBN = isbn
+		}
+	}
+	// Calibre writes 0101-01-01 when the publication date is unknown
+	if year := regexp.MustCompile(`^\d{4}`).FindString(strings.TrimSpace(md.Date)); year > "1000" {
+		meta.Year = year
+	}
+	return meta
+}
+
+func imageExt(name string) string {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".png":
+		return ".png"
+	case ".gif":
+		return ".gif"
+	default:
+		return ".jpg"
+	}
+}
+
+// getCoverFilename names a cover by title and year (or ISBN), so books with
+// the same title don't overwrite each other's cover
+func getCoverFilename(title, year, isbn, ext string) string {
+	return artwork.Filename(artwork.Slug(title), artwork.Key(year, isbn), "cover", ext)
+}
@@ -0,0 +1,173 @@
+package main
+
+// Tests of reading metadata and covers from EPUBs and Calibre libraries:
+//
+//	go test scripts/import_epub.go scripts/import_epub_test.go
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const testContainer = `<?xml version="1.0"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>`
+
+// testOPF is a package document, taking the <dc:title> element and the
+// cover's <meta> and manifest <item>
+const testOPF = `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:opf="http://www.idpf.org/2007/opf" version="3.0">
+  <metadata>
+    %s
+    <dc:creator opf:role="aut">Cal Newport</dc:creator>
+    <dc:creator opf:role="edt">An Editor</dc:creator>
+    <dc:identifier opf:scheme="uuid">urn:uuid:1b4e28ba-2fa1-11d2-883f-0016d3cca427</dc:identifier>
+    <dc:identifier opf:scheme="ISBN">978-1-4555-8669-1</dc:identifier>
+    <dc:publisher> Grand Central </dc:publisher>
+    <dc:date>2016-01-05T00:00:00+00:00</dc:date>
+    %s
+  </metadata>
+  <manifest>
+    <item id="text" href="text.xhtml" media-type="application/xhtml+xml"/>
+    %s
+  </manifest>
+</package>`
+
+func testCover(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 4, 6))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// writeEPUB writes an EPUB of files, mapping names in the archive to content
+func writeEPUB(t *testing.T, files map[string][]byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "book.epub")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	for name, data := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(data)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func opf(title, meta, item string) []byte {
+	return []byte(fmt.Sprintf(testOPF, title, meta, item))
+}
+
+func TestReadEPUB(t *testing.T) {
+	cover := testCover(t)
+	want := EbookMetadata{
+		Title:     "Deep Work",
+		Authors:   []string{"Cal Newport"},
+		ISBN:      "9781455586691",
+		Publisher: "Grand Central",
+		Year:      "2016",
+		Cover:     cover,
+		CoverExt:  ".png",
+	}
+	tests := []struct {
+		name, meta, item, coverName string
+	}{
+		{
+			name:      "EPUB 3 cover-image",
+			item:      `<item id="img" href="images/front%20cover.png" media-type="image/png" properties="cover-image"/>`,
+			coverName: "OEBPS/images/front cover.png",
+		},
+		{
+			name:      "EPUB 2 meta cover",
+			meta:      `<meta name="cover" content="img"/>`,
+			item:      `<item id="img" href="images/front.png" media-type="image/png"/>`,
+			coverName: "OEBPS/images/front.png",
+		},
+		{
+			name:      "image named like a cover",
+			item:      `<item id="cover-image" href="cover.png" media-type="image/png"/>`,
+			coverName: "OEBPS/cover.png",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeEPUB(t, map[string][]byte{
+				"META-INF/container.xml": []byte(testContainer),
+				"OEBPS/content.opf":      opf("<dc:title> Deep Work </dc:title>", tt.meta, tt.item),
+				tt.coverName:             cover,
+			})
+			meta, err := readEbookMetadata(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := want
+			want.Source = path
+			if !reflect.DeepEqual(*meta, want) {
+				t.Errorf("got  %+v\nwant %+v", *meta, want)
+			}
+		})
+	}
+}
+
+func TestReadCalibreOPF(t *testing.T) {
+	dir := t.TempDir()
+	cover := testCover(t)
+	os.WriteFile(filepath.Join(dir, "metadata.opf"), opf("<dc:title>Deep Work</dc:title>", "", ""), 0644)
+	os.WriteFile(filepath.Join(dir, "cover.jpg"), cover, 0644)
+
+	meta, err := readEbookMetadata(filepath.Join(dir, "metadata.opf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Title != "Deep Work" || meta.ISBN != "9781455586691" || !bytes.Equal(meta.Cover, cover) || meta.CoverExt != ".jpg" {
+		t.Errorf("got %+v", *meta)
+	}
+}
+
+func TestReadEbookWithoutTitle(t *testing.T) {
+	for name, title := range map[string]string{"empty": "<dc:title> </dc:title>", "missing": ""} {
+		path := writeEPUB(t, map[string][]byte{
+			"META-INF/container.xml": []byte(testContainer),
+			"OEBPS/content.opf":      opf(title, "", ""),
+		})
+		if meta, err := readEbookMetadata(path); err == nil {
+			t.Errorf("%s title: got %+v, want an error", name, *meta)
+		}
+	}
+}
+
+func TestReadEPUBErrors(t *testing.T) {
+	notZip := filepath.Join(t.TempDir(), "book.epub")
+	os.WriteFile(notZip, []byte("not a zip"), 0644)
+	tests := map[string]string{
+		"not a zip":       notZip,
+		"no container":    writeEPUB(t, map[string][]byte{"OEBPS/content.opf": opf("<dc:title>Deep Work</dc:title>", "", "")}),
+		"no package file": writeEPUB(t, map[string][]byte{"META-INF/container.xml": []byte(testContainer)}),
+	}
+	for name, path := range tests {
+		if _, err := readEbookMetadata(path); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
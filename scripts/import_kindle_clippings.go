@@ -0,0 +1,532 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/consumed"
+)
+
+// kindleSection is the name of the managed body section holding highlights
+const kindleSection = "kindle"
+
+// Clipping is a highlight (with an optional attached note) from My Clippings.txt
+type Clipping struct {
+	Title    string
+	Author   string
+	Page     int
+	LocStart int
+	LocEnd   int
+	Added    time.Time
+	Text     string
+	Note     string
+}
+
+// rawClipping is a single "==========" delimited entry before grouping
+type rawClipping struct {
+	Clipping
+	Kind string // Highlight, Note or Bookmark
+}
+
+// kindleLabels are the per-language strings used when rendering highlights
+var kindleLabels = map[string]struct{ Heading, Location, Page, Note string }{
+	"en": {"Highlights", "Location", "Page", "Note"},
+	"es": {"Subrayados", "Posición", "Página", "Nota"},
+}
+
+func main() {
+	var dryRun bool
+	flag.BoolVar(&dryRun, "dry-run", false, "Report matches without writing pages")
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		fmt.Println("Usage: go run scripts/import_kindle_clippings.go [flags] <My Clippings.txt>")
+		flag.PrintDefaults()
+		os.Exit(1)
+	}
+
+	baseDir := getBaseDir()
+	contentDir := filepath.Join(baseDir, "content")
+
+	f, err := os.Open(flag.Arg(0))
+	if err != nil {
+		fmt.Printf("Error opening clippings: %v\n", err)
+		os.Exit(1)
+	}
+	defer f.Close()
+
+	raw, err := parseClippings(f)
+	if err != nil {
+		fmt.Printf("Error reading clippings: %v\n", err)
+		os.Exit(1)
+	}
+	books := groupClippings(raw)
+	fmt.Printf("Read %d clippings for %d books\n\n", len(raw), len(books))
+
+	pages, err := consumed.LoadAll(contentDir, "book")
+	if err != nil {
+		fmt.Printf("Error reading book pages: %v\n", err)
+		os.Exit(1)
+	}
+
+	titles := make([]string, 0, len(books))
+	for title := range books {
+		titles = append(titles, title)
+	}
+	sort.Strings(titles)
+
+	updated, unmatched := 0, 0
+	for _, title := range titles {
+		clippings := books[title]
+		slug := matchBookPage(pages, clippings[0].Title, clippings[0].Author)
+		if slug == "" {
+			fmt.Printf("⚠ No page found for %s (%s)\n", clippings[0].Title, clippings[0].Author)
+			unmatched++
+			continue
+		}
+
+		fmt.Printf("✓ %s → %s (%d highlights)\n", clippings[0].Title, slug, len(clippings))
+		if dryRun {
+			continue
+		}
+
+		for _, page := range pages {
+			if page.Slug() != slug {
+				continue
+			}
+			merged := mergeClippings(parseKindleSection(page.Body), clippings)
+			page.Body = consumed.ReplaceSection(page.Body, kindleSection, renderKindleSection(merged, page.Lang))
+			if err := page.Save(); err != nil {
+				fmt.Printf("  ✗ Error updating %s: %v\n", page.Path, err)
+				continue
+			}
+			fmt.Printf("  ✓ Updated %s/%s\n", page.Lang, filepath.Base(page.Path))
+		}
+		updated++
+	}
+
+	// Summary
+	fmt.Printf("\n%s\n", strings.Repeat("=", 50))
+	fmt.Printf("Summary:\n")
+	fmt.Printf("  Books updated: %d\n", updated)
+	fmt.Printf("  Books without a page: %d\n", unmatched)
+}
+
+func getBaseDir() string {
+	// Start from current working directory and walk up to find project root
+	wd, _ := os.Getwd()
+	startWd := wd
+	for {
+		if _, err := os.Stat(filepath.Join(wd, "content")); err == nil {
+			return wd
+		}
+		parent := filepath.Dir(wd)
+		if parent == wd {
+			break
+		}
+		wd = parent
+	}
+	return startWd
+}
+
+var (
+	clippingTitlePattern = regexp.MustCompile(`^(.*?)\s*\(([^()]*)\)$`)
+	clippingKindPattern  = regexp.MustCompile(`(?i)^-\s*Your (Highlight|Note|Bookmark)`)
+	clippingPagePattern  = regexp.MustCompile(`(?i)page (\d+)`)
+	clippingLocPattern   = regexp.MustCompile(`(?i)location (\d+)(?:-(\d+))?`)
+	clippingAddedPattern = regexp.MustCompile(`(?i)Added on (.*)$`)
+)
+
+// parseClippings reads every entry of a My Clippings.txt file
+func parseClippings(r io.Reader) ([]rawClipping, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var clippings []rawClipping
+	var lines []string
+	flush := func() {
+		if c, ok := parseClipping(lines); ok {
+			clippings = append(clippings, c)
+		}
+		lines = nil
+	}
+
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		line = strings.TrimPrefix(line, "\ufeff")
+		if strings.HasPrefix(line, "==========") {
+			flush()
+			continue
+		}
+		lines = append(lines, line)
+	}
+	flush()
+	return clippings, scanner.Err()
+}
+
+func parseClipping(lines []string) (rawClipping, bool) {
+	if len(lines) < 2 {
+		return rawClipping{}, false
+	}
+
+	var c rawClipping
+	heading := strings.TrimSpace(lines[0])
+	if m := clippingTitlePattern.FindStringSubmatch(heading); m != nil {
+		c.Title, c.Author = m[1], normalizeKindleAuthor(m[2])
+	} else {
+		c.Title = heading
+	}
+
+	meta := strings.TrimSpace(lines[1])
+	m := clippingKindPattern.FindStringSubmatch(meta)
+	if m == nil {
+		return rawClipping{}, false
+	}
+	c.Kind = strings.ToUpper(m[1][:1]) + strings.ToLower(m[1][1:])
+	if m := clippingPagePattern.FindStringSubmatch(meta); m != nil {
+		c.Page, _ = strconv.Atoi(m[1])
+	}
+	if m := clippingLocPattern.FindStringSubmatch(meta); m != nil {
+		c.LocStart, _ = strconv.Atoi(m[1])
+		c.LocEnd = c.LocStart
+		if m[2] != "" {
+			c.LocEnd, _ = strconv.Atoi(m[2])
+		}
+	}
+	if m := clippingAddedPattern.FindStringSubmatch(meta); m != nil {
+		c.Added, _ = time.Parse("Monday, January 2, 2006 3:04:05 PM", strings.TrimSpace(m[1]))
+	}
+
+	c.Text = strings.TrimSpace(strings.Join(lines[2:], "\n"))
+	if c.Kind != "Bookmark" && c.Text == "" {
+		return rawClipping{}, false
+	}
+	return c, true
+}
+
+// normalizeKindleAuthor turns "Goggins, David" into "David Goggins" and keeps
+// only the first of several ";"-separated authors
+func normalizeKindleAuthor(author string) string {
+	author = strings.TrimSpace(strings.Split(author, ";")[0])
+	parts := strings.Split(author, ",")
+	if len(parts) == 2 {
+		author = strings.TrimSpace(parts[1]) + " " + strings.TrimSpace(parts[0])
+	}
+	return author
+}
+
+// groupClippings groups highlights by book, attaches notes to the highlight
+// they were written on, and drops bookmarks
+func groupClippings(raw []rawClipping) map[string][]Clipping {
+	highlights := make(map[string][]Clipping)
+	notes := make(map[string][]rawClipping)
+	for _, c := range raw {
+		key := c.Title + "\x00" + c.Author
+		switch c.Kind {
+		case "Highlight":
+			highlights[key] = append(highlights[key], c.Clipping)
+		case "Note":
+			notes[key] = append(notes[key], c)
+		}
+	}
+
+	books := make(map[string][]Clipping)
+	for key, hs := range highlights {
+		hs = mergeClippings(nil, hs)
+		for _, note := range notes[key] {
+			attached := false
+			for i := range hs {
+				if clippingsOverlap(hs[i], note.Clipping) {
+					hs[i].Note = note.Text
+					attached = true
+					break
+				}
+			}
+			if !attached {
+				c := note.Clipping
+				c.Note, c.Text = note.Text, ""
+				hs = append(hs, c)
+			}
+		}
+		sortClippings(hs)
+		books[key] = hs
+	}
+	return books
+}
+
+// mergeClippings combines previously imported highlights with new ones. A
+// highlight replaces an older one at the same location, and a highlight that
+// was later extended (overlapping location, containing the old text) replaces
+// the shorter version. Clippings without a location (page-only highlights
+// from PDFs and sideloaded books) go by text instead: the same text, or on
+// the same page one containing the other. Notes without a highlight go by
+// location or their own text, as they have no highlight to compare.
+func mergeClippings(existing, incoming []Clipping) []Clipping {
+	all := append(append([]Clipping{}, existing...), incoming...)
+	var merged []Clipping
+	for _, c := range all {
+		replaced := false
+		for i, m := range merged {
+			if c.Text == "" || m.Text == "" || !sameHighlight(m, c) {
+				continue
+			}
+			sameLocation := hasLocation(c) && c.LocStart == m.LocStart && c.LocEnd == m.LocEnd
+			if sameLocation || strings.Contains(c.Text, m.Text) {
+				if c.Note == "" {
+					c.Note = m.Note
+				}
+				merged[i] = c
+				replaced = true
+				break
+			}
+			if strings.Contains(m.Text, c.Text) {
+				replaced = true
+				break
+			}
+		}
+		for i := range merged {
+			if replaced {
+				break
+			}
+			replaced = mergeNote(&merged[i], c)
+		}
+		if !replaced {
+			merged = append(merged, c)
+		}
+	}
+	sortClippings(merged)
+	return merged
+}
+
+// mergeNote merges c into m when one of them is a note without a highlight,
+// and reports whether it did. A note replaces one at the same location, and
+// is attached to a highlight it was written on that has no note yet.
+func mergeNote(m *Clipping, c Clipping) bool {
+	switch {
+	case c.Text != "" && m.Text != "":
+		return false
+	case c.Text == "" && m.Text == "":
+		sameLocation := hasLocation(c) && hasLocation(*m) && c.LocStart == m.LocStart
+		if !sameLocation && c.Note != m.Note {
+			return false
+		}
+		*m = c
+	case c.Text == "":
+		if !clippingsOverlap(*m, c) || m.Note != "" && m.Note != c.Note {
+			return false
+		}
+		m.Note = c.Note
+	default:
+		if !clippingsOverlap(*m, c) || c.Note != "" && c.Note != m.Note {
+			return false
+		}
+		c.Note = m.Note
+		*m = c
+	}
+	return true
+}
+
+// hasLocation reports whether c has a Kindle location; clippings from PDFs
+// and sideloaded books often only have a page
+func hasLocation(c Clipping) bool {
+	return c.LocStart > 0
+}
+
+// clippingsOverlap reports whether two clippings share a location. Without
+// a location on both there is nothing to compare, so they never overlap.
+func clippingsOverlap(a, b Clipping) bool {
+	if !hasLocation(a) || !hasLocation(b) {
+		return false
+	}
+	return a.LocStart <= b.LocEnd && b.LocStart <= a.LocEnd
+}
+
+// sameHighlight reports whether two highlights may be versions of one
+// another: overlapping locations, or without them the same text or one
+// containing the other on the same page
+func sameHighlight(a, b Clipping) bool {
+	if hasLocation(a) && hasLocation(b) {
+		return clippingsOverlap(a, b)
+	}
+	if a.Text == b.Text {
+		return true
+	}
+	return a.Page > 0 && a.Page == b.Page && (strings.Contains(a.Text, b.Text) || strings.Contains(b.Text, a.Text))
+}
+
+func sortClippings(cs []Clipping) {
+	sort.SliceStable(cs, func(i, j int) bool {
+		if cs[i].LocStart != cs[j].LocStart {
+			return cs[i].LocStart < cs[j].LocStart
+		}
+		if cs[i].Page != cs[j].Page {
+			return cs[i].Page < cs[j].Page
+		}
+		return cs[i].LocEnd < cs[j].LocEnd
+	})
+}
+
+// matchBookPage finds the slug of the page for a Kindle book, first by exact
+// title key and then by prefix when the author's surname also matches
+func matchBookPage(pages []*consumed.Page, title, author string) string {
+	title = regexp.MustCompile(`\s*\([^()]*\)`).ReplaceAllString(title, "")
+	key := consumed.TitleKey(title)
+	if key == "" {
+		return ""
+	}
+	for _, page := range pages {
+		if consumed.TitleKey(page.String("title")) == key {
+			return page.Slug()
+		}
+	}
+
+	surname := ""
+	if fields := strings.Fields(author); len(fields) > 0 {
+		surname = strings.ToLower(fields[len(fields)-1])
+	}
+	for _, page := range pages {
+		pageKey := consumed.TitleKey(page.String("title"))
+		if pageKey == "" || !(strings.HasPrefix(pageKey, key) || strings.HasPrefix(key, pageKey)) {
+			continue
+		}
+		if surname == "" || strings.Contains(strings.ToLower(page.String("author")), surname) {
+			return page.Slug()
+		}
+	}
+	return ""
+}
+
+// renderKindleSection renders highlights as quote shortcodes
+func renderKindleSection(clippings []Clipping, lang string) string {
+	labels, ok := kindleLabels[lang]
+	if !ok {
+		labels = kindleLabels["en"]
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "## %s\n", labels.Heading)
+	for _, c := range clippings {
+		b.WriteString("\n")
+		if c.Text != "" {
+			fmt.Fprintf(&b, "{{< quote cite=\"%s\" >}}\n%s\n{{< /quote >}}\n", kindleCite(c, labels.Location, labels.Page), c.Text)
+		}
+		if c.Note == "" {
+			continue
+		}
+		note := strings.ReplaceAll(c.Note, "\n", " ")
+		if c.Text != "" {
+			fmt.Fprintf(&b, "*%s:* %s\n", labels.Note, note)
+		} else if cite := kindleCite(c, labels.Location, labels.Page); cite != "" {
+			// Keep the location, which is how parseKindleSection and
+			// mergeClippings tell notes apart
+			fmt.Fprintf(&b, "*%s, %s:* %s\n", labels.Note, cite, note)
+		} else {
+			fmt.Fprintf(&b, "*%s:* %s\n", labels.Note, note)
+		}
+	}
+	return b.String()
+}
+
+// kindleCite is where a clipping is in the book, e.g. "Location 100-104",
+// or "Page 12" when it has no location
+func kindleCite(c Clipping, location, page string) string {
+	switch {
+	case hasLocation(c):
+		loc := strconv.Itoa(c.LocStart)
+		if c.LocEnd != c.LocStart {
+			loc += "-" + strconv.Itoa(c.LocEnd)
+		}
+		return location + " " + loc
+	case c.Page > 0:
+		return page + " " + strconv.Itoa(c.Page)
+	}
+	return ""
+}
+
+var (
+	kindleQuotePattern = regexp.MustCompile(`(?s)\{\{< quote cite="([^"]*)" >\}\}\n(.*?)\n\{\{< /quote >\}\}\n?(\*[^*]+:\* [^\n]*)?`)
+	kindleNotePattern  = regexp.MustCompile(`(?m)^\*[^*]+:\* (.*)$`)
+	// A note without a highlight, e.g. *Note, Location 120:* text. Older
+	// imports left out the location.
+	kindleLoneNotePattern = regexp.MustCompile(`(?m)^\*[^*,]*(?:, ([^*]*))?:\* (.*)$`)
+	kindleCitePattern     = regexp.MustCompile(`^(.*?)\s*(\d+)(?:-(\d+))?$`)
+)
+
+// parseKindleSection reads back highlights and notes written by
+// renderKindleSection
+func parseKindleSection(body string) []Clipping {
+	section, ok := consumed.Section(body, kindleSection)
+	if !ok {
+		return nil
+	}
+	var clippings []Clipping
+	quotes := kindleQuotePattern.FindAllStringSubmatchIndex(section, -1)
+	for _, idx := range quotes {
+		m := submatches(section, idx)
+		c := parseKindleCite(m[1])
+		c.Text = m[2]
+		if m[3] != "" {
+			c.Note = kindleNotePattern.FindStringSubmatch(m[3])[1]
+		}
+		clippings = append(clippings, c)
+	}
+
+notes:
+	for _, idx := range kindleLoneNotePattern.FindAllStringSubmatchIndex(section, -1) {
+		for _, q := range quotes {
+			if idx[0] >= q[0] && idx[0] < q[1] {
+				// A highlight's note, read above
+				continue notes
+			}
+		}
+		m := submatches(section, idx)
+		c := parseKindleCite(m[1])
+		c.Note = m[2]
+		clippings = append(clippings, c)
+	}
+	sortClippings(clippings)
+	return clippings
+}
+
+// submatches returns the text of each group of a FindStringSubmatchIndex
+// match, "" for groups that didn't match
+func submatches(s string, idx []int) []string {
+	m := make([]string, len(idx)/2)
+	for i := range m {
+		if idx[2*i] >= 0 {
+			m[i] = s[idx[2*i]:idx[2*i+1]]
+		}
+	}
+	return m
+}
+
+// parseKindleCite reads back what kindleCite wrote: the location, or the
+// page when labelled as one in any language
+func parseKindleCite(cite string) Clipping {
+	var c Clipping
+	m := kindleCitePattern.FindStringSubmatch(cite)
+	if m == nil {
+		return c
+	}
+	for _, labels := range kindleLabels {
+		if m[1] == labels.Page {
+			c.Page, _ = strconv.Atoi(m[2])
+			return c
+		}
+	}
+	c.LocStart, _ = strconv.Atoi(m[2])
+	c.LocEnd = c.LocStart
+	if m[3] != "" {
+		c.LocEnd, _ = strconv.Atoi(m[3])
+	}
+	return c
+}
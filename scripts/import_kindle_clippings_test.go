@@ -0,0 +1,217 @@
+package main
+
+// Tests of reading, merging and writing back Kindle highlights:
+//
+//	go test scripts/import_kindle_clippings.go scripts/import_kindle_clippings_test.go
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/consumed"
+)
+
+const testClippings = `Can't Hurt Me (Goggins, David)
+- Your Highlight on page 12 | location 100-104 | Added on Monday, March 4, 2024 9:15:02 PM
+
+You are in danger of living a life so comfortable and soft, that you will die without ever realizing your true potential.
+==========
+Can't Hurt Me (Goggins, David)
+- Your Note on page 12 | location 104 | Added on Monday, March 4, 2024 9:16:10 PM
+
+The comfort trap
+==========
+Can't Hurt Me (Goggins, David)
+- Your Note on page 30 | location 250 | Added on Monday, March 4, 2024 9:30:00 PM
+
+Look up the 40% rule
+==========
+Can't Hurt Me (Goggins, David)
+- Your Note on page 41 | location 380 | Added on Monday, March 4, 2024 9:40:00 PM
+
+Callous the mind
+==========
+Can't Hurt Me (Goggins, David)
+- Your Bookmark on page 50 | location 420 | Added on Monday, March 4, 2024 9:45:00 PM
+
+==========
+Can't Hurt Me (Goggins, David)
+- Your Highlight on page 60 | location 500-502 | Added on Monday, March 4, 2024 10:01:00 PM
+
+The most important conversations you'll ever have are the ones you'll have with yourself.
+==========
+`
+
+// A PDF: highlights and notes with a page but no location
+const testPageOnlyClippings = `Deep Work (Newport, Cal)
+- Your Highlight on page 5 | Added on Tuesday, April 2, 2024 8:00:00 PM
+
+Clarity about what matters provides clarity about what does not.
+==========
+Deep Work (Newport, Cal)
+- Your Highlight on page 9 | Added on Tuesday, April 2, 2024 8:05:00 PM
+
+Who you are, what you think, feel, and do, what you love is the sum of what you focus on.
+==========
+Deep Work (Newport, Cal)
+- Your Note on page 9 | Added on Tuesday, April 2, 2024 8:06:00 PM
+
+Attention as a resource
+==========
+Deep Work (Newport, Cal)
+- Your Highlight on page 9 | Added on Tuesday, April 2, 2024 8:10:00 PM
+
+Efforts to deepen your focus will struggle if you don't simultaneously wean your mind from a dependence on distraction.
+==========
+Deep Work (Newport, Cal)
+- Your Highlight on page 9 | Added on Tuesday, April 2, 2024 8:11:00 PM
+
+Efforts to deepen your focus will struggle
+==========
+`
+
+// rendered keeps what renderKindleSection writes of each clipping: the
+// location, or the page when there is none
+func rendered(cs []Clipping) []Clipping {
+	out := make([]Clipping, len(cs))
+	for i, c := range cs {
+		out[i] = Clipping{LocStart: c.LocStart, LocEnd: c.LocEnd, Text: c.Text, Note: c.Note}
+		if !hasLocation(c) {
+			out[i].Page = c.Page
+		}
+	}
+	return out
+}
+
+func importTestClippings(t *testing.T, clippings string) []Clipping {
+	t.Helper()
+	raw, err := parseClippings(strings.NewReader(clippings))
+	if err != nil {
+		t.Fatal(err)
+	}
+	books := groupClippings(raw)
+	if len(books) != 1 {
+		t.Fatalf("got %d books, want 1", len(books))
+	}
+	for _, clippings := range books {
+		return clippings
+	}
+	return nil
+}
+
+func TestKindleRoundTrip(t *testing.T) {
+	tests := []struct {
+		name, clippings string
+		want            []Clipping
+	}{
+		{
+			name:      "locations",
+			clippings: testClippings,
+			want: []Clipping{
+				{LocStart: 100, LocEnd: 104, Text: "You are in danger of living a life so comfortable and soft, that you will die without ever realizing your true potential.", Note: "The comfort trap"},
+				{LocStart: 250, LocEnd: 250, Note: "Look up the 40% rule"},
+				{LocStart: 380, LocEnd: 380, Note: "Callous the mind"},
+				{LocStart: 500, LocEnd: 502, Text: "The most important conversations you'll ever have are the ones you'll have with yourself."},
+			},
+		},
+		{
+			// Every highlight is kept, cited by page, and the shorter
+			// version of the last one is dropped
+			name:      "pages only",
+			clippings: testPageOnlyClippings,
+			want: []Clipping{
+				{Page: 5, Text: "Clarity about what matters provides clarity about what does not."},
+				{Page: 9, Text: "Who you are, what you think, feel, and do, what you love is the sum of what you focus on."},
+				{Page: 9, Text: "Efforts to deepen your focus will struggle if you don't simultaneously wean your mind from a dependence on distraction."},
+				{Page: 9, Note: "Attention as a resource"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		for _, lang := range []string{"en", "es"} {
+			t.Run(tt.name+"/"+lang, func(t *testing.T) {
+				testKindleRoundTrip(t, tt.clippings, lang, tt.want)
+			})
+		}
+	}
+}
+
+func testKindleRoundTrip(t *testing.T, file, lang string, want []Clipping) {
+	clippings := importTestClippings(t, file)
+	if got := rendered(clippings); !reflect.DeepEqual(got, want) {
+		t.Fatalf("imported %+v\nwant %+v", got, want)
+	}
+
+	section := renderKindleSection(clippings, lang)
+	body := consumed.ReplaceSection("Some thoughts.\n", kindleSection, section)
+	parsed := parseKindleSection(body)
+	if !reflect.DeepEqual(parsed, want) {
+		t.Fatalf("read back %+v\nwant %+v\nfrom:\n%s", parsed, want, section)
+	}
+	if again := renderKindleSection(parsed, lang); again != section {
+		t.Errorf("rendering what was read back changed the section:\n%s\nwant:\n%s", again, section)
+	}
+
+	// Importing the same file again changes nothing
+	merged := mergeClippings(parsed, clippings)
+	if got := renderKindleSection(merged, lang); got != section {
+		t.Errorf("importing again changed the section:\n%s\nwant:\n%s", got, section)
+	}
+}
+
+func TestMergeClippingsNotes(t *testing.T) {
+	highlight := Clipping{LocStart: 100, LocEnd: 104, Text: "A highlight"}
+	tests := []struct {
+		name               string
+		existing, incoming []Clipping
+		want               []Clipping
+	}{
+		{
+			name:     "notes at different locations",
+			existing: []Clipping{{LocStart: 250, LocEnd: 250, Note: "First"}},
+			incoming: []Clipping{{LocStart: 380, LocEnd: 380, Note: "Second"}},
+			want:     []Clipping{{LocStart: 250, LocEnd: 250, Note: "First"}, {LocStart: 380, LocEnd: 380, Note: "Second"}},
+		},
+		{
+			name:     "edited note",
+			existing: []Clipping{{LocStart: 250, LocEnd: 250, Note: "Frist"}},
+			incoming: []Clipping{{LocStart: 250, LocEnd: 250, Note: "First"}},
+			want:     []Clipping{{LocStart: 250, LocEnd: 250, Note: "First"}},
+		},
+		{
+			name:     "note in a highlight",
+			existing: []Clipping{highlight},
+			incoming: []Clipping{{LocStart: 102, LocEnd: 102, Note: "On it"}},
+			want:     []Clipping{{LocStart: 100, LocEnd: 104, Text: "A highlight", Note: "On it"}},
+		},
+		{
+			name:     "second note in a highlight",
+			existing: []Clipping{{LocStart: 100, LocEnd: 104, Text: "A highlight", Note: "On it"}},
+			incoming: []Clipping{{LocStart: 102, LocEnd: 102, Note: "Also on it"}},
+			want: []Clipping{
+				{LocStart: 100, LocEnd: 104, Text: "A highlight", Note: "On it"},
+				{LocStart: 102, LocEnd: 102, Note: "Also on it"},
+			},
+		},
+		{
+			name:     "highlight over a note",
+			existing: []Clipping{{LocStart: 102, LocEnd: 102, Note: "On it"}},
+			incoming: []Clipping{highlight},
+			want:     []Clipping{{LocStart: 100, LocEnd: 104, Text: "A highlight", Note: "On it"}},
+		},
+		{
+			name:     "extended highlight",
+			existing: []Clipping{{LocStart: 100, LocEnd: 104, Text: "A highlight", Note: "On it"}},
+			incoming: []Clipping{{LocStart: 100, LocEnd: 110, Text: "A highlight, made longer"}},
+			want:     []Clipping{{LocStart: 100, LocEnd: 110, Text: "A highlight, made longer", Note: "On it"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mergeClippings(tt.existing, tt.incoming); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got  %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
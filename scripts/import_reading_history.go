@@ -0,0 +1,334 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/consumed"
+)
+
+// ReadingEntry is one row of a Goodreads or StoryGraph export, normalized
+type ReadingEntry struct {
+	Title    string
+	Author   string
+	ISBN13   string
+	Rating   float64
+	Started  time.Time // StoryGraph only; Goodreads doesn't export it
+	DateRead time.Time
+	Added    time.Time
+	Status   string   // read, currently-reading, to-read, did-not-finish
+	Shelves  []string // non-exclusive shelves / tags
+	Review   string
+}
+
+func main() {
+	var format, statuses, langs string
+	flag.StringVar(&format, "format", "auto", "Export format: goodreads, storygraph or auto")
+	flag.StringVar(&statuses, "status", "read", "Comma-separated read statuses to import (read, currently-reading, to-read, did-not-finish) or \"all\"")
+	flag.StringVar(&langs, "lang", "", "Comma-separated languages to create pages for (default: every content/<lang>/consumed)")
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		fmt.Println("Usage: go run scripts/import_reading_history.go [flags] <export.csv>")
+		flag.PrintDefaults()
+		os.Exit(1)
+	}
+
+	baseDir := getBaseDir()
+	contentDir := filepath.Join(baseDir, "content")
+
+	languages, err := consumed.Languages(contentDir)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if langs != "" {
+		languages = splitList(langs)
+	}
+
+	f, err := os.Open(flag.Arg(0))
+	if err != nil {
+		fmt.Printf("Error opening export: %v\n", err)
+		os.Exit(1)
+	}
+	defer f.Close()
+
+	entries, detected, err := readExport(f, format)
+	if err != nil {
+		fmt.Printf("Error reading export: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Read %d rows from %s export\n\n", len(entries), detected)
+
+	existing, err := consumed.LoadAll(contentDir, "book")
+	if err != nil {
+		fmt.Printf("Error reading book pages: %v\n", err)
+		os.Exit(1)
+	}
+	index := consumed.NewBookIndex(existing)
+
+	wanted := splitList(statuses)
+	created, duplicates, skipped := 0, 0, 0
+
+	for _, entry := range entries {
+		if !statusWanted(entry.Status, wanted) {
+			skipped++
+			continue
+		}
+
+		if match := index.Find(entry.ISBN13, entry.Title); match != "" {
+			fmt.Printf("⏭️  %s (duplicate of %s)\n", entry.Title, match)
+			duplicates++
+			continue
+		}
+
+		slug := consumed.PageSlug(entry.Title)
+		book := newBook(entry)
+		for _, lang := range languages {
+			path := filepath.Join(consumed.Dir(contentDir, lang, "book"), slug+".md")
+			if _, err := os.Stat(path); err == nil {
+				fmt.Printf("  ⚠ %s already exists, leaving it alone\n", path)
+				continue
+			}
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				fmt.Printf("  ✗ Error creating directory: %v\n", err)
+				continue
+			}
+			if err := os.WriteFile(path, []byte(book.Render(lang)), 0644); err != nil {
+				fmt.Printf("  ✗ Error writing %s: %v\n", path, err)
+				continue
+			}
+		}
+		index.Add(entry.ISBN13, entry.Title, slug)
+		fmt.Printf("✓ %s\n", entry.Title)
+		created++
+	}
+
+	// Summary
+	fmt.Printf("\n%s\n", strings.Repeat("=", 50))
+	fmt.Printf("Summary:\n")
+	fmt.Printf("  Created: %d books\n", created)
+	fmt.Printf("  Duplicates: %d\n", duplicates)
+	fmt.Printf("  Skipped by status: %d\n", skipped)
+}
+
+func getBaseDir() string {
+	// Start from current working directory and walk up to find project root
+	wd, _ := os.Getwd()
+	startWd := wd
+	for {
+		if _, err := os.Stat(filepath.Join(wd, "content")); err == nil {
+			return wd
+		}
+		parent := filepath.Dir(wd)
+		if parent == wd {
+			break
+		}
+		wd = parent
+	}
+	return startWd
+}
+
+// readExport parses a Goodreads or StoryGraph CSV export
+func readExport(r io.Reader, format string) ([]ReadingEntry, string, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read header: %w", err)
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))] = i
+	}
+
+	if format == "auto" {
+		switch {
+		case hasColumn(columns, "Exclusive Shelf"):
+			format = "goodreads"
+		case hasColumn(columns, "Read Status"):
+			format = "storygraph"
+		default:
+			return nil, "", fmt.Errorf("could not detect export format from header")
+		}
+	}
+
+	var parse func(func(string) string) ReadingEntry
+	switch format {
+	case "goodreads":
+		parse = parseGoodreadsRow
+	case "storygraph":
+		parse = parseStoryGraphRow
+	default:
+		return nil, "", fmt.Errorf("unknown format: %s", format)
+	}
+
+	var entries []ReadingEntry
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		get := func(name string) string {
+			i, ok := columns[name]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+		entry := parse(get)
+		if entry.Title != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, format, nil
+}
+
+func hasColumn(columns map[string]int, name string) bool {
+	_, ok := columns[name]
+	return ok
+}
+
+func parseGoodreadsRow(get func(string) string) ReadingEntry {
+	rating, _ := strconv.ParseFloat(get("My Rating"), 64)
+
+	var shelves []string
+	for _, shelf := range splitList(get("Bookshelves")) {
+		if shelf != get("Exclusive Shelf") {
+			shelves = append(shelves, shelf)
+		}
+	}
+
+	// Reviews are exported as HTML with <br/> line breaks
+	review := regexp.MustCompile(`(?i)<br\s*/?>`).ReplaceAllString(get("My Review"), "\n")
+
+	return ReadingEntry{
+		Title:    get("Title"),
+		Author:   get("Author"),
+		ISBN13:   consumed.NormalizeISBN(get("ISBN13")),
+		Rating:   rating,
+		DateRead: parseExportDate(get("Date Read")),
+		Added:    parseExportDate(get("Date Added")),
+		Status:   get("Exclusive Shelf"),
+		Shelves:  shelves,
+		Review:   strings.TrimSpace(review),
+	}
+}
+
+func parseStoryGraphRow(get func(string) string) ReadingEntry {
+	rating, _ := strconv.ParseFloat(get("Star Rating"), 64)
+
+	author := get("Authors")
+	if i := strings.Index(author, ","); i != -1 {
+		author = strings.TrimSpace(author[:i])
+	}
+
+	return ReadingEntry{
+		Title:    get("Title"),
+		Author:   author,
+		ISBN13:   consumed.NormalizeISBN(get("ISBN/UID")),
+		Rating:   rating,
+		Started:  lastStarted(get("Dates Read")),
+		DateRead: parseExportDate(get("Last Date Read")),
+		Added:    parseExportDate(get("Date Added")),
+		Status:   get("Read Status"),
+		Shelves:  splitList(get("Tags")),
+		Review:   get("Review"),
+	}
+}
+
+var exportDatePattern = regexp.MustCompile(`\d{4}[/-]\d{1,2}[/-]\d{1,2}`)
+
+// lastStarted returns the start of the last read in StoryGraph's Dates Read,
+// e.g. "2023/01/05-2023/02/01, 2024/03/01-" gives 2024/03/01
+func lastStarted(datesRead string) time.Time {
+	reads := strings.Split(datesRead, ",")
+	return parseExportDate(exportDatePattern.FindString(reads[len(reads)-1]))
+}
+
+func parseExportDate(s string) time.Time {
+	for _, layout := range []string{"2006/01/02", "2006-01-02", "2006/1/2", "01/02/2006"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func splitList(s string) []string {
+	var values []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+func statusWanted(status string, wanted []string) bool {
+	for _, w := range wanted {
+		if w == "all" || strings.EqualFold(w, status) {
+			return true
+		}
+	}
+	return false
+}
+
+// readingStatus maps Goodreads shelves and StoryGraph read statuses to ours
+func readingStatus(status string) consumed.Status {
+	switch strings.ToLower(status) {
+	case "read":
+		return consumed.Finished
+	case "currently-reading":
+		return consumed.Reading
+	case "did-not-finish":
+		return consumed.Abandoned
+	default:
+		return consumed.WantToRead
+	}
+}
+
+// newBook maps an export row to the page that should be created for it
+func newBook(entry ReadingEntry) consumed.NewBook {
+	date := entry.DateRead
+	if date.IsZero() {
+		date = entry.Added
+	}
+	status := readingStatus(entry.Status)
+	// The footer of a book being read says since when; Goodreads only has
+	// the date it was shelved
+	started, finished := entry.Started, entry.DateRead
+	if status == consumed.Reading {
+		if started.IsZero() {
+			started = entry.Added
+		}
+		// A re-read in progress; the last read's date isn't when this one
+		// finished
+		finished = time.Time{}
+	}
+	return consumed.NewBook{
+		Title:    entry.Title,
+		Author:   entry.Author,
+		ISBN:     entry.ISBN13,
+		Status:   status,
+		Date:     date,
+		Started:  started,
+		Finished: finished,
+		Rating:   entry.Rating,
+		Shelves:  entry.Shelves,
+		Body:     entry.Review,
+	}
+}
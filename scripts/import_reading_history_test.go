@@ -0,0 +1,144 @@
+package main
+
+// Tests of reading Goodreads and StoryGraph exports:
+//
+//	go test scripts/import_reading_history.go scripts/import_reading_history_test.go
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/consumed"
+)
+
+const goodreadsExport = "\ufeffBook Id,Title,Author,Author l-f,Additional Authors,ISBN,ISBN13,My Rating,Average Rating,Publisher,Binding,Number of Pages,Year Published,Original Publication Year,Date Read,Date Added,Bookshelves,Bookshelves with positions,Exclusive Shelf,My Review,Spoiler,Private Notes,Read Count,Owned Copies\n" +
+	`25744928,Deep Work: Rules for Focused Success in a Distracted World,Cal Newport,"Newport, Cal",,"=""1455586692""","=""9781455586691""",4,4.19,Grand Central Publishing,Hardcover,296,2016,2016,,2024/02/01,"productivity, currently-reading","productivity (#3), currently-reading (#1)",currently-reading,Focus is a skill.<br/>Train it.,,,0,0` + "\n"
+
+const storyGraphExport = "Title,Authors,Contributors,ISBN/UID,Format,Read Status,Date Added,Last Date Read,Dates Read,Read Count,Moods,Pace,Character- or Plot-Driven?,Strong Character Development?,Loveable Characters?,Diverse Characters?,Flawed Characters?,Star Rating,Review,Content Warnings,Content Warning Description,Tags,Owned?\n" +
+	`Dune,"Frank Herbert, Brian Herbert",,9780441172719,paperback,currently-reading,2023/12/24,2024/01/15,"2023/12/26-2024/01/15, 2024/04/02-",2,"adventurous, challenging",slow,A mix,Yes,Yes,No,Yes,4.5,The spice must flow.,,,"sci-fi, classics",Yes` + "\n"
+
+func date(s string) time.Time {
+	t, _ := time.Parse("2006-01-02", s)
+	return t
+}
+
+func TestReadExport(t *testing.T) {
+	tests := []struct {
+		name, export, format string
+		entry                ReadingEntry
+		book                 consumed.NewBook
+	}{
+		{
+			name:   "goodreads",
+			export: goodreadsExport,
+			format: "goodreads",
+			entry: ReadingEntry{
+				Title:   "Deep Work: Rules for Focused Success in a Distracted World",
+				Author:  "Cal Newport",
+				ISBN13:  "9781455586691",
+				Rating:  4,
+				Added:   date("2024-02-01"),
+				Status:  "currently-reading",
+				Shelves: []string{"productivity"},
+				Review:  "Focus is a skill.\nTrain it.",
+			},
+			book: consumed.NewBook{
+				Title:   "Deep Work: Rules for Focused Success in a Distracted World",
+				Author:  "Cal Newport",
+				ISBN:    "9781455586691",
+				Status:  consumed.Reading,
+				Date:    date("2024-02-01"),
+				Started: date("2024-02-01"),
+				Rating:  4,
+				Shelves: []string{"productivity"},
+				Body:    "Focus is a skill.\nTrain it.",
+			},
+		},
+		{
+			name:   "storygraph",
+			export: storyGraphExport,
+			format: "storygraph",
+			entry: ReadingEntry{
+				Title:    "Dune",
+				Author:   "Frank Herbert",
+				ISBN13:   "9780441172719",
+				Rating:   4.5,
+				Started:  date("2024-04-02"),
+				DateRead: date("2024-01-15"),
+				Added:    date("2023-12-24"),
+				Status:   "currently-reading",
+				Shelves:  []string{"sci-fi", "classics"},
+				Review:   "The spice must flow.",
+			},
+			book: consumed.NewBook{
+				Title:   "Dune",
+				Author:  "Frank Herbert",
+				ISBN:    "9780441172719",
+				Status:  consumed.Reading,
+				Date:    date("2024-01-15"),
+				Started: date("2024-04-02"),
+				Rating:  4.5,
+				Shelves: []string{"sci-fi", "classics"},
+				Body:    "The spice must flow.",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, format := range []string{"auto", tt.format} {
+				entries, detected, err := readExport(strings.NewReader(tt.export), format)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if detected != tt.format {
+					t.Errorf("%s: format = %s, want %s", format, detected, tt.format)
+				}
+				if len(entries) != 1 || !reflect.DeepEqual(entries[0], tt.entry) {
+					t.Fatalf("%s: entries = %+v\nwant %+v", format, entries, tt.entry)
+				}
+			}
+			entries, _, _ := readExport(strings.NewReader(tt.export), "auto")
+			if book := newBook(entries[0]); !reflect.DeepEqual(book, tt.book) {
+				t.Errorf("book = %+v\nwant %+v", book, tt.book)
+			}
+		})
+	}
+}
+
+func TestReadExportUnknownFormat(t *testing.T) {
+	if _, _, err := readExport(strings.NewReader("Name,Rating\nDune,5\n"), "auto"); err == nil {
+		t.Error("detected a format from an unknown header")
+	}
+}
+
+func TestReadingStatus(t *testing.T) {
+	tests := map[string]consumed.Status{
+		"read":              consumed.Finished,
+		"Read":              consumed.Finished,
+		"currently-reading": consumed.Reading,
+		"did-not-finish":    consumed.Abandoned,
+		"to-read":           consumed.WantToRead,
+		"":                  consumed.WantToRead,
+	}
+	for status, want := range tests {
+		if got := readingStatus(status); got != want {
+			t.Errorf("readingStatus(%q) = %s, want %s", status, got, want)
+		}
+	}
+}
+
+func TestLastStarted(t *testing.T) {
+	tests := map[string]time.Time{
+		"2023/12/26-2024/01/15":              date("2023-12-26"),
+		"2023/12/26-2024/01/15, 2024/04/02-": date("2024-04-02"),
+		"2023-12-26-2024-01-15":              date("2023-12-26"),
+		"":                                   {},
+	}
+	for datesRead, want := range tests {
+		if got := lastStarted(datesRead); !got.Equal(want) {
+			t.Errorf("lastStarted(%q) = %s, want %s", datesRead, got, want)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+// Package artwork downloads posters and covers for the consumed pages
+package artwork
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg" // register decoders for validation
+	_ "image/png"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/consumed"
+)
+
+const (
+	// MaxBytes caps a single download; real posters and covers are well
+	// under 5 MB
+	MaxBytes = 20 << 20
+	// Attempts is how many times a download is tried before giving up
+	Attempts = 3
+)
+
+// Client is used for every download
+var Client = &http.Client{Timeout: 30 * time.Second}
+
+// retryWait is how long the first retry waits; later ones wait longer
+var retryWait = 2 * time.Second
+
+// errRetry marks failures worth another attempt (network errors, 5xx, 429,
+// truncated bodies)
+var errRetry = errors.New("temporary failure")
+
+// Download fetches an image to path. The body is written to a temporary
+// file in the same directory and only renamed over path once it has been
+// checked: a 200 response, an image (or octet-stream) Content-Type, JPEG or
+// PNG magic bytes, no more than MaxBytes, and an image that decodes.
+// Temporary failures are retried until ctx is cancelled. On error nothing
+// at path is touched.
+func Download(ctx context.Context, url, path string) error {
+	if url == "" {
+		return fmt.Errorf("no URL")
+	}
+
+	var err error
+	for attempt := 0; attempt < Attempts; attempt++ {
+		if attempt > 0 {
+			wait := time.Duration(attempt) * retryWait
+			fmt.Printf("    Retrying in %v...\n", wait)
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		var data []byte
+		data, err = fetch(ctx, url)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if errors.Is(err, errRetry) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err = Validate(data); err != nil {
+			return err
+		}
+		return consumed.WriteFile(path, data)
+	}
+	return err
+}
+
+func fetch(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errRetry, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		return nil, fmt.Errorf("%w: status %d", errRetry, resp.StatusCode)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download failed with status: %d", resp.StatusCode)
+	}
+
+	// Some CDNs serve images as octet-stream; the magic bytes decide then
+	contentType := resp.Header.Get("Content-Type")
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || !(strings.HasPrefix(mediaType, "image/") || strings.HasSuffix(mediaType, "/octet-stream")) {
+		return nil, fmt.Errorf("not an image (Content-Type %q)", contentType)
+	}
+	if resp.ContentLength > MaxBytes {
+		return nil, fmt.Errorf("image too large (%d bytes)", resp.ContentLength)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, MaxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errRetry, err)
+	}
+	if len(data) > MaxBytes {
+		return nil, fmt.Errorf("image too large (over %d bytes)", MaxBytes)
+	}
+	if resp.ContentLength >= 0 && int64(len(data)) != resp.ContentLength {
+		return nil, fmt.Errorf("%w: got %d of %d bytes", errRetry, len(data), resp.ContentLength)
+	}
+	return data, nil
+}
+
+// Validate checks that data is a complete JPEG or PNG image
+func Validate(data []byte) error {
+	switch detected := http.DetectContentType(data); detected {
+	case "image/jpeg", "image/png":
+	default:
+		return fmt.Errorf("not a JPEG or PNG image (looks like %s)", detected)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("invalid image: %w", err)
+	}
+	if b := img.Bounds(); b.Dx() == 0 || b.Dy() == 0 {
+		return fmt.Errorf("invalid image: empty")
+	}
+	return nil
+}
+
+// Save writes image data, e.g. a cover taken out of an EPUB, to path once
+// Validate accepts it. Like Download, it never leaves a partial file.
+func Save(path string, data []byte) error {
+	if err := Validate(data); err != nil {
+		return err
+	}
+	return consumed.WriteFile(path, data)
+}
@@ -0,0 +1,294 @@
+package artwork
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func init() {
+	retryWait = time.Millisecond
+}
+
+func testImage() image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 3))
+	img.Set(1, 1, color.RGBA{200, 10, 10, 255})
+	return img
+}
+
+func testJPEG(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, testImage(), nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func testPNG(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, testImage()); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// serve answers with status, Content-Type and body, counting requests
+func serve(status int, contentType string, body []byte) (http.HandlerFunc, *int32) {
+	var requests int32
+	return func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("Content-Type", contentType)
+		w.WriteHeader(status)
+		w.Write(body)
+	}, &requests
+}
+
+// files lists what is left in dir
+func files(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return names
+}
+
+func TestDownload(t *testing.T) {
+	jpg, pngData := testJPEG(t), testPNG(t)
+	tests := []struct {
+		name        string
+		status      int
+		contentType string
+		body        []byte
+	}{
+		{"jpeg", http.StatusOK, "image/jpeg", jpg},
+		{"png with parameters", http.StatusOK, "image/png; charset=binary", pngData},
+		{"octet-stream", http.StatusOK, "application/octet-stream", jpg},
+		{"mislabelled image type", http.StatusOK, "image/webp", pngData},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, _ := serve(tt.status, tt.contentType, tt.body)
+			srv := httptest.NewServer(handler)
+			defer srv.Close()
+
+			path := filepath.Join(t.TempDir(), "poster.jpg")
+			if err := Download(context.Background(), srv.URL, path); err != nil {
+				t.Fatal(err)
+			}
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, tt.body) {
+				t.Errorf("wrote %d bytes, want the %d served", len(got), len(tt.body))
+			}
+			if names := files(t, filepath.Dir(path)); len(names) != 1 {
+				t.Errorf("files left: %v", names)
+			}
+		})
+	}
+}
+
+func TestDownloadRejects(t *testing.T) {
+	jpg := testJPEG(t)
+	tests := []struct {
+		name        string
+		status      int
+		contentType string
+		body        []byte
+		want        string
+		requests    int32
+	}{
+		{"html", http.StatusOK, "text/html", []byte("<html>Not found</html>"), "not an image", 1},
+		{"no content type", http.StatusOK, "", jpg, "not an image", 1},
+		{"bad magic bytes", http.StatusOK, "image/jpeg", []byte("GIF89a not really"), "not a JPEG or PNG", 1},
+		{"truncated jpeg", http.StatusOK, "image/jpeg", jpg[:len(jpg)/2], "invalid image", 1},
+		{"png signature with garbage", http.StatusOK, "image/png", append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{7}, 64)...), "invalid image", 1},
+		{"not found", http.StatusNotFound, "image/jpeg", jpg, "status: 404", 1},
+		{"server error every time", http.StatusInternalServerError, "text/plain", nil, "status 500", Attempts},
+		{"rate limited every time", http.StatusTooManyRequests, "text/plain", nil, "status 429", Attempts},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, requests := serve(tt.status, tt.contentType, tt.body)
+			srv := httptest.NewServer(handler)
+			defer srv.Close()
+
+			dir := t.TempDir()
+			path := filepath.Join(dir, "poster.jpg")
+			err := Download(context.Background(), srv.URL, path)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want %q", err, tt.want)
+			}
+			if *requests != tt.requests {
+				t.Errorf("%d requests, want %d", *requests, tt.requests)
+			}
+			if names := files(t, dir); len(names) != 0 {
+				t.Errorf("files left after a failure: %v", names)
+			}
+		})
+	}
+}
+
+func TestDownloadTooLarge(t *testing.T) {
+	big := append(testJPEG(t), make([]byte, MaxBytes)...)
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{"Content-Length", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "image/jpeg")
+			w.Header().Set("Content-Length", strconv.Itoa(len(big)))
+			w.Write(big)
+		}},
+		{"chunked body", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "image/jpeg")
+			w.(http.Flusher).Flush() // no Content-Length from here on
+			w.Write(big)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(tt.handler)
+			defer srv.Close()
+
+			dir := t.TempDir()
+			err := Download(context.Background(), srv.URL, filepath.Join(dir, "poster.jpg"))
+			if err == nil || !strings.Contains(err.Error(), "too large") {
+				t.Errorf("error = %v, want too large", err)
+			}
+			if names := files(t, dir); len(names) != 0 {
+				t.Errorf("files left after a failure: %v", names)
+			}
+		})
+	}
+}
+
+func TestDownloadRetries(t *testing.T) {
+	jpg := testJPEG(t)
+	tests := []struct {
+		name    string
+		failure func(w http.ResponseWriter)
+	}{
+		{"server error", func(w http.ResponseWriter) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}},
+		{"rate limited", func(w http.ResponseWriter) {
+			w.WriteHeader(http.StatusTooManyRequests)
+		}},
+		{"truncated body", func(w http.ResponseWriter) {
+			// Promise the whole image and hang up halfway through
+			conn, buf, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				panic(err)
+			}
+			defer conn.Close()
+			buf.WriteString("HTTP/1.1 200 OK\r\nContent-Type: image/jpeg\r\nContent-Length: " + strconv.Itoa(len(jpg)) + "\r\n\r\n")
+			buf.Write(jpg[:len(jpg)/2])
+			buf.Flush()
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if atomic.AddInt32(&requests, 1) < Attempts {
+					tt.failure(w)
+					return
+				}
+				w.Header().Set("Content-Type", "image/jpeg")
+				w.Write(jpg)
+			}))
+			defer srv.Close()
+
+			path := filepath.Join(t.TempDir(), "poster.jpg")
+			if err := Download(context.Background(), srv.URL, path); err != nil {
+				t.Fatal(err)
+			}
+			if requests != Attempts {
+				t.Errorf("%d requests, want %d", requests, Attempts)
+			}
+			if got, _ := os.ReadFile(path); !bytes.Equal(got, jpg) {
+				t.Errorf("wrote %d bytes, want the %d served", len(got), len(jpg))
+			}
+		})
+	}
+}
+
+func TestDownloadKeepsExistingFile(t *testing.T) {
+	handler, _ := serve(http.StatusOK, "image/jpeg", []byte("GIF89a not really"))
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "poster.jpg")
+	if err := os.WriteFile(path, []byte("old poster"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Download(context.Background(), srv.URL, path); err == nil {
+		t.Fatal("bad image downloaded")
+	}
+	if got, _ := os.ReadFile(path); string(got) != "old poster" {
+		t.Errorf("existing poster changed to %q", got)
+	}
+	if names := files(t, dir); len(names) != 1 {
+		t.Errorf("files left: %v", names)
+	}
+}
+
+func TestDownloadCancelled(t *testing.T) {
+	handler, requests := serve(http.StatusServiceUnavailable, "text/plain", nil)
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	saved := retryWait
+	retryWait = time.Hour
+	defer func() { retryWait = saved }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	if err := Download(ctx, srv.URL, filepath.Join(t.TempDir(), "poster.jpg")); err != context.Canceled {
+		t.Errorf("error = %v, want %v", err, context.Canceled)
+	}
+	if *requests != 1 {
+		t.Errorf("%d requests, want 1", *requests)
+	}
+}
+
+func TestSave(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "cover.png")
+	if err := Save(path, []byte("<svg/>")); err == nil {
+		t.Error("saved an SVG")
+	}
+	if names := files(t, dir); len(names) != 0 {
+		t.Errorf("files left after a failure: %v", names)
+	}
+
+	data := testPNG(t)
+	if err := Save(path, data); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(path); !bytes.Equal(got, data) {
+		t.Error("saved cover differs")
+	}
+}
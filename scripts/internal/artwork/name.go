@@ -0,0 +1,51 @@
+package artwork
+
+import (
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/slug"
+)
+
+var (
+	yearPattern = regexp.MustCompile(`\b(\d{4})\b`)
+	idPattern   = regexp.MustCompile(`^[A-Za-z0-9]+`)
+)
+
+// Key tells apart works with the same title: the release year, or the
+// provider's ID (TMDB, Discogs, Open Library) when the year is unknown.
+// It is empty if neither is known.
+func Key(year, id string) string {
+	if m := yearPattern.FindStringSubmatch(year); m != nil {
+		return m[1]
+	}
+	return strings.ToLower(idPattern.FindString(id))
+}
+
+// Slug returns the slug artwork filenames start with, e.g. the_kingdom
+func Slug(title string) string {
+	return slug.Make(title, slug.Underscore)
+}
+
+// Filename returns the file name of a poster or cover from the title's
+// slug and its Key, e.g. the_kingdom_1994_poster.jpg. Without a key it is
+// the old slug-only name.
+func Filename(titleSlug, key, suffix, ext string) string {
+	if key == "" {
+		return titleSlug + "_" + suffix + ext
+	}
+	return titleSlug + "_" + key + "_" + suffix + ext
+}
+
+// ProviderID returns the ID in a provider URL: the last path segment up to
+// the first dash, so https://www.discogs.com/release/1941316-Kryptic-Minds-768
+// gives 1941316
+func ProviderID(providerURL string) string {
+	u, err := url.Parse(providerURL)
+	if err != nil || u.Path == "" {
+		return ""
+	}
+	return idPattern.FindString(path.Base(strings.TrimSuffix(u.Path, "/")))
+}
@@ -0,0 +1,256 @@
+// Package changes holds what a download script wants to do to a page and its
+// artwork until it is applied, so the change can be shown as a diff first:
+// with -dry-run nothing is written, with -interactive each change is
+// accepted or rejected.
+package changes
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/consumed"
+)
+
+// Change is an update of one page (or books.toml) and the artwork it uses
+type Change struct {
+	Path   string                               // file to update; "" for artwork only
+	Update func(content string) (string, error) // the file's new content
+	Files  []File
+}
+
+// File is artwork a change puts in place. Staged is where it was downloaded
+// to; "" when nothing was downloaded (dry runs, artwork kept offline).
+type File struct {
+	Path   string
+	Staged string
+}
+
+// StagedPath is where artwork for path is downloaded before the change is
+// accepted: a hidden file next to it, cover.jpg becoming .cover.staged.jpg.
+// The extension stays last so the image can be read for its placeholder.
+func StagedPath(path string) string {
+	ext := filepath.Ext(path)
+	name := strings.TrimSuffix(filepath.Base(path), ext)
+	return filepath.Join(filepath.Dir(path), "."+name+".staged"+ext)
+}
+
+// Current is the file to read the artwork from: the staged download if
+// there is one
+func (f File) Current() string {
+	if f.Staged != "" {
+		if _, err := os.Stat(f.Staged); err == nil {
+			return f.Staged
+		}
+	}
+	return f.Path
+}
+
+// Recorder keeps what a change replaces, so the run can be undone. The run
+// journal is one.
+type Recorder interface {
+	Backup(path string) error  // called before path is replaced
+	Written(path string) error // called after
+}
+
+// Reviewer applies changes, or only shows them
+type Reviewer struct {
+	DryRun      bool // print diffs, write nothing
+	Interactive bool // print diffs and ask before applying each one
+	BaseDir     string
+	In          io.Reader // answers in interactive mode; os.Stdin if nil
+	Out         io.Writer // os.Stdout if nil
+	Recorder    Recorder  // nil to keep no backups
+
+	in   *bufio.Reader
+	all  bool // the rest was accepted
+	quit bool // the rest was rejected
+}
+
+// Stopped reports whether the rest of the changes were rejected with "q",
+// so there's no point in looking up more titles
+func (r *Reviewer) Stopped() bool {
+	return r.quit
+}
+
+// Apply shows a change if it should, and applies it unless this is a dry
+// run or it was rejected. It reports whether the change was applied.
+// Rejected changes' staged artwork is removed, and so is a failed one's,
+// with the page put back if it was already written.
+func (r *Reviewer) Apply(c *Change) (bool, error) {
+	out := r.Out
+	if out == nil {
+		out = os.Stdout
+	}
+
+	var old, updated string
+	if c.Path != "" {
+		data, err := os.ReadFile(c.Path)
+		if err != nil {
+			Discard(c)
+			return false, err
+		}
+		old = string(data)
+		if updated, err = c.Update(old); err != nil {
+			Discard(c)
+			return false, err
+		}
+	}
+
+	if r.DryRun || r.Interactive {
+		fmt.Fprint(out, r.Diff(c, old, updated))
+	}
+	if r.DryRun {
+		Discard(c)
+		return false, nil
+	}
+	if r.Interactive && !r.accept(out) {
+		Discard(c)
+		return false, nil
+	}
+
+	// The page first: if it can't be written, no artwork has been replaced
+	pageWritten := c.Path != "" && updated != old
+	if pageWritten {
+		err := r.record(c.Path, func() error {
+			return consumed.WriteFile(c.Path, []byte(updated))
+		})
+		if err != nil {
+			Discard(c)
+			return false, err
+		}
+	}
+	for _, f := range c.Files {
+		if f.Staged == "" {
+			continue
+		}
+		if _, err := os.Stat(f.Staged); err != nil {
+			continue
+		}
+		err := r.record(f.Path, func() error {
+			return os.Rename(f.Staged, f.Path)
+		})
+		if err != nil {
+			Discard(c)
+			if pageWritten {
+				// Put the page back, so it doesn't point at artwork that
+				// isn't there
+				r.record(c.Path, func() error {
+					return consumed.WriteFile(c.Path, []byte(old))
+				})
+			}
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// record replaces path with write, keeping a backup first if there's a
+// Recorder
+func (r *Reviewer) record(path string, write func() error) error {
+	if r.Recorder == nil {
+		return write()
+	}
+	if err := r.Recorder.Backup(path); err != nil {
+		return fmt.Errorf("backing up %s: %w", r.rel(path), err)
+	}
+	if err := write(); err != nil {
+		return err
+	}
+	return r.Recorder.Written(path)
+}
+
+// Diff renders a change: a unified diff of the file and a line per artwork
+// file
+func (r *Reviewer) Diff(c *Change, old, updated string) string {
+	var out strings.Builder
+	if c.Path != "" {
+		name := r.rel(c.Path)
+		if d := Unified("a/"+name, "b/"+name, old, updated); d != "" {
+			out.WriteString(d)
+		} else {
+			fmt.Fprintf(&out, "  (no changes to %s)\n", name)
+		}
+	}
+	for _, f := range c.Files {
+		_, err := os.Stat(f.Path)
+		exists := err == nil
+		var verb string
+		switch {
+		case f.Current() != f.Path && exists:
+			verb = "replaces the existing file"
+		case f.Current() != f.Path:
+			verb = "new file"
+		case r.DryRun && exists:
+			verb = "would be downloaded again"
+		case r.DryRun:
+			verb = "would be downloaded"
+		default:
+			verb = "kept"
+		}
+		fmt.Fprintf(&out, "  artwork: %s (%s)\n", r.rel(f.Path), verb)
+	}
+	return out.String()
+}
+
+// accept asks whether to apply the change just shown. "a" accepts it and
+// all later ones, "q" rejects it and all later ones.
+func (r *Reviewer) accept(out io.Writer) bool {
+	if r.all {
+		return true
+	}
+	if r.quit {
+		return false
+	}
+	if r.in == nil {
+		in := r.In
+		if in == nil {
+			in = os.Stdin
+		}
+		r.in = bufio.NewReader(in)
+	}
+	for {
+		fmt.Fprint(out, "Apply this change? [y]es, [n]o, [a]ll, [q]uit: ")
+		answer, err := r.in.ReadString('\n')
+		if err != nil && answer == "" {
+			// No more input: reject what's left
+			fmt.Fprintln(out)
+			r.quit = true
+			return false
+		}
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "y", "yes":
+			return true
+		case "n", "no", "":
+			return false
+		case "a", "all":
+			r.all = true
+			return true
+		case "q", "quit":
+			r.quit = true
+			return false
+		}
+	}
+}
+
+// Discard removes a change's staged artwork, for changes that won't be
+// applied
+func Discard(c *Change) {
+	for _, f := range c.Files {
+		if f.Staged != "" {
+			os.Remove(f.Staged)
+		}
+	}
+}
+
+func (r *Reviewer) rel(path string) string {
+	if r.BaseDir != "" {
+		if rel, err := filepath.Rel(r.BaseDir, path); err == nil {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return filepath.ToSlash(path)
+}
@@ -0,0 +1,226 @@
+package changes
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// site is a page and a staged poster for it
+type site struct {
+	dir, page, poster string
+}
+
+func newSite(t *testing.T) site {
+	t.Helper()
+	dir := t.TempDir()
+	s := site{
+		dir:    dir,
+		page:   filepath.Join(dir, "bunny.md"),
+		poster: filepath.Join(dir, "bunny_2025_poster.jpg"),
+	}
+	write(t, s.page, "title = \"Bunny\"\nyear = \"\"\n")
+	return s
+}
+
+// change sets the year and stages a new poster
+func (s site) change(t *testing.T) *Change {
+	t.Helper()
+	staged := StagedPath(s.poster)
+	write(t, staged, "new poster")
+	return &Change{
+		Path: s.page,
+		Update: func(content string) (string, error) {
+			return strings.Replace(content, `year = ""`, `year = "2025"`, 1), nil
+		},
+		Files: []File{{Path: s.poster, Staged: staged}},
+	}
+}
+
+func write(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func read(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "<" + err.Error() + ">"
+	}
+	return string(data)
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func TestStagedPath(t *testing.T) {
+	if got, want := StagedPath(filepath.Join("static", "cover.jpg")), filepath.Join("static", ".cover.staged.jpg"); got != want {
+		t.Errorf("StagedPath = %s, want %s", got, want)
+	}
+}
+
+func TestApply(t *testing.T) {
+	s := newSite(t)
+	c := s.change(t)
+	r := &Reviewer{BaseDir: s.dir, Out: &bytes.Buffer{}}
+	if applied, err := r.Apply(c); !applied || err != nil {
+		t.Fatalf("Apply = %v, %v", applied, err)
+	}
+	if got := read(s.page); got != "title = \"Bunny\"\nyear = \"2025\"\n" {
+		t.Errorf("page = %q", got)
+	}
+	if got := read(s.poster); got != "new poster" {
+		t.Errorf("poster = %q", got)
+	}
+	if exists(c.Files[0].Staged) {
+		t.Error("staged poster left behind")
+	}
+}
+
+func TestApplyDryRun(t *testing.T) {
+	s := newSite(t)
+	c := s.change(t)
+	var out bytes.Buffer
+	r := &Reviewer{DryRun: true, BaseDir: s.dir, Out: &out}
+	if applied, err := r.Apply(c); applied || err != nil {
+		t.Fatalf("Apply = %v, %v, want nothing applied", applied, err)
+	}
+	if got := read(s.page); got != "title = \"Bunny\"\nyear = \"\"\n" {
+		t.Errorf("page written: %q", got)
+	}
+	if exists(s.poster) || exists(c.Files[0].Staged) {
+		t.Error("poster written or staged poster kept")
+	}
+	for _, want := range []string{"--- a/bunny.md", `+year = "2025"`, "artwork: bunny_2025_poster.jpg (new file)"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output doesn't show %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestApplyInteractive(t *testing.T) {
+	tests := []struct {
+		name, answers string
+		want          []bool // applied, per change
+		stopped       bool
+	}{
+		{"yes and no", "y\nn\nyes\n", []bool{true, false, true}, false},
+		{"unknown answers are asked again", "maybe\n\ny\nwhat\nn\nY\n", []bool{false, true, false}, false},
+		{"all", "n\na\n", []bool{false, true, true}, false},
+		{"quit", "y\nq\n", []bool{true, false, false}, true},
+		{"end of input", "y\n", []bool{true, false, false}, true},
+		{"last answer without a newline", "n\ny", []bool{false, true, false}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			r := &Reviewer{Interactive: true, In: strings.NewReader(tt.answers), Out: &out}
+			for i, want := range tt.want {
+				s := newSite(t)
+				c := s.change(t)
+				applied, err := r.Apply(c)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if applied != want {
+					t.Errorf("change %d: applied = %v, want %v", i, applied, want)
+				}
+				if written := exists(s.poster); written != want {
+					t.Errorf("change %d: poster written = %v, want %v", i, written, want)
+				}
+				if exists(c.Files[0].Staged) {
+					t.Errorf("change %d: staged poster left behind", i)
+				}
+			}
+			if r.Stopped() != tt.stopped {
+				t.Errorf("Stopped = %v, want %v", r.Stopped(), tt.stopped)
+			}
+		})
+	}
+}
+
+// recorder records the calls, failing Backup of fail
+type recorder struct {
+	calls []string
+	fail  string
+}
+
+func (r *recorder) Backup(path string) error {
+	r.calls = append(r.calls, "backup "+filepath.Base(path))
+	if filepath.Base(path) == r.fail {
+		return errors.New("disk full")
+	}
+	return nil
+}
+
+func (r *recorder) Written(path string) error {
+	r.calls = append(r.calls, "written "+filepath.Base(path))
+	return nil
+}
+
+func TestApplyRecords(t *testing.T) {
+	s := newSite(t)
+	rec := &recorder{}
+	r := &Reviewer{Out: &bytes.Buffer{}, Recorder: rec}
+	if _, err := r.Apply(s.change(t)); err != nil {
+		t.Fatal(err)
+	}
+	want := "backup bunny.md,written bunny.md,backup bunny_2025_poster.jpg,written bunny_2025_poster.jpg"
+	if got := strings.Join(rec.calls, ","); got != want {
+		t.Errorf("calls = %s, want %s", got, want)
+	}
+}
+
+func TestApplyPageFails(t *testing.T) {
+	s := newSite(t)
+	c := s.change(t)
+	r := &Reviewer{Out: &bytes.Buffer{}, Recorder: &recorder{fail: "bunny.md"}}
+	if applied, err := r.Apply(c); applied || err == nil {
+		t.Fatalf("Apply = %v, %v, want an error", applied, err)
+	}
+	if exists(s.poster) {
+		t.Error("poster put in place for a page that wasn't written")
+	}
+	if exists(c.Files[0].Staged) {
+		t.Error("staged poster left behind")
+	}
+}
+
+func TestApplyArtworkFails(t *testing.T) {
+	s := newSite(t)
+	c := s.change(t)
+	// The poster can't be moved into a directory that doesn't exist
+	c.Files = append(c.Files, File{Path: filepath.Join(s.dir, "missing", "bunny_dithered.jpg"), Staged: StagedPath(s.poster)})
+	c.Files[0], c.Files[1] = c.Files[1], c.Files[0]
+
+	r := &Reviewer{Out: &bytes.Buffer{}}
+	if applied, err := r.Apply(c); applied || err == nil {
+		t.Fatalf("Apply = %v, %v, want an error", applied, err)
+	}
+	if got := read(s.page); got != "title = \"Bunny\"\nyear = \"\"\n" {
+		t.Errorf("page not put back: %q", got)
+	}
+	if exists(StagedPath(s.poster)) {
+		t.Error("staged poster left behind")
+	}
+}
+
+func TestApplyUpdateFails(t *testing.T) {
+	s := newSite(t)
+	c := s.change(t)
+	c.Update = func(string) (string, error) { return "", errors.New("bad page") }
+	r := &Reviewer{Out: &bytes.Buffer{}}
+	if applied, err := r.Apply(c); applied || err == nil {
+		t.Fatalf("Apply = %v, %v, want an error", applied, err)
+	}
+	if exists(s.poster) || exists(c.Files[0].Staged) {
+		t.Error("poster written or staged poster kept")
+	}
+}
@@ -0,0 +1,145 @@
+package changes
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Context is the number of unchanged lines shown around each change
+const Context = 3
+
+// Unified returns a unified diff of a and b, or "" if they are the same.
+// The names go in the --- and +++ header lines.
+func Unified(oldName, newName, a, b string) string {
+	if a == b {
+		return ""
+	}
+	x, y := splitLines(a), splitLines(b)
+	ops := diffLines(x, y)
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", oldName, newName)
+	for start := 0; start < len(ops); {
+		// Find the next change, then extend the hunk while changes are
+		// closer than two contexts apart
+		first := start
+		for first < len(ops) && ops[first].kind == ' ' {
+			first++
+		}
+		if first == len(ops) {
+			break
+		}
+		last := first
+		for i := first; i < len(ops); i++ {
+			if ops[i].kind != ' ' {
+				last = i
+			} else if i-last > 2*Context {
+				break
+			}
+		}
+		from := max(first-Context, start)
+		to := min(last+Context+1, len(ops))
+
+		oldStart, newStart := ops[from].x+1, ops[from].y+1
+		oldCount, newCount := 0, 0
+		for _, op := range ops[from:to] {
+			if op.kind != '+' {
+				oldCount++
+			}
+			if op.kind != '-' {
+				newCount++
+			}
+		}
+		if oldCount == 0 {
+			oldStart--
+		}
+		if newCount == 0 {
+			newStart--
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(oldStart, oldCount), hunkRange(newStart, newCount))
+		for _, op := range ops[from:to] {
+			out.WriteByte(op.kind)
+			out.WriteString(op.text)
+			out.WriteByte('\n')
+			if op.noNewline {
+				out.WriteString("\\ No newline at end of file\n")
+			}
+		}
+		start = to
+	}
+	return out.String()
+}
+
+func hunkRange(start, count int) string {
+	if count == 1 {
+		return fmt.Sprint(start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
+
+// line is a line of input; last lines without a newline are marked
+type line struct {
+	text      string
+	noNewline bool
+}
+
+func splitLines(s string) []line {
+	if s == "" {
+		return nil
+	}
+	parts := strings.SplitAfter(s, "\n")
+	if parts[len(parts)-1] == "" {
+		parts = parts[:len(parts)-1]
+	}
+	lines := make([]line, len(parts))
+	for i, p := range parts {
+		lines[i] = line{text: strings.TrimSuffix(p, "\n"), noNewline: !strings.HasSuffix(p, "\n")}
+	}
+	return lines
+}
+
+// op is one line of the diff: kept (' '), removed ('-') or added ('+'),
+// with its 0-based position in the old and new text
+type op struct {
+	kind      byte
+	text      string
+	noNewline bool
+	x, y      int
+}
+
+// diffLines aligns x and y on a longest common subsequence. Pages are a few
+// dozen lines, so the quadratic table is fine.
+func diffLines(x, y []line) []op {
+	n, m := len(x), len(y)
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var ops []op
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && x[i] == y[j]:
+			ops = append(ops, op{' ', x[i].text, x[i].noNewline, i, j})
+			i++
+			j++
+		case j == m || i < n && lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, op{'-', x[i].text, x[i].noNewline, i, j})
+			i++
+		default:
+			ops = append(ops, op{'+', y[j].text, y[j].noNewline, i, j})
+			j++
+		}
+	}
+	return ops
+}
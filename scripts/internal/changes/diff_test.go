@@ -0,0 +1,154 @@
+package changes
+
+import (
+	"fmt"
+	"math/rand"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestUnified(t *testing.T) {
+	tests := []struct {
+		name, a, b, want string
+	}{
+		{name: "same", a: "x\ny\n", b: "x\ny\n", want: ""},
+		{
+			name: "changed line",
+			a:    "title = \"Dune\"\nyear = \"\"\nrating = 4\n",
+			b:    "title = \"Dune\"\nyear = \"1965\"\nrating = 4\n",
+			want: "--- a/dune.md\n+++ b/dune.md\n@@ -1,3 +1,3 @@\n title = \"Dune\"\n-year = \"\"\n+year = \"1965\"\n rating = 4\n",
+		},
+		{
+			name: "new file",
+			a:    "",
+			b:    "one\ntwo\n",
+			want: "--- a/dune.md\n+++ b/dune.md\n@@ -0,0 +1,2 @@\n+one\n+two\n",
+		},
+		{
+			name: "newline added at the end",
+			a:    "one\ntwo",
+			b:    "one\ntwo\n",
+			want: "--- a/dune.md\n+++ b/dune.md\n@@ -1,2 +1,2 @@\n one\n-two\n\\ No newline at end of file\n+two\n",
+		},
+		{
+			name: "two hunks",
+			a:    "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+			b:    "one\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\ntwelve\n",
+			want: "--- a/dune.md\n+++ b/dune.md\n@@ -1,4 +1,4 @@\n-1\n+one\n 2\n 3\n 4\n@@ -9,4 +9,4 @@\n 9\n 10\n 11\n-12\n+twelve\n",
+		},
+	}
+	for _, tt := range tests {
+		if got := Unified("a/dune.md", "b/dune.md", tt.a, tt.b); got != tt.want {
+			t.Errorf("%s:\n%s\nwant:\n%s", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestUnifiedApplies checks that applying the diff of random texts to the
+// first gives the second
+func TestUnifiedApplies(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	random := func() string {
+		var b strings.Builder
+		for i := rng.Intn(30); i > 0; i-- {
+			// Few distinct lines, so there is plenty to align
+			fmt.Fprintf(&b, "line %d\n", rng.Intn(6))
+		}
+		s := b.String()
+		if rng.Intn(4) == 0 {
+			s = strings.TrimSuffix(s, "\n")
+		}
+		return s
+	}
+	for i := 0; i < 2000; i++ {
+		a, b := random(), random()
+		diff := Unified("a", "b", a, b)
+		got, err := patch(a, diff)
+		if err != nil {
+			t.Fatalf("%v\na: %q\nb: %q\ndiff:\n%s", err, a, b, diff)
+		}
+		if got != b {
+			t.Fatalf("patched to %q, want %q\ndiff:\n%s", got, b, diff)
+		}
+	}
+}
+
+var hunkHeader = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@$`)
+
+// patch applies a unified diff strictly: hunk counts and every context
+// and removed line must match
+func patch(a, diff string) (string, error) {
+	if diff == "" {
+		return a, nil
+	}
+	old := splitLines(a)
+	lines := strings.Split(strings.TrimSuffix(diff, "\n"), "\n")
+	if len(lines) < 2 || !strings.HasPrefix(lines[0], "--- ") || !strings.HasPrefix(lines[1], "+++ ") {
+		return "", fmt.Errorf("no header")
+	}
+	var out []line
+	next := 0 // next old line to copy
+	for i := 2; i < len(lines); {
+		m := hunkHeader.FindStringSubmatch(lines[i])
+		if m == nil {
+			return "", fmt.Errorf("line %d: not a hunk header: %q", i, lines[i])
+		}
+		oldStart, _ := strconv.Atoi(m[1])
+		oldCount, newCount := 1, 1
+		if m[2] != "" {
+			oldCount, _ = strconv.Atoi(m[2])
+		}
+		if m[4] != "" {
+			newCount, _ = strconv.Atoi(m[4])
+		}
+		if oldCount > 0 {
+			oldStart--
+		}
+		if oldStart < next {
+			return "", fmt.Errorf("hunk at %d overlaps the last one", oldStart+1)
+		}
+		out = append(out, old[next:oldStart]...)
+		next = oldStart
+
+		removed, added := 0, 0
+		for i++; i < len(lines) && !strings.HasPrefix(lines[i], "@@"); i++ {
+			l := line{text: lines[i][1:]}
+			if i+1 < len(lines) && lines[i+1] == "\\ No newline at end of file" {
+				l.noNewline = true
+			}
+			switch lines[i][0] {
+			case ' ', '-':
+				if next >= len(old) || old[next] != l {
+					return "", fmt.Errorf("line %d doesn't match %q", next+1, lines[i])
+				}
+				next++
+				removed++
+				if lines[i][0] == ' ' {
+					out = append(out, l)
+					added++
+				}
+			case '+':
+				out = append(out, l)
+				added++
+			case '\\':
+			default:
+				return "", fmt.Errorf("bad line %q", lines[i])
+			}
+		}
+		if removed != oldCount || added != newCount {
+			return "", fmt.Errorf("hunk -%d,%d +%d: got %d and %d lines", oldStart+1, oldCount, newCount, removed, added)
+		}
+	}
+	out = append(out, old[next:]...)
+
+	var b strings.Builder
+	for _, l := range out {
+		b.WriteString(l.text)
+		if !l.noNewline {
+			b.WriteByte('\n')
+		}
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,185 @@
+package consumed
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/slug"
+)
+
+// NewBook describes a book page to be created by one of the importers
+type NewBook struct {
+	Title     string
+	Author    string
+	Year      string
+	Publisher string
+	ISBN      string
+	Img       string
+	Date      time.Time // page date; defaults to Finished, then today
+	Status    Status    // optional; the footer is derived from it
+	Started   time.Time
+	Finished  time.Time
+	Rating    float64
+	Shelves   []string
+	Draft     bool
+	Body      string
+}
+
+// Render builds the page content for one language
+func (b NewBook) Render(lang string) string {
+	date := b.Date
+	if date.IsZero() {
+		date = b.Finished
+	}
+	if date.IsZero() {
+		date = time.Now()
+	}
+
+	var s strings.Builder
+	s.WriteString("+++\n")
+	fmt.Fprintf(&s, "title = %s\n", QuoteString(b.Title))
+	fmt.Fprintf(&s, "date = %s\n", date.Format("2006-01-02"))
+	fmt.Fprintf(&s, "draft = %t\n\n", b.Draft)
+	s.WriteString("category = \"book\"\n")
+	writeString := func(key, value string) {
+		if value != "" {
+			fmt.Fprintf(&s, "%s = %s\n", key, QuoteString(value))
+		}
+	}
+	writeString("year", b.Year)
+	writeString("author", b.Author)
+	writeString("publisher", b.Publisher)
+	writeString("isbn", b.ISBN)
+	writeString("img", b.Img)
+	writeString("status", string(b.Status))
+	if !b.Started.IsZero() {
+		fmt.Fprintf(&s, "started = %s\n", b.Started.Format("2006-01-02"))
+	}
+	if !b.Finished.IsZero() {
+		if b.Status != "" {
+			fmt.Fprintf(&s, "finished = %s\n", b.Finished.Format("2006-01-02"))
+		}
+		writeString("footer", Footer("book", lang, b.Finished))
+	}
+	if b.Rating > 0 {
+		fmt.Fprintf(&s, "rating = %s\n", strconv.FormatFloat(b.Rating, 'f', -1, 64))
+	}
+	if len(b.Shelves) > 0 {
+		fmt.Fprintf(&s, "shelves = %s\n", QuoteStrings(b.Shelves))
+	}
+	s.WriteString("+++\n")
+	if body := strings.TrimSpace(b.Body); body != "" {
+		s.WriteString("\n" + body + "\n")
+	}
+
+	page, _ := Parse(s.String())
+	page.Lang = lang
+	if b.Status != "" {
+		if footer := BookFooter(page, lang); footer != "" {
+			page.SetString("footer", footer, "finished", "started", "status")
+		}
+	}
+	return string(page.Bytes())
+}
+
+// BookIndex finds existing book pages by ISBN or title, to avoid importing
+// the same book twice
+type BookIndex struct {
+	byISBN  map[string]string
+	byTitle map[string]string
+}
+
+// NewBookIndex indexes the given book pages by slug
+func NewBookIndex(pages []*Page) *BookIndex {
+	idx := &BookIndex{byISBN: map[string]string{}, byTitle: map[string]string{}}
+	for _, page := range pages {
+		idx.Add(page.String("isbn"), page.String("title"), page.Slug())
+		idx.byTitle[TitleKey(strings.ReplaceAll(page.Slug(), "-", " "))] = page.Slug()
+	}
+	return idx
+}
+
+// Find returns the slug of a page with the same ISBN or title, or ""
+func (idx *BookIndex) Find(isbn, title string) string {
+	if isbn != "" {
+		if slug, ok := idx.byISBN[isbn]; ok {
+			return slug
+		}
+	}
+	if slug, ok := idx.byTitle[TitleKey(title)]; ok {
+		return slug
+	}
+	return idx.byTitle[TitleKey(PageSlug(title))]
+}
+
+// Add records a page so later lookups in the same run find it
+func (idx *BookIndex) Add(isbn, title, slug string) {
+	if isbn != "" {
+		idx.byISBN[isbn] = slug
+	}
+	if key := TitleKey(title); key != "" {
+		idx.byTitle[key] = slug
+	}
+}
+
+// PageSlug builds a content filename (and so the page URL) from a title
+func PageSlug(title string) string {
+	return slug.Make(title, slug.Hyphen)
+}
+
+// NormalizeISBN returns the ISBN-13 form of an ISBN-10 or ISBN-13, ignoring
+// hyphens, spaces and prefixes such as "urn:isbn:". It returns "" for
+// anything that is not an ISBN.
+func NormalizeISBN(raw string) string {
+	raw = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(raw)), "urn:isbn:")
+	isbn := strings.ToUpper(regexp.MustCompile(`[^0-9xX]`).ReplaceAllString(raw, ""))
+	switch len(isbn) {
+	case 13:
+		if strings.HasPrefix(isbn, "978") || strings.HasPrefix(isbn, "979") {
+			return isbn
+		}
+	case 10:
+		if strings.Contains(isbn[:9], "X") {
+			return ""
+		}
+		core := "978" + isbn[:9]
+		sum := 0
+		for i, r := range core {
+			d := int(r - '0')
+			if i%2 == 1 {
+				d *= 3
+			}
+			sum += d
+		}
+		return core + strconv.Itoa((10-sum%10)%10)
+	}
+	return ""
+}
+
+var subtitleSeparator = regexp.MustCompile(`\s*:\s+`)
+
+// SplitTitle splits "Can't Hurt Me: Master Your Mind and Defy the Odds" into
+// its title and subtitle. Titles without a colon come back unchanged.
+func SplitTitle(title string) (string, string) {
+	parts := subtitleSeparator.Split(strings.TrimSpace(title), 2)
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		return strings.TrimSpace(title), ""
+	}
+	return parts[0], parts[1]
+}
+
+var seriesSuffix = regexp.MustCompile(`^(.*?)\s*\(([^()]+?),?\s+#(\d+(?:\.\d+)?)\)$`)
+
+// SplitSeries splits the series suffix Goodreads and Open Library add to
+// titles, e.g. "Dune (Dune Chronicles, #1)" gives "Dune", "Dune Chronicles",
+// "1". Titles without one come back unchanged.
+func SplitSeries(title string) (string, string, string) {
+	m := seriesSuffix.FindStringSubmatch(strings.TrimSpace(title))
+	if m == nil {
+		return strings.TrimSpace(title), "", ""
+	}
+	return m[1], m[2], m[3]
+}
@@ -0,0 +1,24 @@
+package consumed
+
+import (
+	"fmt"
+	"time"
+)
+
+// footerVerbs holds the per-language verb used in footers such as
+// footer = "Read Sep 2025" / footer = "Leído Sep 2025"
+var footerVerbs = map[string]map[string]string{
+	"book":  {"en": "Read", "es": "Leído"},
+	"movie": {"en": "Watched", "es": "Visto"},
+	"music": {"en": "Listened", "es": "Escuchado"},
+}
+
+// Footer renders the footer line for a page. Month abbreviations are English
+// in every language, matching the existing pages.
+func Footer(kind, lang string, t time.Time) string {
+	verb := footerVerbs[kind][lang]
+	if verb == "" {
+		verb = footerVerbs[kind]["en"]
+	}
+	return fmt.Sprintf("%s %s", verb, t.Format("Jan 2006"))
+}
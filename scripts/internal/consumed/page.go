@@ -0,0 +1,294 @@
+// Package consumed reads and writes the TOML-fronted pages that live under
+// content/<lang>/consumed/<kind>/ (kind is "book", "movie" or "music").
+package consumed
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/slug"
+)
+
+// Page is a single consumed page split into frontmatter and body
+type Page struct {
+	Path        string
+	Lang        string
+	Frontmatter string // text between the +++ delimiters, including surrounding newlines
+	Body        string // everything after the closing +++
+}
+
+// Load reads a page from disk
+func Load(path string) (*Page, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	page, err := Parse(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	page.Path = path
+	return page, nil
+}
+
+// Parse splits page content into frontmatter and body
+func Parse(content string) (*Page, error) {
+	start := strings.Index(content, "+++")
+	if start == -1 {
+		return nil, fmt.Errorf("no frontmatter found")
+	}
+	end := strings.Index(content[start+3:], "+++")
+	if end == -1 {
+		return nil, fmt.Errorf("no closing frontmatter found")
+	}
+	end += start + 3
+	return &Page{
+		Frontmatter: content[start+3 : end],
+		Body:        content[end+3:],
+	}, nil
+}
+
+// Bytes renders the page back to its on-disk form
+func (p *Page) Bytes() []byte {
+	return []byte("+++" + p.Frontmatter + "+++" + p.Body)
+}
+
+// Save writes the page back to p.Path
+func (p *Page) Save() error {
+	return WriteFile(p.Path, p.Bytes())
+}
+
+// WriteFile replaces a page (or an image) through a temporary file in the
+// same directory, so a run that is interrupted mid-write leaves either the
+// old file or the new one, never half of it. A replaced file keeps its
+// permissions; a new one gets 0644.
+func WriteFile(path string, data []byte) error {
+	mode := os.FileMode(0644)
+	if st, err := os.Stat(path); err == nil {
+		mode = st.Mode().Perm()
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Slug is the page filename without its extension
+func (p *Page) Slug() string {
+	return strings.TrimSuffix(filepath.Base(p.Path), ".md")
+}
+
+func keyPattern(key string) *regexp.Regexp {
+	return regexp.MustCompile(`(?m)^` + regexp.QuoteMeta(key) + `\s*=\s*(.*)$`)
+}
+
+// Raw returns the unparsed TOML value of a top-level key
+func (p *Page) Raw(key string) (string, bool) {
+	m := keyPattern(key).FindStringSubmatch(p.Frontmatter)
+	if m == nil {
+		return "", false
+	}
+	return strings.TrimSpace(m[1]), true
+}
+
+// String returns a string field, or "" when it is missing
+func (p *Page) String(key string) string {
+	raw, ok := p.Raw(key)
+	if !ok {
+		return ""
+	}
+	s, err := UnquoteString(raw)
+	if err != nil {
+		return ""
+	}
+	return s
+}
+
+// Strings returns a string array field such as tags = ["a", "b"]
+func (p *Page) Strings(key string) []string {
+	raw, ok := p.Raw(key)
+	if !ok || !strings.HasPrefix(raw, "[") {
+		return nil
+	}
+	var values []string
+	for _, m := range regexp.MustCompile(`"((?:[^"\\]|\\.)*)"`).FindAllString(raw, -1) {
+		if s, err := UnquoteString(m); err == nil {
+			values = append(values, s)
+		}
+	}
+	return values
+}
+
+// Float returns a numeric field such as rating = 3.5
+func (p *Page) Float(key string) (float64, bool) {
+	raw, ok := p.Raw(key)
+	if !ok {
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(strings.Trim(raw, `"`), 64)
+	if err != nil {
+		return 0, false
+	}
+	return f, true
+}
+
+// Bool returns a boolean field such as processed = true
+func (p *Page) Bool(key string) bool {
+	raw, _ := p.Raw(key)
+	return raw == "true" || raw == "yes"
+}
+
+// Set replaces the value of key, or inserts "key = value" after the first
+// existing key in after. value must already be valid TOML.
+func (p *Page) Set(key, value string, after ...string) {
+	line := key + " = " + value
+	re := keyPattern(key)
+	if re.MatchString(p.Frontmatter) {
+		p.Frontmatter = re.ReplaceAllLiteralString(p.Frontmatter, line)
+		return
+	}
+	for _, a := range after {
+		loc := keyPattern(a).FindStringIndex(p.Frontmatter)
+		if loc != nil {
+			p.Frontmatter = p.Frontmatter[:loc[1]] + "\n" + line + p.Frontmatter[loc[1]:]
+			return
+		}
+	}
+	// At the end, keeping the blank lines that separate books.toml blocks
+	trimmed := strings.TrimRight(p.Frontmatter, "\n")
+	trailing := p.Frontmatter[len(trimmed):]
+	if trailing == "" {
+		trailing = "\n"
+	}
+	p.Frontmatter = trimmed + "\n" + line + trailing
+}
+
+// SetString sets a string field
+func (p *Page) SetString(key, value string, after ...string) {
+	p.Set(key, QuoteString(value), after...)
+}
+
+// SetStrings sets a string array field
+func (p *Page) SetStrings(key string, values []string, after ...string) {
+	p.Set(key, QuoteStrings(values), after...)
+}
+
+// Delete removes a top-level key
+func (p *Page) Delete(key string) {
+	p.Frontmatter = regexp.MustCompile(`(?m)^`+regexp.QuoteMeta(key)+`\s*=.*\n?`).ReplaceAllString(p.Frontmatter, "")
+}
+
+// QuoteString renders s as a TOML basic string
+func QuoteString(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+	return `"` + r.Replace(s) + `"`
+}
+
+// QuoteStrings renders values as a TOML array of strings
+func QuoteStrings(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = QuoteString(v)
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
+
+// UnquoteString parses a TOML basic or literal string
+func UnquoteString(raw string) (string, error) {
+	if strings.HasPrefix(raw, "'") && strings.HasSuffix(raw, "'") && len(raw) >= 2 {
+		return raw[1 : len(raw)-1], nil
+	}
+	if !strings.HasPrefix(raw, `"`) {
+		return "", fmt.Errorf("not a string: %s", raw)
+	}
+	end := strings.LastIndex(raw, `"`)
+	if end == 0 {
+		return "", fmt.Errorf("unterminated string: %s", raw)
+	}
+	r := strings.NewReplacer(`\\`, `\`, `\"`, `"`, `\n`, "\n", `\r`, "\r", `\t`, "\t")
+	return r.Replace(raw[1:end]), nil
+}
+
+// Languages lists the language directories under contentDir that have a
+// consumed section, e.g. ["en", "es"]
+func Languages(contentDir string) ([]string, error) {
+	entries, err := os.ReadDir(contentDir)
+	if err != nil {
+		return nil, err
+	}
+	var langs []string
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(contentDir, e.Name(), "consumed")); err == nil {
+			langs = append(langs, e.Name())
+		}
+	}
+	sort.Strings(langs)
+	if len(langs) == 0 {
+		return nil, fmt.Errorf("no content/<lang>/consumed directories found in %s", contentDir)
+	}
+	return langs, nil
+}
+
+// Dir is the directory holding pages of one kind for one language
+func Dir(contentDir, lang, kind string) string {
+	return filepath.Join(contentDir, lang, "consumed", kind)
+}
+
+// LoadAll reads every page of a kind across all languages
+func LoadAll(contentDir, kind string) ([]*Page, error) {
+	langs, err := Languages(contentDir)
+	if err != nil {
+		return nil, err
+	}
+	var pages []*Page
+	for _, lang := range langs {
+		files, err := filepath.Glob(filepath.Join(Dir(contentDir, lang, kind), "*.md"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(files)
+		for _, f := range files {
+			page, err := Load(f)
+			if err != nil {
+				continue
+			}
+			page.Lang = lang
+			pages = append(pages, page)
+		}
+	}
+	return pages, nil
+}
+
+// TitleKey reduces a title to a comparison key, ignoring case, accents,
+// punctuation and anything after a subtitle colon
+func TitleKey(title string) string {
+	if i := strings.Index(title, ":"); i != -1 {
+		title = title[:i]
+	}
+	return strings.ReplaceAll(slug.Make(title, slug.Hyphen), "-", "")
+}
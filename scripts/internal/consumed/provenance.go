@@ -0,0 +1,162 @@
+package consumed
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// Locked reports whether field is listed in the page's locked array, e.g.
+// locked = ["year", "img"]. The download scripts leave locked fields alone.
+func (p *Page) Locked(field string) bool {
+	for _, locked := range p.Strings("locked") {
+		if locked == field {
+			return true
+		}
+	}
+	return false
+}
+
+// ClearLocked empties the values about to be written to locked fields, so
+// an update made from them leaves those fields alone. fields maps keys to
+// the values.
+func (p *Page) ClearLocked(fields map[string]*string) {
+	for key, value := range fields {
+		if p.Locked(key) {
+			*value = ""
+		}
+	}
+}
+
+// Source is where the value of a field came from
+type Source struct {
+	Provider string    // "tmdb", "discogs", "google-books" or "openlibrary"
+	ID       string    // the provider's ID of the work
+	Fetched  time.Time // when the provider sent it
+}
+
+// provenanceKey is the dotted key a field's source is kept under. Dotted
+// keys make a provenance table without a [provenance] header, which would
+// have to come after every other key.
+func provenanceKey(field string) string {
+	return "provenance." + field
+}
+
+var (
+	sourcePattern  = regexp.MustCompile(`source\s*=\s*("(?:[^"\\]|\\.)*")`)
+	idPattern      = regexp.MustCompile(`\bid\s*=\s*("(?:[^"\\]|\\.)*")`)
+	fetchedPattern = regexp.MustCompile(`fetched\s*=\s*([0-9TZ:+.-]+)`)
+)
+
+// Provenance returns where the value of field came from, if it was recorded
+func (p *Page) Provenance(field string) (Source, bool) {
+	raw, ok := p.Raw(provenanceKey(field))
+	if !ok {
+		return Source{}, false
+	}
+	var s Source
+	if m := sourcePattern.FindStringSubmatch(raw); m != nil {
+		s.Provider, _ = UnquoteString(m[1])
+	}
+	if m := idPattern.FindStringSubmatch(raw); m != nil {
+		s.ID, _ = UnquoteString(m[1])
+	}
+	if m := fetchedPattern.FindStringSubmatch(raw); m != nil {
+		s.Fetched, _ = time.Parse(time.RFC3339, m[1])
+	}
+	return s, s.Provider != ""
+}
+
+// SetProvenance records where the value of field came from:
+// provenance.year = { source = "tmdb", id = "1422004", fetched = 2025-11-20T18:04:05Z }
+func (p *Page) SetProvenance(field string, s Source) {
+	value := fmt.Sprintf("{ source = %s, id = %s, fetched = %s }",
+		QuoteString(s.Provider), QuoteString(s.ID), s.Fetched.UTC().Format(time.RFC3339))
+
+	// Keep the table together, after the fields
+	var after []string
+	for _, m := range regexp.MustCompile(`(?m)^(provenance\.[A-Za-z0-9_-]+)\s*=`).FindAllStringSubmatch(p.Frontmatter, -1) {
+		after = append([]string{m[1]}, after...)
+	}
+	p.Set(provenanceKey(field), value, after...)
+}
+
+// RecordProvenance notes src as the source of each of fields whose value
+// differs from before, or already came from src's provider (so its fetch
+// time moves on). Values the update didn't come up with, such as a year
+// kept from the page, don't get a source.
+func (p *Page) RecordProvenance(before *Page, src Source, fields ...string) {
+	if src.Provider == "" {
+		return
+	}
+	for _, field := range fields {
+		value, ok := p.Raw(field)
+		if !ok || value == `""` || p.Locked(field) {
+			continue
+		}
+		old, _ := before.Raw(field)
+		prev, recorded := before.Provenance(field)
+		if value != old || recorded && prev.Provider == src.Provider {
+			p.SetProvenance(field, src)
+		}
+	}
+}
+
+// OldestSource returns the provenance entry fetched longest ago: how old the
+// page's fetched data is, and where it came from
+func (p *Page) OldestSource() (Source, bool) {
+	var oldest Source
+	found := false
+	for _, m := range regexp.MustCompile(`(?m)^provenance\.([A-Za-z0-9_-]+)\s*=`).FindAllStringSubmatch(p.Frontmatter, -1) {
+		s, ok := p.Provenance(m[1])
+		if ok && (!found || s.Fetched.Before(oldest.Fetched)) {
+			oldest, found = s, true
+		}
+	}
+	return oldest, found
+}
+
+// DueForRefresh reports whether the page's fetched data is older than
+// maxAge, or its age was never recorded, and says why
+func (p *Page) DueForRefresh(maxAge time.Duration, now time.Time) (bool, string) {
+	src, ok := p.OldestSource()
+	if !ok {
+		return true, "no provenance recorded"
+	}
+	return src.Due(maxAge, now)
+}
+
+// Due reports whether data fetched from s is older than maxAge, and says
+// how old it is
+func (s Source) Due(maxAge time.Duration, now time.Time) (bool, string) {
+	age := now.Sub(s.Fetched)
+	why := fmt.Sprintf("fetched %s, %d day(s) ago", s.Fetched.Format("2006-01-02"), int(age.Hours()/24))
+	return age > maxAge, why
+}
+
+// ChangedFields describes the fields whose value differs between before and
+// p, one per line like `year: "2024" → "2025"`. Provenance is left out.
+func (p *Page) ChangedFields(before *Page) []string {
+	var changed []string
+	seen := make(map[string]bool)
+	for _, page := range []*Page{before, p} {
+		for _, m := range regexp.MustCompile(`(?m)^([A-Za-z0-9_-]+)\s*=`).FindAllStringSubmatch(page.Frontmatter, -1) {
+			key := m[1]
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			old, hadOld := before.Raw(key)
+			value, hasValue := p.Raw(key)
+			switch {
+			case !hadOld:
+				changed = append(changed, fmt.Sprintf("%s: added %s", key, value))
+			case !hasValue:
+				changed = append(changed, fmt.Sprintf("%s: removed (was %s)", key, old))
+			case old != value:
+				changed = append(changed, fmt.Sprintf("%s: %s → %s", key, old, value))
+			}
+		}
+	}
+	return changed
+}
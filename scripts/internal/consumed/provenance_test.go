@@ -0,0 +1,202 @@
+package consumed
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	fetched = time.Date(2025, 11, 20, 18, 4, 5, 0, time.UTC)
+	earlier = time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+)
+
+// update applies values to frontmatter the way the download scripts do:
+// locked values are cleared, the rest set, and their sources recorded
+func update(frontmatter string, src Source, values map[string]string) *Page {
+	before := &Page{Frontmatter: frontmatter}
+	fields := make(map[string]*string)
+	var keys []string
+	for key, value := range values {
+		value := value
+		fields[key] = &value
+		keys = append(keys, key)
+	}
+	before.ClearLocked(fields)
+
+	p := &Page{Frontmatter: frontmatter}
+	for _, key := range keys {
+		if *fields[key] != "" {
+			p.SetString(key, *fields[key], "title")
+		}
+	}
+	p.RecordProvenance(before, src, keys...)
+	return p
+}
+
+func TestRecordProvenanceLocked(t *testing.T) {
+	tests := []struct {
+		name        string
+		frontmatter string
+		src         Source
+		values      map[string]string
+		locked      map[string]string // locked field -> value it keeps
+		recorded    []string
+	}{
+		{
+			name:        "movie",
+			frontmatter: "\ntitle = \"Bunny\"\ncategory = \"movie\"\nyear = \"1999\"\nlocked = [\"year\"]\n",
+			src:         Source{Provider: "tmdb", ID: "1422004", Fetched: fetched},
+			values:      map[string]string{"year": "2025", "director": "Jane Doe", "img": "/images/movies/bunny_2025_poster.jpg"},
+			locked:      map[string]string{"year": `"1999"`},
+			recorded:    []string{"director", "img"},
+		},
+		{
+			name:        "music",
+			frontmatter: "\ntitle = \"Blue\"\ncategory = \"music\"\nimg = \"/images/music/mine.jpg\"\nlocked = [\"img\", \"genre\"]\n",
+			src:         Source{Provider: "discogs", ID: "42", Fetched: fetched},
+			values:      map[string]string{"artist": "Joni Mitchell", "year": "1971", "genre": "Folk", "img": "/images/music/blue_1971_cover.jpg"},
+			locked:      map[string]string{"img": `"/images/music/mine.jpg"`, "genre": ""},
+			recorded:    []string{"artist", "year"},
+		},
+		{
+			name:        "book",
+			frontmatter: "\ntitle = \"Dune\"\ncategory = \"book\"\nauthor = \"F. Herbert\"\nyear = \"\"\nlocked = [\"author\", \"year\"]\n",
+			src:         Source{Provider: "openlibrary", ID: "OL1W", Fetched: fetched},
+			values:      map[string]string{"author": "Frank Herbert", "year": "1965", "publisher": "Chilton"},
+			locked:      map[string]string{"author": `"F. Herbert"`, "year": `""`},
+			recorded:    []string{"publisher"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := update(tt.frontmatter, tt.src, tt.values)
+			for field, want := range tt.locked {
+				if got, _ := p.Raw(field); got != want {
+					t.Errorf("locked %s = %s, want %s", field, got, want)
+				}
+				if _, ok := p.Provenance(field); ok {
+					t.Errorf("locked %s got a source", field)
+				}
+			}
+			for _, field := range tt.recorded {
+				if got, ok := p.Provenance(field); !ok || got != tt.src {
+					t.Errorf("provenance of %s = %+v, %v, want %+v", field, got, ok, tt.src)
+				}
+			}
+		})
+	}
+}
+
+func TestRecordProvenance(t *testing.T) {
+	tmdb := Source{Provider: "tmdb", ID: "1", Fetched: fetched}
+	tests := []struct {
+		name        string
+		frontmatter string
+		src         Source
+		want        Source // of year; no Provider for none
+	}{
+		{
+			name:        "changed",
+			frontmatter: "\ntitle = \"Bunny\"\nyear = \"1999\"\n",
+			src:         tmdb,
+			want:        tmdb,
+		},
+		{
+			name:        "new field",
+			frontmatter: "\ntitle = \"Bunny\"\n",
+			src:         tmdb,
+			want:        tmdb,
+		},
+		{
+			name:        "unchanged and never recorded",
+			frontmatter: "\ntitle = \"Bunny\"\nyear = \"2025\"\n",
+			src:         tmdb,
+		},
+		{
+			name:        "unchanged from the same provider",
+			frontmatter: "\ntitle = \"Bunny\"\nyear = \"2025\"\nprovenance.year = { source = \"tmdb\", id = \"1\", fetched = 2025-01-02T03:04:05Z }\n",
+			src:         tmdb,
+			want:        tmdb,
+		},
+		{
+			name:        "unchanged from another provider",
+			frontmatter: "\ntitle = \"Bunny\"\nyear = \"2025\"\nprovenance.year = { source = \"imdb\", id = \"tt1\", fetched = 2025-01-02T03:04:05Z }\n",
+			src:         tmdb,
+			want:        Source{Provider: "imdb", ID: "tt1", Fetched: earlier},
+		},
+		{
+			name:        "no provider",
+			frontmatter: "\ntitle = \"Bunny\"\nyear = \"1999\"\n",
+			src:         Source{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := update(tt.frontmatter, tt.src, map[string]string{"year": "2025", "director": ""})
+			got, ok := p.Provenance("year")
+			if ok != (tt.want.Provider != "") || got != tt.want {
+				t.Errorf("provenance = %+v, %v, want %+v", got, ok, tt.want)
+			}
+			if _, ok := p.Provenance("director"); ok {
+				t.Error("empty director got a source")
+			}
+		})
+	}
+}
+
+func TestSetProvenance(t *testing.T) {
+	p := &Page{Frontmatter: "\ntitle = \"Bunny\"\nyear = \"2025\"\nprovenance.title = { source = \"tmdb\", id = \"1\", fetched = 2025-01-02T03:04:05Z }\n\n[[screenshots]]\nsrc = \"a.jpg\"\n"}
+	tmdb := Source{Provider: "tmdb", ID: "1", Fetched: fetched}
+	p.SetProvenance("year", tmdb)
+	p.SetProvenance("director", Source{Provider: "tmdb", ID: `say "hi"`, Fetched: fetched.In(time.FixedZone("CET", 3600))})
+	p.SetProvenance("title", tmdb)
+	// Later fields of the page are still set before the table
+	p.SetString("director", "Jane Doe", "year")
+
+	want := "\ntitle = \"Bunny\"\nyear = \"2025\"\ndirector = \"Jane Doe\"\n" +
+		"provenance.title = { source = \"tmdb\", id = \"1\", fetched = 2025-11-20T18:04:05Z }\n" +
+		"provenance.year = { source = \"tmdb\", id = \"1\", fetched = 2025-11-20T18:04:05Z }\n" +
+		"provenance.director = { source = \"tmdb\", id = \"say \\\"hi\\\"\", fetched = 2025-11-20T18:04:05Z }\n" +
+		"\n[[screenshots]]\nsrc = \"a.jpg\"\n"
+	if p.Frontmatter != want {
+		t.Errorf("frontmatter:\n%s\nwant:\n%s", p.Frontmatter, want)
+	}
+	if strings.Contains(p.Frontmatter, "[provenance]") {
+		t.Error("provenance written as a table header")
+	}
+	if got, ok := p.Provenance("director"); !ok || got.ID != `say "hi"` || !got.Fetched.Equal(fetched) {
+		t.Errorf("director provenance = %+v, %v", got, ok)
+	}
+
+	// Every provenance key in one block
+	lines := regexp.MustCompile(`(?m)^provenance\.`).FindAllStringIndex(p.Frontmatter, -1)
+	block := p.Frontmatter[lines[0][0]:lines[len(lines)-1][0]]
+	if strings.Count(block, "\n") != len(lines)-1 {
+		t.Errorf("provenance keys split up:\n%s", p.Frontmatter)
+	}
+}
+
+func TestOldestSource(t *testing.T) {
+	p := &Page{Frontmatter: "\ntitle = \"Bunny\"\n"}
+	if _, ok := p.OldestSource(); ok {
+		t.Error("a page without provenance has a source")
+	}
+	if due, why := p.DueForRefresh(time.Hour, fetched); !due || why != "no provenance recorded" {
+		t.Errorf("DueForRefresh = %v, %q", due, why)
+	}
+
+	p.SetProvenance("year", Source{Provider: "tmdb", ID: "1", Fetched: fetched})
+	p.SetProvenance("img", Source{Provider: "tmdb", ID: "1", Fetched: earlier})
+	if got, ok := p.OldestSource(); !ok || !got.Fetched.Equal(earlier) {
+		t.Errorf("OldestSource = %+v, %v, want the one fetched %v", got, ok, earlier)
+	}
+	now := earlier.Add(10 * 24 * time.Hour)
+	if due, why := p.DueForRefresh(30*24*time.Hour, now); due || why != "fetched 2025-01-02, 10 day(s) ago" {
+		t.Errorf("DueForRefresh(30 days) = %v, %q", due, why)
+	}
+	if due, _ := p.DueForRefresh(7*24*time.Hour, now); !due {
+		t.Error("data 10 days old not due after 7")
+	}
+}
@@ -0,0 +1,48 @@
+package consumed
+
+import "strings"
+
+// Managed sections are blocks of a page body owned by a script, delimited by
+// HTML comments so Hugo renders nothing for the markers:
+//
+//	<!-- kindle:start -->
+//	...
+//	<!-- kindle:end -->
+
+func sectionMarkers(name string) (string, string) {
+	return "<!-- " + name + ":start -->", "<!-- " + name + ":end -->"
+}
+
+// Section returns the contents of a managed section, without its markers
+func Section(body, name string) (string, bool) {
+	start, end := sectionMarkers(name)
+	i := strings.Index(body, start)
+	if i == -1 {
+		return "", false
+	}
+	j := strings.Index(body[i:], end)
+	if j == -1 {
+		return "", false
+	}
+	return strings.Trim(body[i+len(start):i+j], "\n"), true
+}
+
+// ReplaceSection replaces a managed section's contents, appending the
+// section to the end of the body if it does not exist yet
+func ReplaceSection(body, name, content string) string {
+	start, end := sectionMarkers(name)
+	block := start + "\n" + strings.Trim(content, "\n") + "\n" + end
+
+	i := strings.Index(body, start)
+	if i != -1 {
+		if j := strings.Index(body[i:], end); j != -1 {
+			return body[:i] + block + body[i+j+len(end):]
+		}
+	}
+
+	body = strings.TrimRight(body, "\n")
+	if body == "" {
+		return "\n" + block + "\n"
+	}
+	return body + "\n\n" + block + "\n"
+}
@@ -0,0 +1,183 @@
+package consumed
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// Status is the reading status of a book page
+type Status string
+
+const (
+	WantToRead Status = "want-to-read"
+	Reading    Status = "reading"
+	Finished   Status = "finished"
+	Abandoned  Status = "abandoned"
+)
+
+// transitions lists the statuses a book can move to from each status
+var transitions = map[Status][]Status{
+	WantToRead: {Reading, Finished, Abandoned},
+	Reading:    {Finished, Abandoned, WantToRead},
+	Finished:   {Reading},
+	Abandoned:  {Reading, WantToRead},
+}
+
+// statusFooters holds the per-language footer wording for the statuses
+// other than finished, which uses the usual "Read Sep 2025" footer. The date
+// is appended where the status has one.
+var statusFooters = map[Status]map[string]string{
+	WantToRead: {"en": "Want to read", "es": "Por leer"},
+	Reading:    {"en": "Reading since", "es": "Leyendo desde"},
+	Abandoned:  {"en": "Abandoned", "es": "Abandonado"},
+}
+
+// ParseStatus validates a status name
+func ParseStatus(s string) (Status, error) {
+	status := Status(s)
+	if _, ok := transitions[status]; !ok {
+		return "", fmt.Errorf("unknown status %q (want-to-read, reading, finished, abandoned)", s)
+	}
+	return status, nil
+}
+
+// BookStatus returns the status of a book page. Pages written before the
+// status field existed are finished if they have a footer.
+func BookStatus(p *Page) Status {
+	if status, err := ParseStatus(p.String("status")); err == nil {
+		return status
+	}
+	if p.String("footer") != "" {
+		return Finished
+	}
+	return WantToRead
+}
+
+// CanTransition reports whether a book may move from one status to another
+func CanTransition(from, to Status) bool {
+	for _, s := range transitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+var datePattern = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}`)
+
+// Date returns a TOML date field such as started = 2025-09-01
+func (p *Page) Date(key string) (time.Time, bool) {
+	raw, ok := p.Raw(key)
+	if !ok {
+		return time.Time{}, false
+	}
+	if s, err := UnquoteString(raw); err == nil {
+		raw = s
+	}
+	t, err := time.Parse("2006-01-02", datePattern.FindString(raw))
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// SetDate sets a bare TOML date field
+func (p *Page) SetDate(key string, t time.Time, after ...string) {
+	p.Set(key, t.Format("2006-01-02"), after...)
+}
+
+// Transition moves a book page to a new status on the given date, recording
+// started/finished dates and regenerating the footer for the page language
+func Transition(p *Page, to Status, on time.Time) error {
+	from := BookStatus(p)
+	if from != to && !CanTransition(from, to) {
+		return fmt.Errorf("cannot go from %s to %s", from, to)
+	}
+
+	switch to {
+	case Reading:
+		if from != Reading {
+			p.SetDate("started", on, "category", "date")
+			p.Delete("finished")
+			p.Delete("progress")
+		}
+	case Finished, Abandoned:
+		if _, ok := p.Date("started"); !ok {
+			p.SetDate("started", on, "category", "date")
+		}
+		p.SetDate("finished", on, "started")
+		if to == Finished {
+			// The page date is when it was added; only fill it in if missing
+			if _, ok := p.Raw("date"); !ok {
+				p.SetDate("date", on)
+			}
+			if pages, ok := p.Float("pages"); ok {
+				p.Set("progress", strconv.Itoa(int(pages)), "pages", "finished")
+			}
+		}
+	case WantToRead:
+		p.Delete("started")
+		p.Delete("finished")
+		p.Delete("progress")
+	}
+
+	p.SetString("status", string(to), "category")
+	p.SetString("footer", BookFooter(p, p.Lang), "finished", "started", "status")
+	return nil
+}
+
+// SetProgress records the page a book being read is on
+func SetProgress(p *Page, page int) error {
+	if status := BookStatus(p); status != Reading {
+		return fmt.Errorf("book is %s, not reading", status)
+	}
+	if total, ok := p.Float("pages"); ok && page > int(total) {
+		return fmt.Errorf("page %d is past the last page (%d)", page, int(total))
+	}
+	p.Set("progress", strconv.Itoa(page), "pages", "started", "status")
+	p.SetString("footer", BookFooter(p, p.Lang), "started", "status")
+	return nil
+}
+
+// BookFooter renders the footer for a book page from its status and dates,
+// e.g. "Read Sep 2025", "Leyendo desde Sep 2025 (40%)"
+func BookFooter(p *Page, lang string) string {
+	status := BookStatus(p)
+
+	var date time.Time
+	var ok bool
+	switch status {
+	case Reading:
+		date, ok = p.Date("started")
+	case Finished, Abandoned:
+		date, ok = p.Date("finished")
+	}
+
+	if status == Finished {
+		if !ok {
+			// Keep footers written before dates were tracked
+			return p.String("footer")
+		}
+		return Footer("book", lang, date)
+	}
+
+	words := statusFooters[status][lang]
+	if words == "" {
+		words = statusFooters[status]["en"]
+	}
+	if !ok {
+		return words
+	}
+
+	footer := words + " " + date.Format("Jan 2006")
+	if status == Reading {
+		progress, hasProgress := p.Float("progress")
+		pages, hasPages := p.Float("pages")
+		if hasProgress && hasPages && pages > 0 {
+			footer += fmt.Sprintf(" (%d%%)", int(progress*100/pages))
+		}
+	}
+	return footer
+}
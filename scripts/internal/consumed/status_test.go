@@ -0,0 +1,31 @@
+package consumed
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTransitionFinishedKeepsDate(t *testing.T) {
+	on := time.Date(2025, 10, 3, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name, frontmatter, want string
+	}{
+		{"has a date", "\ntitle = \"Dune\"\ndate = 2024-01-15\nstatus = \"reading\"\nstarted = 2025-09-01\n", "2024-01-15"},
+		{"no date", "\ntitle = \"Dune\"\nstatus = \"reading\"\nstarted = 2025-09-01\n", "2025-10-03"},
+	}
+	for _, tt := range tests {
+		p, err := Parse("+++" + tt.frontmatter + "+++\n")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := Transition(p, Finished, on); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got, _ := p.Raw("date"); got != tt.want {
+			t.Errorf("%s: date = %s, want %s", tt.name, got, tt.want)
+		}
+		if got, _ := p.Raw("finished"); got != "2025-10-03" {
+			t.Errorf("%s: finished = %s, want 2025-10-03", tt.name, got)
+		}
+	}
+}
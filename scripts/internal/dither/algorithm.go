@@ -0,0 +1,184 @@
+package dither
+
+import (
+	"fmt"
+	"image"
+	"strings"
+)
+
+// Algorithm selects how grayscale is reduced to the palette's levels
+type Algorithm string
+
+const (
+	// AlgorithmHard is the original recipe (see Hard); it always produces two
+	// levels and ignores Options.Contrast
+	AlgorithmHard  Algorithm = "hard"
+	FloydSteinberg Algorithm = "floyd-steinberg"
+	Atkinson       Algorithm = "atkinson"
+	Bayer2         Algorithm = "bayer2"
+	Bayer4         Algorithm = "bayer4"
+	Bayer8         Algorithm = "bayer8"
+	BlueNoise      Algorithm = "blue-noise"
+)
+
+// Algorithms lists every algorithm, for usage messages
+var Algorithms = []Algorithm{AlgorithmHard, FloydSteinberg, Atkinson, Bayer2, Bayer4, Bayer8, BlueNoise}
+
+// ParseAlgorithm validates an algorithm name
+func ParseAlgorithm(name string) (Algorithm, error) {
+	for _, a := range Algorithms {
+		if Algorithm(strings.ToLower(strings.TrimSpace(name))) == a {
+			return a, nil
+		}
+	}
+	names := make([]string, len(Algorithms))
+	for i, a := range Algorithms {
+		names[i] = string(a)
+	}
+	return "", fmt.Errorf("unknown algorithm %q (%s)", name, strings.Join(names, ", "))
+}
+
+// Options configures Dither
+type Options struct {
+	Algorithm Algorithm
+	Palette   Palette
+	Contrast  float64 // percent clipped at each end before dithering
+}
+
+// DefaultOptions reproduces the original look
+var DefaultOptions = Options{Algorithm: AlgorithmHard, Palette: OneBit, Contrast: 5}
+
+// Dither renders an image with the given algorithm and palette
+func Dither(img image.Image, opts Options) image.Image {
+	if opts.Palette.Levels < 2 {
+		opts.Palette = OneBit
+	}
+
+	if opts.Algorithm == AlgorithmHard || opts.Algorithm == "" {
+		g := Hard(img)
+		for i, v := range g.Pix {
+			g.Pix[i] = v / 255
+		}
+		return opts.Palette.withLevels(2).apply(g)
+	}
+
+	g := Gray(img)
+	if opts.Contrast > 0 {
+		ContrastStretch(g, opts.Contrast, opts.Contrast)
+	}
+	levels := opts.Palette.Levels
+	switch opts.Algorithm {
+	case FloydSteinberg:
+		diffuse(g, levels, floydSteinberg)
+	case Atkinson:
+		diffuse(g, levels, atkinson)
+	case Bayer2:
+		ordered(g, levels, bayerMatrix(2))
+	case Bayer4:
+		ordered(g, levels, bayerMatrix(4))
+	case Bayer8:
+		ordered(g, levels, bayerMatrix(8))
+	case BlueNoise:
+		ordered(g, levels, blueNoise())
+	}
+	return opts.Palette.apply(g)
+}
+
+// thresholdMap is a square map of ranks 0..size*size-1
+type thresholdMap struct {
+	size  int
+	ranks []int
+}
+
+// bayerMatrix builds the n x n Bayer index matrix (n a power of two)
+func bayerMatrix(n int) thresholdMap {
+	m := thresholdMap{size: 1, ranks: []int{0}}
+	for m.size < n {
+		size := m.size * 2
+		ranks := make([]int, size*size)
+		for y := 0; y < m.size; y++ {
+			for x := 0; x < m.size; x++ {
+				r := 4 * m.ranks[y*m.size+x]
+				ranks[y*size+x] = r
+				ranks[y*size+x+m.size] = r + 2
+				ranks[(y+m.size)*size+x] = r + 3
+				ranks[(y+m.size)*size+x+m.size] = r + 1
+			}
+		}
+		m = thresholdMap{size: size, ranks: ranks}
+	}
+	return m
+}
+
+// ordered replaces every pixel with its level index (0..levels-1), using the
+// threshold map to pick between the two nearest levels
+func ordered(g *image.Gray, levels int, m thresholdMap) {
+	n := len(m.ranks)
+	steps := levels - 1
+	w, h := g.Bounds().Dx(), g.Bounds().Dy()
+	for y := 0; y < h; y++ {
+		row := g.Pix[y*g.Stride : y*g.Stride+w]
+		for x, v := range row {
+			rank := m.ranks[(y%m.size)*m.size+x%m.size]
+			// floor(v*steps/255 + (rank+0.5)/n) in integers
+			level := (2*int(v)*steps*n + (2*rank+1)*255) / (510 * n)
+			if level > steps {
+				level = steps
+			}
+			row[x] = uint8(level)
+		}
+	}
+}
+
+// diffusionKernel spreads the quantization error to neighbours; dx, dy and
+// the weight's share of divisor
+type diffusionKernel struct {
+	divisor int
+	taps    []struct{ dx, dy, weight int }
+}
+
+var floydSteinberg = diffusionKernel{16, []struct{ dx, dy, weight int }{
+	{1, 0, 7}, {-1, 1, 3}, {0, 1, 5}, {1, 1, 1},
+}}
+
+// atkinson only spreads 6/8 of the error, which keeps highlights and shadows
+// clean
+var atkinson = diffusionKernel{8, []struct{ dx, dy, weight int }{
+	{1, 0, 1}, {2, 0, 1}, {-1, 1, 1}, {0, 1, 1}, {1, 1, 1}, {0, 2, 1},
+}}
+
+// diffuse replaces every pixel with its level index (0..levels-1), carrying
+// the rounding error over to unvisited neighbours
+func diffuse(g *image.Gray, levels int, k diffusionKernel) {
+	steps := levels - 1
+	w, h := g.Bounds().Dx(), g.Bounds().Dy()
+	buf := make([]int, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			buf[y*w+x] = int(g.Pix[y*g.Stride+x])
+		}
+	}
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := buf[y*w+x]
+			level := (v*steps*2 + 255) / 510
+			if v < 0 {
+				level = 0
+			}
+			if level > steps {
+				level = steps
+			}
+			g.Pix[y*g.Stride+x] = uint8(level)
+
+			err := v - level*255/steps
+			for _, t := range k.taps {
+				nx, ny := x+t.dx, y+t.dy
+				if nx < 0 || nx >= w || ny >= h {
+					continue
+				}
+				buf[ny*w+nx] += err * t.weight / k.divisor
+			}
+		}
+	}
+}
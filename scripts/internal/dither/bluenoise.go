@@ -0,0 +1,136 @@
+package dither
+
+import (
+	"math"
+	"sync"
+)
+
+const (
+	blueNoiseSize   = 64
+	blueNoiseSigma  = 1.5
+	blueNoiseRadius = 6 // the Gaussian is negligible beyond this
+)
+
+var (
+	blueNoiseOnce sync.Once
+	blueNoiseMap  thresholdMap
+)
+
+// blueNoise returns a 64x64 blue noise threshold map, built once with the
+// void-and-cluster method. Energies are integers and ties go to the lowest
+// index, so the map is the same on every machine.
+func blueNoise() thresholdMap {
+	blueNoiseOnce.Do(func() {
+		blueNoiseMap = voidAndCluster(blueNoiseSize)
+	})
+	return blueNoiseMap
+}
+
+type blueNoiseField struct {
+	size   int
+	kernel []int // (2r+1)^2 Gaussian weights
+	on     []bool
+	energy []int
+}
+
+func (f *blueNoiseField) toggle(i int, on bool) {
+	f.on[i] = on
+	sign := 1
+	if !on {
+		sign = -1
+	}
+	x0, y0 := i%f.size, i/f.size
+	side := 2*blueNoiseRadius + 1
+	for dy := -blueNoiseRadius; dy <= blueNoiseRadius; dy++ {
+		y := (y0 + dy + f.size) % f.size
+		for dx := -blueNoiseRadius; dx <= blueNoiseRadius; dx++ {
+			x := (x0 + dx + f.size) % f.size
+			f.energy[y*f.size+x] += sign * f.kernel[(dy+blueNoiseRadius)*side+dx+blueNoiseRadius]
+		}
+	}
+}
+
+// tightestCluster is the set pixel with the most energy
+func (f *blueNoiseField) tightestCluster() int {
+	best := -1
+	for i, on := range f.on {
+		if on && (best < 0 || f.energy[i] > f.energy[best]) {
+			best = i
+		}
+	}
+	return best
+}
+
+// largestVoid is the unset pixel with the least energy
+func (f *blueNoiseField) largestVoid() int {
+	best := -1
+	for i, on := range f.on {
+		if !on && (best < 0 || f.energy[i] < f.energy[best]) {
+			best = i
+		}
+	}
+	return best
+}
+
+func (f *blueNoiseField) clone() *blueNoiseField {
+	c := *f
+	c.on = append([]bool(nil), f.on...)
+	c.energy = append([]int(nil), f.energy...)
+	return &c
+}
+
+func voidAndCluster(size int) thresholdMap {
+	n := size * size
+	side := 2*blueNoiseRadius + 1
+	kernel := make([]int, side*side)
+	for dy := -blueNoiseRadius; dy <= blueNoiseRadius; dy++ {
+		for dx := -blueNoiseRadius; dx <= blueNoiseRadius; dx++ {
+			d2 := float64(dx*dx + dy*dy)
+			kernel[(dy+blueNoiseRadius)*side+dx+blueNoiseRadius] = int(math.Round(4096 * math.Exp(-d2/(2*blueNoiseSigma*blueNoiseSigma))))
+		}
+	}
+	f := &blueNoiseField{size: size, kernel: kernel, on: make([]bool, n), energy: make([]int, n)}
+
+	// Seed about a tenth of the pixels with a fixed LCG, then move pixels
+	// from the tightest cluster to the largest void until that stops
+	// changing anything
+	seed := uint32(1)
+	ones := n / 10
+	for placed := 0; placed < ones; {
+		seed = seed*1664525 + 1013904223
+		i := int(seed>>8) % n
+		if !f.on[i] {
+			f.toggle(i, true)
+			placed++
+		}
+	}
+	for {
+		cluster := f.tightestCluster()
+		f.toggle(cluster, false)
+		void := f.largestVoid()
+		if void == cluster {
+			f.toggle(cluster, true)
+			break
+		}
+		f.toggle(void, true)
+	}
+
+	ranks := make([]int, n)
+
+	// Rank the initial pixels by removing the tightest cluster each time
+	removing := f.clone()
+	for rank := ones - 1; rank >= 0; rank-- {
+		i := removing.tightestCluster()
+		removing.toggle(i, false)
+		ranks[i] = rank
+	}
+
+	// Rank the rest by filling the largest void each time
+	for rank := ones; rank < n; rank++ {
+		i := f.largestVoid()
+		f.toggle(i, true)
+		ranks[i] = rank
+	}
+
+	return thresholdMap{size: size, ranks: ranks}
+}
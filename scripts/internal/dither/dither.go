@@ -0,0 +1,140 @@
+// Package dither produces the site's lo-fi black and white images in pure
+// Go, so building the site doesn't need ImageMagick. Everything works on 8-bit
+// grayscale with integer arithmetic, so the same input always gives the same
+// output bytes.
+package dither
+
+import (
+	"image"
+	"image/draw"
+)
+
+// bayer4 is the 4x4 ordered dither threshold map (ImageMagick's o4x4),
+// with thresholds 1..16 out of 17
+var bayer4 = [4][4]int{
+	{1, 9, 3, 11},
+	{13, 5, 15, 7},
+	{4, 12, 2, 10},
+	{16, 8, 14, 6},
+}
+
+// Hard applies the site's original recipe, equivalent to
+//
+//	magick in -colorspace Gray -contrast-stretch 5%x5% -normalize \
+//	    -posterize 2 -ordered-dither o4x4 out
+func Hard(img image.Image) *image.Gray {
+	g := Gray(img)
+	ContrastStretch(g, 5, 5)
+	Normalize(g)
+	Posterize(g, 2)
+	Ordered4x4(g)
+	return g
+}
+
+// Gray converts an image to 8-bit grayscale using Rec. 709 luma, as
+// ImageMagick's -colorspace Gray does
+func Gray(img image.Image) *image.Gray {
+	b := img.Bounds()
+	g := image.NewGray(image.Rect(0, 0, b.Dx(), b.Dy()))
+	if src, ok := img.(*image.Gray); ok {
+		draw.Draw(g, g.Bounds(), src, b.Min, draw.Src)
+		return g
+	}
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, gr, bl, a := img.At(x, y).RGBA()
+			// Composite transparent pixels onto white
+			r, gr, bl = r+0xffff-a, gr+0xffff-a, bl+0xffff-a
+			luma := (2126*(r>>8) + 7152*(gr>>8) + 722*(bl>>8) + 5000) / 10000
+			if luma > 255 {
+				luma = 255
+			}
+			g.Pix[(y-b.Min.Y)*g.Stride+(x-b.Min.X)] = uint8(luma)
+		}
+	}
+	return g
+}
+
+// ContrastStretch clips the darkest blackPercent and the brightest
+// whitePercent of pixels and stretches the rest over the full range, like
+// ImageMagick's -contrast-stretch BxW%
+func ContrastStretch(g *image.Gray, blackPercent, whitePercent float64) {
+	var histogram [256]int
+	for _, v := range g.Pix {
+		histogram[v]++
+	}
+	total := len(g.Pix)
+	if total == 0 {
+		return
+	}
+
+	blackCount := int(float64(total) * blackPercent / 100)
+	whiteCount := int(float64(total) * whitePercent / 100)
+
+	black, seen := 0, 0
+	for ; black < 255; black++ {
+		seen += histogram[black]
+		if seen > blackCount {
+			break
+		}
+	}
+	white, seen := 255, 0
+	for ; white > 0; white-- {
+		seen += histogram[white]
+		if seen > whiteCount {
+			break
+		}
+	}
+
+	// When one level covers more than the clipped share (a flat background),
+	// black and white meet and the stretch becomes a hard threshold, as in
+	// ImageMagick
+	var lut [256]uint8
+	for v := range lut {
+		switch {
+		case v <= black:
+			lut[v] = 0
+		case v >= white:
+			lut[v] = 255
+		default:
+			lut[v] = uint8(((v-black)*255*2 + (white - black)) / ((white - black) * 2))
+		}
+	}
+	for i, v := range g.Pix {
+		g.Pix[i] = lut[v]
+	}
+}
+
+// Normalize is ImageMagick's -normalize: a 2% black, 1% white stretch
+func Normalize(g *image.Gray) {
+	ContrastStretch(g, 2, 1)
+}
+
+// Posterize rounds every pixel to the nearest of levels evenly spaced gray
+// levels
+func Posterize(g *image.Gray, levels int) {
+	if levels < 2 {
+		levels = 2
+	}
+	steps := levels - 1
+	for i, v := range g.Pix {
+		q := (int(v)*steps*2 + 255) / 510
+		g.Pix[i] = uint8(q * 255 / steps)
+	}
+}
+
+// Ordered4x4 thresholds every pixel to black or white against the 4x4
+// ordered dither map
+func Ordered4x4(g *image.Gray) {
+	b := g.Bounds()
+	for y := 0; y < b.Dy(); y++ {
+		row := g.Pix[y*g.Stride : y*g.Stride+b.Dx()]
+		for x, v := range row {
+			if int(v)*17 > bayer4[y%4][x%4]*255 {
+				row[x] = 255
+			} else {
+				row[x] = 0
+			}
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package dither
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+
+	_ "golang.org/x/image/webp" // decoder only
+)
+
+// JPEGQuality matches ImageMagick's default when it can't tell the input
+// quality
+const JPEGQuality = 92
+
+// ErrUnsupported is returned for image formats that can't be read, or
+// written (WebP, which has no pure Go encoder)
+var ErrUnsupported = errors.New("unsupported image format")
+
+// Supported reports whether a file can be dithered, by extension
+func Supported(path string) bool {
+	return Encodable(path) || strings.EqualFold(filepath.Ext(path), ".webp")
+}
+
+// Encodable reports whether images can be saved in a file's format, by
+// extension
+func Encodable(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jpg", ".jpeg", ".png":
+		return true
+	}
+	return false
+}
+
+// IsImage reports whether a file looks like an image the site uses
+func IsImage(path string) bool {
+	return Supported(path)
+}
+
+// IsDithered reports whether a file is itself a dithered copy
+func IsDithered(path string) bool {
+	return strings.Contains(filepath.Base(path), "_dithered")
+}
+
+// DitheredPath returns the path of the dithered copy of an image:
+// foo/cover.jpg becomes foo/cover_dithered.jpg. WebP images get a PNG copy,
+// foo/avatar.webp becoming foo/avatar_dithered.png, as there is no WebP
+// encoder.
+func DitheredPath(path string) string {
+	ext := filepath.Ext(path)
+	out := ext
+	if strings.EqualFold(ext, ".webp") {
+		out = ".png"
+	}
+	return strings.TrimSuffix(path, ext) + "_dithered" + out
+}
+
+// Load decodes a JPEG, PNG or WebP file
+func Load(path string) (image.Image, error) {
+	if !Supported(path) {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(path), ErrUnsupported)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+	return img, nil
+}
+
+// Save encodes an image in the format given by the path's extension
+func Save(path string, img image.Image) error {
+	return SaveQuality(path, img, JPEGQuality)
+}
+
+// SaveQuality is Save with the given JPEG quality
+func SaveQuality(path string, img image.Image, quality int) error {
+	if !Encodable(path) {
+		return fmt.Errorf("%s: %w", filepath.Base(path), ErrUnsupported)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".png":
+		err = png.Encode(f, img)
+	default:
+		err = jpeg.Encode(f, img, &jpeg.Options{Quality: quality})
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// File writes the dithered copy of an image next to it and returns its path
+func File(path string, opts Options) (string, error) {
+	img, err := Load(path)
+	if err != nil {
+		return "", err
+	}
+	output := DitheredPath(path)
+	if err := Save(output, Dither(img, opts)); err != nil {
+		return "", err
+	}
+	return output, nil
+}
@@ -0,0 +1,86 @@
+package dither
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/golden"
+)
+
+// ditherTwice dithers a copy of a fixture twice and returns both outputs
+func ditherTwice(t *testing.T, fixture string, opts Options) (string, []byte, []byte) {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", fixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := filepath.Join(t.TempDir(), fixture)
+	if err := os.WriteFile(src, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	var outputs [2][]byte
+	var output string
+	for i := range outputs {
+		output, err = File(src, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if outputs[i], err = os.ReadFile(output); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return filepath.Base(output), outputs[0], outputs[1]
+}
+
+func TestFile(t *testing.T) {
+	tests := []struct {
+		fixture string
+		opts    Options
+		want    string
+	}{
+		{"gradient.png", DefaultOptions, "gradient_dithered.png"},
+		{"gradient.jpg", DefaultOptions, "gradient_dithered.jpg"},
+		{"video.webp", DefaultOptions, "video_dithered.png"},
+		{"gradient.png", Options{Algorithm: FloydSteinberg, Palette: OneBit, Contrast: 5}, "gradient_dithered.png"},
+	}
+	for _, tt := range tests {
+		name := tt.fixture + "/" + string(tt.opts.Algorithm)
+		t.Run(name, func(t *testing.T) {
+			output, first, second := ditherTwice(t, tt.fixture, tt.opts)
+			if output != tt.want {
+				t.Errorf("output = %s, want %s", output, tt.want)
+			}
+			if !bytes.Equal(first, second) {
+				t.Fatal("dithering the same image twice gave different bytes")
+			}
+			golden.CheckBytes(t, filepath.Join("testdata", "golden", string(tt.opts.Algorithm), output), first)
+		})
+	}
+}
+
+func TestDitheredPath(t *testing.T) {
+	tests := map[string]string{
+		"static/img/cover.jpg":   "static/img/cover_dithered.jpg",
+		"static/img/cover.JPEG":  "static/img/cover_dithered.JPEG",
+		"static/img/avatar.png":  "static/img/avatar_dithered.png",
+		"static/img/avatar.webp": "static/img/avatar_dithered.png",
+		"static/img/avatar.WebP": "static/img/avatar_dithered.png",
+	}
+	for path, want := range tests {
+		if got := DitheredPath(path); got != want {
+			t.Errorf("DitheredPath(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestSaveWebP(t *testing.T) {
+	img, err := Load(filepath.Join("testdata", "video.webp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Save(filepath.Join(t.TempDir(), "out.webp"), img); err == nil {
+		t.Error("saving a WebP file succeeded, want ErrUnsupported")
+	}
+}
@@ -0,0 +1,154 @@
+package dither
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// Version is recorded with every output; bump it when a change to the
+// algorithms alters their output, so everything is redone
+const Version = 1
+
+// ManifestFile is where the manifest lives, relative to the site root. It
+// is kept out of git with the rest of .cache; on a fresh clone the
+// committed dithered copies are adopted or redone to the same bytes.
+const ManifestFile = ".cache/dither-manifest.json"
+
+// Entry records what an output was made from
+type Entry struct {
+	Source     string  `json:"source"`
+	SourceHash string  `json:"source_hash"`
+	Algorithm  string  `json:"algorithm"`
+	Palette    string  `json:"palette"`
+	Contrast   float64 `json:"contrast"`
+	Version    int     `json:"version"`
+}
+
+// NewEntry describes an output made from source (with the given content
+// hash) with opts. Paths are stored relative to the site root.
+func NewEntry(source, hash string, opts Options) Entry {
+	e := Entry{
+		Source:     filepath.ToSlash(source),
+		SourceHash: hash,
+		Algorithm:  string(opts.Algorithm),
+		Palette:    opts.Palette.Name,
+		Contrast:   opts.Contrast,
+		Version:    Version,
+	}
+	if opts.Algorithm == AlgorithmHard {
+		// hard has a fixed contrast
+		e.Contrast = 0
+	}
+	return e
+}
+
+// Manifest maps output paths (relative to the site root) to what they were
+// made from, so unchanged images aren't redone
+type Manifest struct {
+	mu      sync.RWMutex
+	path    string
+	Outputs map[string]Entry `json:"outputs"`
+}
+
+// LoadManifest reads a manifest, or starts an empty one if it doesn't exist
+func LoadManifest(path string) (*Manifest, error) {
+	m := &Manifest{path: path, Outputs: map[string]Entry{}}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, err
+	}
+	if m.Outputs == nil {
+		m.Outputs = map[string]Entry{}
+	}
+	return m, nil
+}
+
+// Save writes the manifest with sorted keys, through a temporary file so an
+// interrupted run doesn't leave it truncated
+func (m *Manifest) Save() error {
+	m.mu.RLock()
+	data, err := json.MarshalIndent(m, "", "  ")
+	m.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(m.path), 0755); err != nil {
+		return err
+	}
+	tmp := m.path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, m.path)
+}
+
+// UpToDate reports whether output exists and was made from the same source
+// content with the same settings
+func (m *Manifest) UpToDate(output, outputPath string, want Entry) bool {
+	got, ok := m.Lookup(output)
+	if !ok || got != want {
+		return false
+	}
+	_, err := os.Stat(outputPath)
+	return err == nil
+}
+
+// Lookup returns the entry of an output
+func (m *Manifest) Lookup(output string) (Entry, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	e, ok := m.Outputs[filepath.ToSlash(output)]
+	return e, ok
+}
+
+// Set records an output
+func (m *Manifest) Set(output string, e Entry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Outputs[filepath.ToSlash(output)] = e
+}
+
+// Delete forgets an output
+func (m *Manifest) Delete(output string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.Outputs, filepath.ToSlash(output))
+}
+
+// Paths lists the recorded outputs in sorted order
+func (m *Manifest) Paths() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	paths := make([]string, 0, len(m.Outputs))
+	for path := range m.Outputs {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// HashFile returns the hex SHA-256 of a file's content
+func HashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,95 @@
+package dither
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Override holds per-image settings from a page's frontmatter or a sidecar
+// file. Empty fields keep whatever was set before.
+type Override struct {
+	Algorithm string
+	Palette   string
+	Contrast  string
+	Source    string // where the override came from, for messages
+}
+
+// SidecarPath is the per-image settings file: trees.jpg.dither.toml
+func SidecarPath(imagePath string) string {
+	return imagePath + ".dither.toml"
+}
+
+// ReadSidecar reads the sidecar file of an image, if it has one. It holds
+// plain top-level keys:
+//
+//	algorithm = "atkinson"
+//	palette = "duotone"
+//	contrast = 2
+func ReadSidecar(imagePath string) (Override, bool, error) {
+	path := SidecarPath(imagePath)
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return Override{}, false, nil
+	}
+	if err != nil {
+		return Override{}, false, err
+	}
+	defer f.Close()
+
+	o := Override{Source: path}
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(text, "=")
+		if !ok {
+			return Override{}, false, fmt.Errorf("%s:%d: expected key = value", path, line)
+		}
+		value = strings.Trim(strings.TrimSpace(value), `"'`)
+		switch strings.TrimSpace(key) {
+		case "algorithm":
+			o.Algorithm = value
+		case "palette":
+			o.Palette = value
+		case "contrast":
+			o.Contrast = value
+		default:
+			return Override{}, false, fmt.Errorf("%s:%d: unknown key %q (algorithm, palette, contrast)", path, line, strings.TrimSpace(key))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Override{}, false, err
+	}
+	return o, true, nil
+}
+
+// Apply returns opts with the override's fields replaced
+func (o Override) Apply(opts Options) (Options, error) {
+	if o.Algorithm != "" {
+		algorithm, err := ParseAlgorithm(o.Algorithm)
+		if err != nil {
+			return opts, fmt.Errorf("%s: %w", o.Source, err)
+		}
+		opts.Algorithm = algorithm
+	}
+	if o.Palette != "" {
+		palette, err := ParsePalette(o.Palette)
+		if err != nil {
+			return opts, fmt.Errorf("%s: %w", o.Source, err)
+		}
+		opts.Palette = palette
+	}
+	if o.Contrast != "" {
+		contrast, err := strconv.ParseFloat(o.Contrast, 64)
+		if err != nil || contrast < 0 || contrast >= 50 {
+			return opts, fmt.Errorf("%s: invalid contrast %q, expected a percentage from 0 to 50", o.Source, o.Contrast)
+		}
+		opts.Contrast = contrast
+	}
+	return opts, nil
+}
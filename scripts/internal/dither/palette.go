@@ -0,0 +1,79 @@
+package dither
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strconv"
+	"strings"
+)
+
+// Palette is a ramp of evenly spaced colors from Dark to Light
+type Palette struct {
+	Name   string
+	Levels int
+	Dark   color.RGBA
+	Light  color.RGBA
+}
+
+var (
+	black = color.RGBA{0, 0, 0, 255}
+	white = color.RGBA{255, 255, 255, 255}
+
+	// OneBit is pure black and white
+	OneBit = Palette{Name: "1-bit", Levels: 2, Dark: black, Light: white}
+
+	// Duotone uses the theme's text color (#1a1a1a) and background
+	// (#dfe5db), so images sit on the page without a white box
+	Duotone = Palette{Name: "duotone", Levels: 2,
+		Dark:  color.RGBA{0x1a, 0x1a, 0x1a, 255},
+		Light: color.RGBA{0xdf, 0xe5, 0xdb, 255},
+	}
+)
+
+// ParsePalette reads a palette name: "1-bit", "duotone" or "grayN" with N
+// levels of gray from 2 to 256 (e.g. "gray4")
+func ParsePalette(name string) (Palette, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	switch name {
+	case "1-bit", "1bit", "bw":
+		return OneBit, nil
+	case "duotone":
+		return Duotone, nil
+	}
+	if rest, ok := strings.CutPrefix(name, "gray"); ok {
+		levels, err := strconv.Atoi(rest)
+		if err == nil && levels >= 2 && levels <= 256 {
+			return Palette{Name: name, Levels: levels, Dark: black, Light: white}, nil
+		}
+	}
+	return Palette{}, fmt.Errorf("unknown palette %q (1-bit, duotone, gray2..gray256)", name)
+}
+
+func (p Palette) withLevels(levels int) Palette {
+	p.Levels = levels
+	return p
+}
+
+// apply turns an image of level indexes into the palette's colors. Black to
+// white ramps stay 8-bit grayscale; anything else becomes a paletted image.
+func (p Palette) apply(levels *image.Gray) image.Image {
+	steps := p.Levels - 1
+	if p.Dark == black && p.Light == white {
+		for i, v := range levels.Pix {
+			levels.Pix[i] = uint8(int(v) * 255 / steps)
+		}
+		return levels
+	}
+
+	colors := make(color.Palette, p.Levels)
+	for i := range colors {
+		mix := func(a, b uint8) uint8 {
+			return uint8((int(a)*(steps-i) + int(b)*i + steps/2) / steps)
+		}
+		colors[i] = color.RGBA{mix(p.Dark.R, p.Light.R), mix(p.Dark.G, p.Light.G), mix(p.Dark.B, p.Light.B), 255}
+	}
+	out := image.NewPaletted(levels.Bounds(), colors)
+	copy(out.Pix, levels.Pix)
+	return out
+}
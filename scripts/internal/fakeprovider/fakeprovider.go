@@ -0,0 +1,117 @@
+// Package fakeprovider stands in for the TMDB, Discogs, Google Books and
+// Open Library endpoints the download scripts use. One local HTTP server
+// answers them with recorded responses (fixtures), and posters and covers
+// with generated images, so the scripts run without keys or network once
+// their API bases point at it.
+package fakeprovider
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/httpcache"
+)
+
+// Fixtures are the built-in recorded responses
+//
+//go:embed fixtures
+var Fixtures embed.FS
+
+// Provider is an API the server stands in for, under /<Name>/ on the server
+type Provider struct {
+	Name   string // path prefix on the server
+	Base   string // the real base URL, which fixture URLs start with
+	Env    string // environment variable the scripts read the base from
+	Images bool   // answered with generated images instead of fixtures
+
+	authorized func(*http.Request) bool // nil if no credentials are needed
+	denied     string                   // the provider's 401 body
+}
+
+// Providers are the APIs and image hosts the scripts use
+var Providers = []Provider{
+	{
+		Name: "tmdb",
+		Base: "https://api.themoviedb.org/3",
+		Env:  "TMDB_API_BASE",
+		authorized: func(r *http.Request) bool {
+			return r.URL.Query().Get("api_key") != "" || strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ")
+		},
+		denied: `{"success":false,"status_code":7,"status_message":"Invalid API key: You must be granted a valid key."}`,
+	},
+	{Name: "tmdb-images", Base: "https://image.tmdb.org/t/p/w500", Env: "TMDB_IMAGE_BASE", Images: true},
+	{
+		Name: "discogs",
+		Base: "https://api.discogs.com",
+		Env:  "DISCOGS_API_BASE",
+		authorized: func(r *http.Request) bool {
+			return r.URL.Query().Get("token") != "" || strings.HasPrefix(r.Header.Get("Authorization"), "Discogs token=")
+		},
+		denied: `{"message": "You must authenticate to access this resource."}`,
+	},
+	{Name: "discogs-images", Base: "https://i.discogs.com", Images: true},
+	{Name: "google-books", Base: "https://www.googleapis.com/books/v1", Env: "GOOGLE_BOOKS_API_BASE"},
+	{Name: "google-books-images", Base: "http://books.google.com/books/content", Images: true},
+	{Name: "openlibrary", Base: "https://openlibrary.org", Env: "OPENLIBRARY_API_BASE"},
+	{Name: "openlibrary-covers", Base: "https://covers.openlibrary.org", Images: true},
+}
+
+// Fixture is a recorded response. It is stored as JSON, one per file.
+type Fixture struct {
+	URL    string          `json:"url"`              // the real URL, without secrets
+	Status int             `json:"status,omitempty"` // 200 if not set
+	Header http.Header     `json:"header,omitempty"` // e.g. Date, ETag
+	Body   json.RawMessage `json:"body"`
+}
+
+// Load reads the fixtures in every .json file under fsys
+func Load(fsys fs.FS) ([]Fixture, error) {
+	var fixtures []Fixture
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || path.Ext(name) != ".json" {
+			return err
+		}
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		var f Fixture
+		if err := json.Unmarshal(data, &f); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		if f.URL == "" {
+			return fmt.Errorf("%s: no url", name)
+		}
+		fixtures = append(fixtures, f)
+		return nil
+	})
+	return fixtures, err
+}
+
+// Bases returns the base URL of each provider on a server at serverURL, by
+// the environment variable the scripts read it from
+func Bases(serverURL string) map[string]string {
+	bases := make(map[string]string)
+	for _, p := range Providers {
+		if p.Env != "" {
+			bases[p.Env] = strings.TrimSuffix(serverURL, "/") + "/" + p.Name
+		}
+	}
+	return bases
+}
+
+// key is how fixtures and requests are matched: httpcache's key of the
+// real URL, which leaves out secrets and sorts the query
+func key(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	return httpcache.Key(u), nil
+}
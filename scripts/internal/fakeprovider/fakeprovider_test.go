@@ -0,0 +1,254 @@
+package fakeprovider
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/artwork"
+)
+
+func start(t *testing.T) map[string]string {
+	t.Helper()
+	s, err := New(Fixtures)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+	return Bases(srv.URL)
+}
+
+func get(t *testing.T, url string, header http.Header) (*http.Response, []byte) {
+	t.Helper()
+	req, _ := http.NewRequest("GET", url, nil)
+	for h, values := range header {
+		req.Header[h] = values
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, body
+}
+
+func TestFixtures(t *testing.T) {
+	bases := start(t)
+	tests := []struct {
+		url    string
+		header http.Header
+		status int
+		want   string
+	}{
+		// Secrets and query order don't matter
+		{bases["TMDB_API_BASE"] + "/search/movie?year=2025&query=Bunny&language=en-US&api_key=secret", nil, 200, `"id": 1422004`},
+		{bases["TMDB_API_BASE"] + "/movie/1422004?append_to_response=credits&language=en-US", http.Header{"Authorization": {"Bearer secret"}}, 200, `"Ben Jacobson"`},
+		{bases["TMDB_API_BASE"] + "/search/movie?query=Bunny&language=en-US&year=2025", nil, 401, `"status_code":7`},
+		{bases["TMDB_API_BASE"] + "/search/movie?api_key=secret&query=Nothing", nil, 404, "no fixture for https://api.themoviedb.org/3/search/movie?query=Nothing"},
+		{bases["DISCOGS_API_BASE"] + "/releases/1941316", http.Header{"Authorization": {"Discogs token=secret"}}, 200, `"Tectonic"`},
+		{bases["DISCOGS_API_BASE"] + "/releases/1941316", nil, 401, "You must authenticate"},
+		{bases["GOOGLE_BOOKS_API_BASE"] + "/volumes?q=All+About+Love&maxResults=5", nil, 200, `"bell hooks"`},
+		{bases["OPENLIBRARY_API_BASE"] + "/works/OL19545135W.json", nil, 200, `"/authors/OL7500326A"`},
+	}
+	for _, tt := range tests {
+		resp, body := get(t, tt.url, tt.header)
+		if resp.StatusCode != tt.status || !strings.Contains(string(body), tt.want) {
+			t.Errorf("GET %s = %d %s, want %d with %s", tt.url, resp.StatusCode, body, tt.status, tt.want)
+		}
+	}
+}
+
+func TestConditionalRequests(t *testing.T) {
+	bases := start(t)
+	details := bases["TMDB_API_BASE"] + "/movie/1422004?append_to_response=credits&language=en-US&api_key=secret"
+	resp, _ := get(t, details, nil)
+	etag := resp.Header.Get("ETag")
+	if etag == "" || resp.Header.Get("Date") != "Thu, 20 Nov 2025 18:04:05 GMT" {
+		t.Fatalf("recorded headers not sent: %v", resp.Header)
+	}
+	if resp, _ := get(t, details, http.Header{"If-None-Match": {etag}}); resp.StatusCode != http.StatusNotModified {
+		t.Errorf("If-None-Match %s = %d, want 304", etag, resp.StatusCode)
+	}
+	if resp, _ := get(t, details, http.Header{"If-None-Match": {`"other"`}}); resp.StatusCode != http.StatusOK {
+		t.Errorf("If-None-Match other = %d, want 200", resp.StatusCode)
+	}
+
+	release := bases["DISCOGS_API_BASE"] + "/releases/1941316?token=secret"
+	for since, want := range map[string]int{
+		"Mon, 03 Mar 2025 09:00:00 GMT": http.StatusNotModified,
+		"Sun, 02 Mar 2025 09:00:00 GMT": http.StatusOK,
+	} {
+		if resp, _ := get(t, release, http.Header{"If-Modified-Since": {since}}); resp.StatusCode != want {
+			t.Errorf("If-Modified-Since %s = %d, want %d", since, resp.StatusCode, want)
+		}
+	}
+}
+
+func TestImages(t *testing.T) {
+	bases := start(t)
+	_, body := get(t, bases["DISCOGS_API_BASE"]+"/releases/1941316?token=secret", nil)
+	var release struct {
+		Images []struct {
+			URI string `json:"uri"`
+		} `json:"images"`
+	}
+	if err := json.Unmarshal(body, &release); err != nil || len(release.Images) == 0 {
+		t.Fatalf("release: %v %s", err, body)
+	}
+	cover := release.Images[0].URI
+	server := strings.TrimSuffix(bases["DISCOGS_API_BASE"], "/discogs")
+	if !strings.HasPrefix(cover, server+"/discogs-images/") {
+		t.Fatalf("cover %s not on the server %s", cover, server)
+	}
+
+	for _, url := range []string{cover, bases["TMDB_IMAGE_BASE"] + "/bunny_poster.jpg"} {
+		resp, data := get(t, url, nil)
+		if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "image/jpeg" {
+			t.Errorf("GET %s = %d %s", url, resp.StatusCode, resp.Header.Get("Content-Type"))
+		}
+		if err := artwork.Validate(data); err != nil {
+			t.Errorf("GET %s: %v", url, err)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	fixtures, err := Load(Fixtures)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range fixtures {
+		if !json.Valid(f.Body) {
+			t.Errorf("%s: body is not JSON", f.URL)
+		}
+		if _, err := key(f.URL); err != nil {
+			t.Errorf("%s: %v", f.URL, err)
+		}
+	}
+}
+
+// toServer sends requests for the real providers to a fake at serverURL
+type toServer string
+
+func (serverURL toServer) RoundTrip(req *http.Request) (*http.Response, error) {
+	p, ok := apiProvider(req.URL)
+	if !ok {
+		return nil, fmt.Errorf("not a provider: %s", req.URL)
+	}
+	u := string(serverURL) + "/" + p.Name + strings.TrimPrefix(req.URL.String(), p.Base)
+	fake, _ := http.NewRequestWithContext(req.Context(), req.Method, u, nil)
+	fake.Header = req.Header.Clone()
+	return http.DefaultTransport.RoundTrip(fake)
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	const key, token = "0123456789abcdef", "discogs-token-value"
+	s, err := New(Fixtures)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A response echoing a key, as error messages sometimes do
+	s.Add(Fixture{
+		URL:    "https://api.themoviedb.org/3/movie/1",
+		Status: http.StatusNotFound,
+		Body:   json.RawMessage(`{"status_message": "No movie 1 for key ` + key + `"}`),
+	})
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	dir := t.TempDir()
+	rec := &Recorder{Dir: dir, Transport: toServer(srv.URL)}
+	client := &http.Client{Transport: rec}
+	requests := []struct {
+		url, auth string
+	}{
+		{"https://api.themoviedb.org/3/search/movie?api_key=" + key + "&query=Bunny&language=en-US&year=2025", ""},
+		{"https://api.themoviedb.org/3/movie/1?api_key=" + key, ""},
+		{"https://api.discogs.com/releases/1941316", "Discogs token=" + token},
+	}
+	var want [][]byte
+	for _, r := range requests {
+		req, _ := http.NewRequest("GET", r.url, nil)
+		if r.auth != "" {
+			req.Header.Set("Authorization", r.auth)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		want = append(want, bytes.ReplaceAll(body, []byte(key), []byte(Redacted)))
+	}
+	if rec.Recorded() != len(requests) {
+		t.Fatalf("recorded %d responses, want %d", rec.Recorded(), len(requests))
+	}
+
+	// Nothing secret is written
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if bytes.Contains(data, []byte(key)) || bytes.Contains(data, []byte(token)) {
+			t.Errorf("%s holds a secret:\n%s", path, data)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Replaying gives the same responses, through the scripts' variables
+	for env := range Bases("") {
+		t.Setenv(env, "")
+	}
+	replay, stop, err := Replay(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+	for i, r := range requests {
+		p, _ := apiProvider(mustParse(t, r.url))
+		u := os.Getenv(p.Env) + strings.TrimPrefix(r.url, p.Base)
+		_, body := get(t, u, http.Header{"Authorization": {"Discogs token=x"}})
+		if !bytes.Equal(bytes.TrimSpace(compact(t, body)), bytes.TrimSpace(compact(t, want[i]))) {
+			t.Errorf("replayed %s:\n%s\nwant:\n%s", r.url, body, want[i])
+		}
+	}
+	if missing := replay.Missing(); len(missing) != 0 {
+		t.Errorf("missing fixtures: %v", missing)
+	}
+}
+
+func mustParse(t *testing.T, rawURL string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func compact(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, data); err != nil {
+		t.Fatalf("%v: %s", err, data)
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,162 @@
+package fakeprovider
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/httpcache"
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/secrets"
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/slug"
+)
+
+// Redacted replaces keys and tokens in recorded responses
+const Redacted = secrets.Redacted
+
+// Recorder is an http.RoundTripper saving the provider API responses that
+// pass through it as fixtures under Dir, one file per URL, for a Server to
+// replay. Keys and tokens are redacted. Artwork and other hosts pass
+// through unrecorded.
+type Recorder struct {
+	Dir       string
+	Transport http.RoundTripper
+
+	recorded atomic.Int64
+}
+
+// RoundTrip implements http.RoundTripper
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	transport := r.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil || req.Method != http.MethodGet || resp.StatusCode == http.StatusNotModified {
+		return resp, err
+	}
+	provider, ok := apiProvider(req.URL)
+	if !ok {
+		return resp, nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err := r.save(provider, req, resp, body); err != nil {
+		fmt.Printf("  ⚠ Could not record response: %v\n", err)
+	}
+	return resp, nil
+}
+
+// Recorded returns how many responses were saved. It is safe to call while
+// requests are running.
+func (r *Recorder) Recorded() int {
+	return int(r.recorded.Load())
+}
+
+func (r *Recorder) save(provider Provider, req *http.Request, resp *http.Response, body []byte) error {
+	k := httpcache.Key(req.URL)
+	values := requestSecrets(req)
+	body = secrets.Replace(body, values)
+	if !json.Valid(body) {
+		return fmt.Errorf("%s: not JSON", k)
+	}
+
+	f := Fixture{URL: k, Header: http.Header{}, Body: body}
+	if resp.StatusCode != http.StatusOK {
+		f.Status = resp.StatusCode
+	}
+	for _, h := range []string{"Content-Type", "Date", "ETag", "Last-Modified"} {
+		if v := resp.Header.Get(h); v != "" {
+			f.Header.Set(h, string(secrets.Replace([]byte(v), values)))
+		}
+	}
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(r.Dir, provider.Name, fixtureName(provider, k))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	r.recorded.Add(1)
+	return nil
+}
+
+// fixtureName names the file of a URL after its path and query, with a
+// hash of the URL so names stay unique once slugged and shortened:
+// search-movie-language-en-us-query-bunny-9c1d2f3a.json
+func fixtureName(provider Provider, key string) string {
+	sum := sha256.Sum256([]byte(key))
+	rest := strings.TrimPrefix(key, provider.Base)
+	if unescaped, err := url.QueryUnescape(rest); err == nil {
+		rest = unescaped
+	}
+	name := slug.Make(querySeparators.Replace(rest), '-')
+	if len(name) > 80 {
+		name = strings.TrimRight(name[:80], "-")
+	}
+	return name + "-" + hex.EncodeToString(sum[:4]) + ".json"
+}
+
+// querySeparators split a query into words for slug, which drops them
+var querySeparators = strings.NewReplacer("?", " ", "&", " ", "=", " ")
+
+// apiProvider returns the provider API a URL belongs to
+func apiProvider(u *url.URL) (Provider, bool) {
+	base := u.Scheme + "://" + u.Host + u.Path
+	for _, p := range Providers {
+		if !p.Images && strings.HasPrefix(base, p.Base) {
+			return p, true
+		}
+	}
+	return Provider{}, false
+}
+
+// requestSecrets returns the keys and tokens a request carries: secret
+// query parameters and the credentials of its Authorization header
+func requestSecrets(req *http.Request) []string {
+	var values []string
+	q := req.URL.Query()
+	for _, p := range httpcache.SecretParams {
+		values = append(values, q[p]...)
+	}
+	if auth := req.Header.Get("Authorization"); auth != "" {
+		// "Bearer <token>", "Discogs token=<token>"
+		_, credentials, _ := strings.Cut(auth, " ")
+		values = append(values, strings.TrimPrefix(credentials, "token="))
+	}
+	return values
+}
+
+// Replay serves the fixtures under dir on a local server and points the
+// scripts' API bases at it, through the environment variables they read.
+// Call stop when done.
+func Replay(dir string) (s *Server, stop func(), err error) {
+	s, err = New(os.DirFS(dir))
+	if err != nil {
+		return nil, nil, err
+	}
+	srv := httptest.NewServer(s)
+	for env, base := range Bases(srv.URL) {
+		os.Setenv(env, base)
+	}
+	return s, srv.Close, nil
+}
@@ -0,0 +1,221 @@
+package fakeprovider
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"io/fs"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Server answers requests to /<provider>/... as the provider would. It is
+// an http.Handler: run it with httptest.NewServer in tests, or
+// http.ListenAndServe (see scripts/fake_providers.go).
+type Server struct {
+	Log io.Writer // each request and its status, if set
+
+	mu       sync.Mutex
+	fixtures map[string]Fixture // by key of the real URL
+	missing  missing
+}
+
+// New returns a server with the fixtures under fsys, e.g. Fixtures
+func New(fsys fs.FS) (*Server, error) {
+	s := &Server{fixtures: make(map[string]Fixture)}
+	fixtures, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range fixtures {
+		if err := s.Add(f); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Add serves f, replacing a fixture for the same URL
+func (s *Server) Add(f Fixture) error {
+	k, err := key(f.URL)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fixtures[k] = f
+	return nil
+}
+
+// Len returns how many fixtures are served
+func (s *Server) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.fixtures)
+}
+
+// Missing returns the URLs requested that had no fixture, without secrets
+func (s *Server) Missing() []string {
+	return s.missing.list()
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	status, realURL := s.serve(w, r)
+	if s.Log != nil {
+		fmt.Fprintf(s.Log, "%s %s → %d\n", r.Method, realURL, status)
+	}
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) (int, string) {
+	name, rest, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	var provider *Provider
+	for i := range Providers {
+		if Providers[i].Name == name {
+			provider = &Providers[i]
+		}
+	}
+	if provider == nil {
+		http.Error(w, "no provider at /"+name, http.StatusNotFound)
+		return http.StatusNotFound, r.URL.String()
+	}
+
+	realURL := provider.Base
+	if rest != "" {
+		realURL += "/" + rest
+	}
+	if r.URL.RawQuery != "" {
+		realURL += "?" + r.URL.RawQuery
+	}
+	k, err := key(realURL)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return http.StatusBadRequest, realURL
+	}
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return http.StatusMethodNotAllowed, k
+	}
+	if provider.Images {
+		w.Header().Set("Content-Type", "image/jpeg")
+		w.Write(placeholderImage(k))
+		return http.StatusOK, k
+	}
+	if provider.authorized != nil && !provider.authorized(r) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		io.WriteString(w, provider.denied)
+		return http.StatusUnauthorized, k
+	}
+
+	s.mu.Lock()
+	f, ok := s.fixtures[k]
+	s.mu.Unlock()
+	if !ok {
+		s.missing.add(k)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, "{\"error\": %q}\n", "no fixture for "+k)
+		return http.StatusNotFound, k
+	}
+
+	for h, values := range f.Header {
+		w.Header()[http.CanonicalHeaderKey(h)] = values
+	}
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	if notModified(r, w.Header()) {
+		w.WriteHeader(http.StatusNotModified)
+		return http.StatusNotModified, k
+	}
+	status := f.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.WriteHeader(status)
+	w.Write(rewriteImages(f.Body, "http://"+r.Host))
+	return status, k
+}
+
+// notModified tells whether a conditional request matches the fixture's
+// ETag or Last-Modified
+func notModified(r *http.Request, header http.Header) bool {
+	if etag := header.Get("ETag"); etag != "" {
+		return r.Header.Get("If-None-Match") == etag
+	}
+	modified, err := http.ParseTime(header.Get("Last-Modified"))
+	if err != nil {
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	return err == nil && !modified.After(since)
+}
+
+// rewriteImages points the image URLs in a body at the server, so the
+// scripts download generated artwork instead of the real one
+func rewriteImages(body []byte, serverURL string) []byte {
+	for _, p := range Providers {
+		if !p.Images {
+			continue
+		}
+		local := []byte(serverURL + "/" + p.Name)
+		host := strings.TrimPrefix(strings.TrimPrefix(p.Base, "https://"), "http://")
+		for _, scheme := range []string{"https://", "http://"} {
+			body = bytes.ReplaceAll(body, []byte(scheme+host), local)
+		}
+	}
+	return body
+}
+
+// placeholderImage is a poster-shaped JPEG in a color of its own for each
+// URL, so artwork colors differ between titles
+func placeholderImage(url string) []byte {
+	h := fnv.New32a()
+	io.WriteString(h, url)
+	sum := h.Sum32()
+	c := color.RGBA{uint8(sum), uint8(sum >> 8), uint8(sum >> 16), 255}
+
+	img := image.NewRGBA(image.Rect(0, 0, 200, 300))
+	for y := 0; y < 300; y++ {
+		for x := 0; x < 200; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	jpeg.Encode(&buf, img, &jpeg.Options{Quality: 80})
+	return buf.Bytes()
+}
+
+// missing keeps the URLs a server had no fixture for
+type missing struct {
+	mu   sync.Mutex
+	urls map[string]bool
+}
+
+func (m *missing) add(url string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.urls == nil {
+		m.urls = make(map[string]bool)
+	}
+	m.urls[url] = true
+}
+
+func (m *missing) list() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var urls []string
+	for url := range m.urls {
+		urls = append(urls, url)
+	}
+	sort.Strings(urls)
+	return urls
+}
@@ -0,0 +1,79 @@
+// Package golden compares what tests produce with files kept under
+// testdata, and rewrites the files when the tests run with -update
+package golden
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/changes"
+)
+
+var update = flag.Bool("update", false, "Rewrite golden files with what the tests produce")
+
+// Check compares got with the golden file at path, or writes it there with
+// -update
+func Check(t testing.TB, path, got string) {
+	t.Helper()
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run with -update to create it)", err)
+	}
+	if string(want) != got {
+		t.Errorf("%s differs (run with -update if the change is intended):\n%s", path, changes.Unified(path, "got", string(want), got))
+	}
+}
+
+// CheckBytes is Check for binary files, such as images: it reports where
+// got first differs instead of a diff
+func CheckBytes(t testing.TB, path string, got []byte) {
+	t.Helper()
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run with -update to create it)", err)
+	}
+	if bytes.Equal(want, got) {
+		return
+	}
+	at := 0
+	for at < len(want) && at < len(got) && want[at] == got[at] {
+		at++
+	}
+	t.Errorf("%s differs from byte %d (got %d bytes, want %d; run with -update if the change is intended)", path, at, len(got), len(want))
+}
+
+// Report lays out what a lookup printed and returned, for Check. Each pair
+// of replace strings is swapped in, e.g. a test server's random URL for a
+// fixed one.
+func Report(output string, result any, replace ...string) string {
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		data = []byte(fmt.Sprintf("error: %v", err))
+	}
+	report := fmt.Sprintf("%s\n--- result\n%s\n", strings.TrimRight(output, "\n"), data)
+	return strings.NewReplacer(replace...).Replace(report)
+}
@@ -0,0 +1,304 @@
+// Package httpcache keeps provider API responses on disk, so runs over the
+// same titles don't query TMDB, Discogs, Google Books and Open Library
+// again. It is an http.RoundTripper: give it to a client as its Transport.
+package httpcache
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Dir is where responses are stored, relative to the site root
+const Dir = ".cache/http"
+
+// SecretParams are query parameters left out of cache keys and files
+var SecretParams = []string{"api_key", "key", "token", "access_token"}
+
+// ErrOffline is returned in offline mode for requests that aren't cached
+var ErrOffline = errors.New("not in the HTTP cache (offline)")
+
+// Rule sets how long responses of an endpoint stay fresh
+type Rule struct {
+	Host       string // e.g. api.themoviedb.org
+	PathPrefix string // e.g. /3/search/
+	TTL        time.Duration
+}
+
+const day = 24 * time.Hour
+
+// Rules are the cached endpoints. Searches change as providers add
+// releases, details rarely do. Other requests (artwork downloads) aren't
+// cached.
+var Rules = []Rule{
+	{"api.themoviedb.org", "/3/search/", 7 * day},
+	{"api.themoviedb.org", "/3/movie/", 30 * day},
+	{"api.discogs.com", "/database/search", 7 * day},
+	{"api.discogs.com", "/releases/", 90 * day},
+	{"www.googleapis.com", "/books/v1/volumes", 7 * day},
+	{"www.googleapis.com", "/books/v1/series/", 30 * day},
+	{"openlibrary.org", "/search.json", 7 * day},
+	{"openlibrary.org", "/works/", 30 * day},
+	{"openlibrary.org", "/authors/", 90 * day},
+}
+
+// Cache is an http.RoundTripper answering cached GET requests from disk
+type Cache struct {
+	Dir       string
+	Rules     []Rule
+	Refresh   bool // ignore cached responses, but store new ones
+	Offline   bool // never go to the network
+	NoStore   bool // answer from the cache, but never write to it (dry runs)
+	Transport http.RoundTripper
+
+	hits, misses atomic.Int64
+}
+
+// New returns a cache under baseDir with the default rules
+func New(baseDir string) *Cache {
+	return &Cache{
+		Dir:       filepath.Join(baseDir, Dir),
+		Rules:     Rules,
+		Transport: http.DefaultTransport,
+	}
+}
+
+// entry is a stored response
+type entry struct {
+	URL     string      `json:"url"` // without secrets, for people reading the files
+	Status  int         `json:"status"`
+	Header  http.Header `json:"header"`
+	Body    []byte      `json:"body"`
+	Fetched time.Time   `json:"fetched"`
+}
+
+type refreshKey struct{}
+
+// WithRefresh makes requests made with ctx skip cached responses (and store
+// new ones), as Refresh does for all requests
+func WithRefresh(ctx context.Context) context.Context {
+	return context.WithValue(ctx, refreshKey{}, true)
+}
+
+// RoundTrip implements http.RoundTripper
+func (c *Cache) RoundTrip(req *http.Request) (*http.Response, error) {
+	ttl, cacheable := c.ttl(req)
+	if !cacheable {
+		if c.Offline {
+			return nil, fmt.Errorf("%s: %w", Key(req.URL), ErrOffline)
+		}
+		return c.transport().RoundTrip(req)
+	}
+
+	path := c.path(req.URL)
+	cached, err := load(path)
+	refresh := c.Refresh || req.Context().Value(refreshKey{}) != nil
+	if err == nil && (c.Offline || !refresh && time.Since(cached.Fetched) < ttl) {
+		c.hits.Add(1)
+		return cached.response(req), nil
+	}
+	if c.Offline {
+		return nil, fmt.Errorf("%s: %w", Key(req.URL), ErrOffline)
+	}
+
+	c.misses.Add(1)
+	resp, err := c.transport().RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	fresh := entry{
+		URL:     Key(req.URL),
+		Status:  resp.StatusCode,
+		Header:  http.Header{},
+		Body:    body,
+		Fetched: time.Now(),
+	}
+	for _, h := range []string{"Content-Type", "Date", "ETag", "Last-Modified"} {
+		if v := resp.Header.Get(h); v != "" {
+			fresh.Header.Set(h, v)
+		}
+	}
+	if c.NoStore {
+		return resp, nil
+	}
+	if err := fresh.save(path); err != nil {
+		fmt.Printf("  ⚠ Could not cache response: %v\n", err)
+	}
+	return resp, nil
+}
+
+// Changed asks the provider whether the cached response to req is out of
+// date, with a conditional request on its ETag or Last-Modified. Responses
+// cached without either, or not cached at all, count as unchanged: only
+// their age tells. Offline, nothing has changed.
+func (c *Cache) Changed(req *http.Request) (bool, error) {
+	if c.Offline {
+		return false, nil
+	}
+	cached, err := load(c.path(req.URL))
+	if err != nil {
+		return false, nil
+	}
+	etag, modified := cached.Header.Get("ETag"), cached.Header.Get("Last-Modified")
+	if etag == "" && modified == "" {
+		return false, nil
+	}
+
+	cond := req.Clone(req.Context())
+	if etag != "" {
+		cond.Header.Set("If-None-Match", etag)
+	}
+	if modified != "" {
+		cond.Header.Set("If-Modified-Since", modified)
+	}
+	resp, err := c.transport().RoundTrip(cond)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return false, nil
+	case http.StatusOK:
+		// Not every server honors conditional requests
+		if etag != "" && resp.Header.Get("ETag") == etag {
+			return false, nil
+		}
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return false, err
+		}
+		return !bytes.Equal(body, cached.Body), nil
+	}
+	return false, fmt.Errorf("%s: %s", Key(req.URL), resp.Status)
+}
+
+// Stats returns how many responses came from the cache and how many were
+// fetched. It is safe to call while requests are running.
+func (c *Cache) Stats() (hits, fetched int) {
+	return int(c.hits.Load()), int(c.misses.Load())
+}
+
+// Fetched is when the provider sent a response, cached or not: its Date
+// header, or now if it has none
+func Fetched(resp *http.Response) time.Time {
+	if t, err := http.ParseTime(resp.Header.Get("Date")); err == nil {
+		return t.UTC()
+	}
+	return time.Now().UTC().Truncate(time.Second)
+}
+
+// Key is the cache key of a URL: the URL with secret query parameters
+// removed and the rest sorted
+func Key(u *url.URL) string {
+	stripped := *u
+	q := stripped.Query()
+	for _, p := range SecretParams {
+		q.Del(p)
+	}
+	stripped.RawQuery = q.Encode()
+	stripped.Fragment = ""
+	return stripped.String()
+}
+
+func (c *Cache) ttl(req *http.Request) (time.Duration, bool) {
+	if req.Method != http.MethodGet {
+		return 0, false
+	}
+	for _, rule := range c.Rules {
+		if strings.EqualFold(req.URL.Hostname(), rule.Host) && strings.HasPrefix(req.URL.Path, rule.PathPrefix) {
+			return rule.TTL, true
+		}
+	}
+	return 0, false
+}
+
+func (c *Cache) transport() http.RoundTripper {
+	if c.Transport != nil {
+		return c.Transport
+	}
+	return http.DefaultTransport
+}
+
+// path is the file a URL is stored in: <dir>/<host>/<sha256 of the key>.json
+func (c *Cache) path(u *url.URL) string {
+	sum := sha256.Sum256([]byte(Key(u)))
+	return filepath.Join(c.Dir, u.Hostname(), hex.EncodeToString(sum[:])+".json")
+}
+
+func load(path string) (*entry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var e entry
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+func (e *entry) save(path string) error {
+	data, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	// Workers may store the same URL at once; each writes its own file
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (e *entry) response(req *http.Request) *http.Response {
+	header := e.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	if header.Get("Date") == "" {
+		// Stored before Date was kept
+		header.Set("Date", e.Fetched.UTC().Format(http.TimeFormat))
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", e.Status, http.StatusText(e.Status)),
+		StatusCode:    e.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+}
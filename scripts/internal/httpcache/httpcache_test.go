@@ -0,0 +1,247 @@
+package httpcache
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// provider is a test server counting the requests that reach it
+type provider struct {
+	*httptest.Server
+	requests atomic.Int64
+	status   atomic.Int64
+}
+
+func newProvider(t *testing.T) *provider {
+	t.Helper()
+	p := &provider{}
+	p.status.Store(http.StatusOK)
+	p.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := p.requests.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", `"v1"`)
+		w.WriteHeader(int(p.status.Load()))
+		io.WriteString(w, `{"path":"`+r.URL.Path+`","n":`+strconv.FormatInt(n, 10)+`}`)
+	}))
+	t.Cleanup(p.Close)
+	return p
+}
+
+// newCache returns a cache of the provider's /cached/ responses
+func newCache(t *testing.T, p *provider) *Cache {
+	t.Helper()
+	u, _ := url.Parse(p.URL)
+	c := New(t.TempDir())
+	c.Rules = []Rule{{Host: u.Hostname(), PathPrefix: "/cached/", TTL: time.Hour}}
+	return c
+}
+
+func get(t *testing.T, c *Cache, ctx context.Context, u string) (string, error) {
+	t.Helper()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	resp, err := (&http.Client{Transport: c}).Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	return string(body), err
+}
+
+func cachedFiles(t *testing.T, c *Cache) []string {
+	t.Helper()
+	var files []string
+	filepath.WalkDir(c.Dir, func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files
+}
+
+func TestKey(t *testing.T) {
+	tests := map[string]string{
+		"https://api.themoviedb.org/3/search/movie?query=Bunny&api_key=secret&year=2025":        "https://api.themoviedb.org/3/search/movie?query=Bunny&year=2025",
+		"https://api.themoviedb.org/3/movie/1?year=2025&query=Bunny":                            "https://api.themoviedb.org/3/movie/1?query=Bunny&year=2025",
+		"https://www.googleapis.com/books/v1/volumes?q=dune&key=secret":                         "https://www.googleapis.com/books/v1/volumes?q=dune",
+		"https://api.discogs.com/database/search?q=x&token=secret&access_token=secret#fragment": "https://api.discogs.com/database/search?q=x",
+	}
+	for in, want := range tests {
+		u, _ := url.Parse(in)
+		if got := Key(u); got != want {
+			t.Errorf("Key(%s) = %s, want %s", in, got, want)
+		}
+	}
+}
+
+func TestSecretsStayOutOfFiles(t *testing.T) {
+	p := newProvider(t)
+	c := newCache(t, p)
+	if _, err := get(t, c, context.Background(), p.URL+"/cached/search?q=x&api_key=very-secret"); err != nil {
+		t.Fatal(err)
+	}
+	// Another key gets the same response
+	if _, err := get(t, c, context.Background(), p.URL+"/cached/search?q=x&api_key=other-secret"); err != nil {
+		t.Fatal(err)
+	}
+	if n := p.requests.Load(); n != 1 {
+		t.Errorf("%d requests reached the provider, want 1", n)
+	}
+	files := cachedFiles(t, c)
+	if len(files) != 1 {
+		t.Fatalf("cached %d files, want 1", len(files))
+	}
+	data, _ := os.ReadFile(files[0])
+	if strings.Contains(string(data), "secret") {
+		t.Errorf("the key was stored:\n%s", data)
+	}
+}
+
+func TestTTL(t *testing.T) {
+	p := newProvider(t)
+	c := newCache(t, p)
+	u := p.URL + "/cached/movie/1"
+	first, _ := get(t, c, context.Background(), u)
+	second, _ := get(t, c, context.Background(), u)
+	if first != second || p.requests.Load() != 1 {
+		t.Fatalf("fresh response not reused: %s, %s, %d requests", first, second, p.requests.Load())
+	}
+
+	// Age the stored response past the rule's TTL
+	path := c.path(mustParse(u))
+	e, err := load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.Fetched = time.Now().Add(-2 * time.Hour)
+	if err := e.save(path); err != nil {
+		t.Fatal(err)
+	}
+	third, _ := get(t, c, context.Background(), u)
+	if third == first || p.requests.Load() != 2 {
+		t.Errorf("stale response reused: %s, %d requests", third, p.requests.Load())
+	}
+	if hits, fetched := c.Stats(); hits != 1 || fetched != 2 {
+		t.Errorf("Stats = %d hits, %d fetched, want 1, 2", hits, fetched)
+	}
+}
+
+func TestNotCached(t *testing.T) {
+	p := newProvider(t)
+	c := newCache(t, p)
+	for i := 0; i < 2; i++ {
+		get(t, c, context.Background(), p.URL+"/poster.jpg")
+	}
+	p.status.Store(http.StatusNotFound)
+	for i := 0; i < 2; i++ {
+		get(t, c, context.Background(), p.URL+"/cached/movie/404")
+	}
+	if n := p.requests.Load(); n != 4 {
+		t.Errorf("%d requests reached the provider, want 4", n)
+	}
+	if files := cachedFiles(t, c); len(files) != 0 {
+		t.Errorf("cached %v, want nothing", files)
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	p := newProvider(t)
+	c := newCache(t, p)
+	u := p.URL + "/cached/movie/1"
+	first, _ := get(t, c, context.Background(), u)
+
+	refreshed, _ := get(t, c, WithRefresh(context.Background()), u)
+	if refreshed == first {
+		t.Error("WithRefresh answered from the cache")
+	}
+	c.Refresh = true
+	again, _ := get(t, c, context.Background(), u)
+	if again == refreshed {
+		t.Error("Refresh answered from the cache")
+	}
+	// The refreshed response is stored
+	c.Refresh = false
+	if cached, _ := get(t, c, context.Background(), u); cached != again {
+		t.Errorf("got %s, want the refreshed %s", cached, again)
+	}
+	if n := p.requests.Load(); n != 3 {
+		t.Errorf("%d requests reached the provider, want 3", n)
+	}
+}
+
+func TestOffline(t *testing.T) {
+	p := newProvider(t)
+	c := newCache(t, p)
+	u := p.URL + "/cached/movie/1"
+	first, _ := get(t, c, context.Background(), u)
+
+	// However old, and whether refreshing or not
+	path := c.path(mustParse(u))
+	e, _ := load(path)
+	e.Fetched = time.Now().Add(-365 * 24 * time.Hour)
+	e.save(path)
+	c.Offline, c.Refresh = true, true
+	if cached, err := get(t, c, context.Background(), u); err != nil || cached != first {
+		t.Errorf("got %q, %v, want the cached %s", cached, err, first)
+	}
+
+	for _, uncached := range []string{p.URL + "/cached/movie/2", p.URL + "/poster.jpg"} {
+		if _, err := get(t, c, context.Background(), uncached); !errors.Is(err, ErrOffline) {
+			t.Errorf("%s: err = %v, want ErrOffline", uncached, err)
+		}
+	}
+	if changed, err := c.Changed(mustRequest(u)); changed || err != nil {
+		t.Errorf("Changed = %v, %v offline, want false", changed, err)
+	}
+	if n := p.requests.Load(); n != 1 {
+		t.Errorf("%d requests reached the provider, want 1", n)
+	}
+}
+
+func TestNoStore(t *testing.T) {
+	p := newProvider(t)
+	c := newCache(t, p)
+	cachedURL := p.URL + "/cached/movie/1"
+	first, _ := get(t, c, context.Background(), cachedURL)
+
+	c.NoStore = true
+	if cached, _ := get(t, c, context.Background(), cachedURL); cached != first {
+		t.Errorf("got %s, want the cached %s", cached, first)
+	}
+	get(t, c, context.Background(), p.URL+"/cached/movie/2")
+	get(t, c, context.Background(), p.URL+"/cached/movie/2")
+	if n := p.requests.Load(); n != 3 {
+		t.Errorf("%d requests reached the provider, want 3", n)
+	}
+	if files := cachedFiles(t, c); len(files) != 1 {
+		t.Errorf("cached %v, want only the response from before", files)
+	}
+}
+
+func mustParse(s string) *url.URL {
+	u, err := url.Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return u
+}
+
+func mustRequest(s string) *http.Request {
+	req, err := http.NewRequest(http.MethodGet, s, nil)
+	if err != nil {
+		panic(err)
+	}
+	return req
+}
@@ -0,0 +1,106 @@
+package imageset
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ManifestFile is the image manifest, relative to the site root. Hugo reads
+// it as site.Data.images.
+const ManifestFile = "data/images.json"
+
+// Image describes an original and its variants. Paths are site URLs, the
+// way pages reference them (/images/movies/bunny_poster.jpg).
+type Image struct {
+	Width    int       `json:"width"`
+	Height   int       `json:"height"`
+	Dithered string    `json:"dithered,omitempty"`
+	Variants []Variant `json:"variants"`
+	Hash     string    `json:"hash"`
+}
+
+// Variant is one resized copy, narrowest first
+type Variant struct {
+	Src      string `json:"src"`
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
+	Dithered string `json:"dithered,omitempty"`
+}
+
+// Manifest maps the URL of every original to its dimensions and variants
+type Manifest struct {
+	path   string
+	Images map[string]Image
+}
+
+// LoadManifest reads a manifest, or starts an empty one if it doesn't exist
+func LoadManifest(path string) (*Manifest, error) {
+	m := &Manifest{path: path, Images: map[string]Image{}}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &m.Images); err != nil {
+		return nil, err
+	}
+	if m.Images == nil {
+		m.Images = map[string]Image{}
+	}
+	return m, nil
+}
+
+// Save writes the manifest with sorted keys, through a temporary file
+func (m *Manifest) Save() error {
+	data, err := json.MarshalIndent(m.Images, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(m.path), 0755); err != nil {
+		return err
+	}
+	tmp := m.path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, m.path)
+}
+
+// URLs lists the recorded originals in sorted order
+func (m *Manifest) URLs() []string {
+	urls := make([]string, 0, len(m.Images))
+	for url := range m.Images {
+		urls = append(urls, url)
+	}
+	sort.Strings(urls)
+	return urls
+}
+
+// URL returns the site URL of a file under static/ or assets/ (rel is
+// relative to the site root). ok is false for files elsewhere, which pages
+// can't reference by a fixed URL.
+func URL(rel string) (url string, ok bool) {
+	rel = filepath.ToSlash(rel)
+	for _, root := range []string{"static/", "assets/"} {
+		if strings.HasPrefix(rel, root) {
+			return "/" + strings.TrimPrefix(rel, root), true
+		}
+	}
+	return "", false
+}
+
+// Locate finds the file behind a site URL in static/ or assets/
+func Locate(baseDir, url string) (string, bool) {
+	for _, root := range []string{"static", "assets"} {
+		file := filepath.Join(baseDir, root, filepath.FromSlash(url))
+		if _, err := os.Stat(file); err == nil {
+			return file, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,125 @@
+package imageset
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestManifest(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, filepath.FromSlash(ManifestFile))
+
+	m, err := LoadManifest(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Images) != 0 {
+		t.Fatalf("missing manifest loaded %d images", len(m.Images))
+	}
+
+	m.Images["/images/music/b_cover.jpg"] = Image{
+		Width: 600, Height: 600, Hash: "b",
+		Variants: []Variant{{Src: "/images/music/b_cover_160w.jpg", Width: 160, Height: 160}},
+	}
+	m.Images["/images/movies/a_poster.jpg"] = Image{
+		Width: 500, Height: 750, Hash: "a", Dithered: "/images/movies/a_poster_dithered.jpg",
+		Variants: []Variant{
+			{Src: "/images/movies/a_poster_160w.jpg", Width: 160, Height: 240, Dithered: "/images/movies/a_poster_160w_dithered.jpg"},
+			{Src: "/images/movies/a_poster_320w.jpg", Width: 320, Height: 480, Dithered: "/images/movies/a_poster_320w_dithered.jpg"},
+		},
+	}
+	if err := m.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Hugo reads the keys templates use, so they must not drift
+	for _, key := range []string{`"width": 500`, `"height": 750`, `"dithered": "/images/movies/a_poster_dithered.jpg"`, `"src": "/images/movies/a_poster_160w.jpg"`, `"hash": "a"`} {
+		if !strings.Contains(string(data), key) {
+			t.Errorf("manifest has no %s:\n%s", key, data)
+		}
+	}
+	if a, b := strings.Index(string(data), "a_poster.jpg"), strings.Index(string(data), "b_cover.jpg"); a > b {
+		t.Error("manifest keys aren't sorted")
+	}
+	if strings.Contains(strings.SplitN(string(data), "b_cover.jpg", 2)[1], `"dithered"`) {
+		t.Error("empty dithered written")
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Errorf("files left next to the manifest: %v", entries)
+	}
+
+	loaded, err := LoadManifest(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded.Images, m.Images) {
+		t.Errorf("reloaded %+v, want %+v", loaded.Images, m.Images)
+	}
+	if got, want := loaded.URLs(), []string{"/images/movies/a_poster.jpg", "/images/music/b_cover.jpg"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("URLs = %v, want %v", got, want)
+	}
+}
+
+func TestLoadManifestErrors(t *testing.T) {
+	dir := t.TempDir()
+	tests := map[string]string{
+		"broken.json": `{"/images/a.jpg": {`,
+		"null.json":   `null`,
+	}
+	for name, content := range tests {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := LoadManifest(filepath.Join(dir, "broken.json")); err == nil {
+		t.Error("broken manifest loaded")
+	}
+	m, err := LoadManifest(filepath.Join(dir, "null.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Images["/images/a.jpg"] = Image{} // must not panic
+}
+
+func TestURL(t *testing.T) {
+	tests := []struct {
+		rel, url string
+		ok       bool
+	}{
+		{"static/images/movies/a.jpg", "/images/movies/a.jpg", true},
+		{"assets/screenshots/s.png", "/screenshots/s.png", true},
+		{"content/posts/hello/cover.jpg", "", false},
+		{"statics/a.jpg", "", false},
+	}
+	for _, tt := range tests {
+		url, ok := URL(filepath.FromSlash(tt.rel))
+		if url != tt.url || ok != tt.ok {
+			t.Errorf("URL(%q) = %q, %v, want %q, %v", tt.rel, url, ok, tt.url, tt.ok)
+		}
+		if !ok {
+			continue
+		}
+		// Locate finds the file again from its URL
+		base := t.TempDir()
+		file := filepath.Join(base, filepath.FromSlash(tt.rel))
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if got, found := Locate(base, url); !found || got != file {
+			t.Errorf("Locate(%q) = %s, %v, want %s", url, got, found, file)
+		}
+	}
+	if _, found := Locate(t.TempDir(), "/images/missing.jpg"); found {
+		t.Error("Locate found a missing file")
+	}
+}
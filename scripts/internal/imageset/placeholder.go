@@ -0,0 +1,169 @@
+package imageset
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/png"
+
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/consumed"
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/dither"
+)
+
+// PlaceholderWidth is the width of the inline placeholder thumbnail; cards
+// scale it up with image-rendering: pixelated
+const PlaceholderWidth = 16
+
+// placeholderOptions dithers placeholders in the theme's colors, so they
+// look like the covers they stand in for
+var placeholderOptions = dither.Options{
+	Algorithm: dither.Bayer4,
+	Palette:   dither.Duotone,
+	Contrast:  5,
+}
+
+// Artwork is what cards need to draw a cover before it has loaded
+type Artwork struct {
+	Placeholder string // data: URL of a tiny dithered PNG
+	Color       string // dominant color, #rrggbb
+	Accent      string // most vivid common color, #rrggbb
+}
+
+// Analyze computes the placeholder and colors of an image file
+func Analyze(path string) (Artwork, error) {
+	img, err := dither.Load(path)
+	if err != nil {
+		return Artwork{}, err
+	}
+	placeholder, err := Placeholder(img)
+	if err != nil {
+		return Artwork{}, err
+	}
+	dominant, accent := Colors(img)
+	return Artwork{Placeholder: placeholder, Color: dominant, Accent: accent}, nil
+}
+
+// Apply writes the artwork fields to a page's frontmatter, after img.
+// Fields the page locks are left alone.
+func (a Artwork) Apply(p *consumed.Page) {
+	if !p.Locked("placeholder") {
+		p.SetString("placeholder", a.Placeholder, "img")
+	}
+	if !p.Locked("color") {
+		p.SetString("color", a.Color, "placeholder", "img")
+	}
+	if !p.Locked("accent") {
+		p.SetString("accent", a.Accent, "color", "placeholder", "img")
+	}
+}
+
+// Placeholder returns a PlaceholderWidth-pixel-wide dithered copy of an
+// image as a data: URL, a couple of hundred bytes
+func Placeholder(img image.Image) (string, error) {
+	thumb := dither.Dither(Resize(img, PlaceholderWidth), placeholderOptions)
+	var buf bytes.Buffer
+	enc := png.Encoder{CompressionLevel: png.BestCompression}
+	if err := enc.Encode(&buf, thumb); err != nil {
+		return "", err
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// colorBucket collects the pixels whose colors share their top four bits
+// per channel
+type colorBucket struct {
+	count      int
+	r, g, b    int
+	saturation float64
+	lightness  float64
+}
+
+// Colors returns the dominant color of an image (the most common, after
+// grouping similar colors) and its accent: the most vivid color that still
+// covers a noticeable part of the image, or the dominant color if it has
+// none. Fully transparent areas are ignored.
+func Colors(img image.Image) (dominant, accent string) {
+	thumb := Resize(img, 64)
+	b := thumb.Bounds()
+
+	var buckets [4096]colorBucket
+	total := 0
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, bl, a := thumb.At(x, y).RGBA()
+			if a < 0x8000 {
+				continue
+			}
+			r, g, bl = r>>8, g>>8, bl>>8
+			bucket := &buckets[(r>>4)<<8|(g>>4)<<4|bl>>4]
+			bucket.count++
+			bucket.r += int(r)
+			bucket.g += int(g)
+			bucket.b += int(bl)
+			total++
+		}
+	}
+	if total == 0 {
+		return "#ffffff", "#ffffff"
+	}
+
+	best, vivid := -1, -1
+	var vividScore float64
+	for i := range buckets {
+		bucket := &buckets[i]
+		if bucket.count == 0 {
+			continue
+		}
+		bucket.r /= bucket.count
+		bucket.g /= bucket.count
+		bucket.b /= bucket.count
+		bucket.saturation, bucket.lightness = saturationLightness(bucket.r, bucket.g, bucket.b)
+
+		if best < 0 || bucket.count > buckets[best].count {
+			best = i
+		}
+		// Ignore specks, and colors too dark or light to read as a tint
+		if bucket.count*100 < total || bucket.lightness < 0.15 || bucket.lightness > 0.9 {
+			continue
+		}
+		score := float64(bucket.count) * bucket.saturation * bucket.saturation
+		if score > vividScore {
+			vivid, vividScore = i, score
+		}
+	}
+	if vivid < 0 {
+		vivid = best
+	}
+	return hex(buckets[best]), hex(buckets[vivid])
+}
+
+// saturationLightness returns the HSL saturation and lightness of a color
+func saturationLightness(r, g, b int) (float64, float64) {
+	max, min := r, r
+	for _, c := range []int{g, b} {
+		if c > max {
+			max = c
+		}
+		if c < min {
+			min = c
+		}
+	}
+	lightness := float64(max+min) / 510
+	if max == min {
+		return 0, lightness
+	}
+	chroma := float64(max-min) / 255
+	return chroma / (1 - abs(2*lightness-1)), lightness
+}
+
+func abs(v float64) float64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func hex(b colorBucket) string {
+	return fmt.Sprintf("#%02x%02x%02x", b.r, b.g, b.b)
+}
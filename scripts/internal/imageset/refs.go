@@ -0,0 +1,182 @@
+package imageset
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/consumed"
+)
+
+// Ref is one image reference in a page or gallery data file
+type Ref struct {
+	File  string // page or data file the reference is in, absolute
+	Field string // img, image, src (frontmatter tables and shortcodes), markdown or gallery
+	Value string // the reference as written
+	Page  *consumed.Page
+}
+
+var (
+	// srcPattern finds src = "..." in frontmatter tables (screenshots) and
+	// gallery data files, and src="..." in shortcodes
+	srcPattern = regexp.MustCompile(`\bsrc\s*=\s*"([^"]+)"`)
+	// markdownImagePattern finds ![alt](path)
+	markdownImagePattern = regexp.MustCompile(`!\[[^\]]*\]\(([^)\s]+)`)
+	// galleryPattern finds the data file of gallery and spoiler-gallery
+	// shortcodes
+	galleryPattern = regexp.MustCompile(`\{\{<\s*(?:spoiler-)?gallery\b[^>]*\bfile="([^"]+)"`)
+	// inlineCodePattern and commentedShortcodePattern find examples that
+	// aren't rendered as images
+	inlineCodePattern         = regexp.MustCompile("`[^`\n]*`")
+	commentedShortcodePattern = regexp.MustCompile(`(?s)\{\{</\*.*?\*/>\}\}`)
+)
+
+// FindRefs lists every image referenced from content/: the img and image
+// frontmatter fields, src entries in frontmatter (screenshots), src
+// parameters of shortcodes, markdown images, and the images of the data
+// files gallery shortcodes point at. Remote and data: URLs are left out.
+func FindRefs(baseDir string) ([]Ref, error) {
+	var refs []Ref
+	add := func(file, field, value string, page *consumed.Page) {
+		if value == "" || strings.Contains(value, "://") || strings.HasPrefix(value, "data:") {
+			return
+		}
+		refs = append(refs, Ref{File: file, Field: field, Value: value, Page: page})
+	}
+
+	contentDir := filepath.Join(baseDir, "content")
+	err := filepath.WalkDir(contentDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(path, ".md") {
+			return err
+		}
+		page, err := consumed.Load(path)
+		if err != nil {
+			// Pages without TOML frontmatter; their body can still use images
+			content, readErr := os.ReadFile(path)
+			if readErr != nil {
+				return readErr
+			}
+			page = &consumed.Page{Path: path, Body: string(content)}
+		}
+		add(path, "img", page.String("img"), page)
+		add(path, "image", page.String("image"), page)
+		for _, m := range srcPattern.FindAllStringSubmatch(page.Frontmatter, -1) {
+			add(path, "src", m[1], page)
+		}
+		body := stripCode(page.Body)
+		for _, m := range srcPattern.FindAllStringSubmatch(body, -1) {
+			add(path, "src", m[1], page)
+		}
+		for _, m := range markdownImagePattern.FindAllStringSubmatch(body, -1) {
+			add(path, "markdown", m[1], page)
+		}
+		for _, m := range galleryPattern.FindAllStringSubmatch(body, -1) {
+			dataFile, ok := galleryData(baseDir, m[1])
+			if !ok {
+				add(path, "gallery", "data/"+m[1], page)
+				continue
+			}
+			content, err := os.ReadFile(dataFile)
+			if err != nil {
+				return err
+			}
+			for _, src := range srcPattern.FindAllStringSubmatch(string(content), -1) {
+				add(dataFile, "gallery", src[1], nil)
+			}
+		}
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return refs, nil
+	}
+	return refs, err
+}
+
+// stripCode removes fenced code blocks, inline code and commented-out
+// shortcodes from a page body, so documentation examples don't count
+func stripCode(body string) string {
+	var b strings.Builder
+	fence := ""
+	for _, line := range strings.SplitAfter(body, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if fence == "" && (strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~")) {
+			fence = trimmed[:3]
+			continue
+		}
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+			continue
+		}
+		b.WriteString(line)
+	}
+	body = commentedShortcodePattern.ReplaceAllString(b.String(), "")
+	return inlineCodePattern.ReplaceAllString(body, "")
+}
+
+// galleryData finds the data file of a gallery shortcode: gallery looks it
+// up by base name, spoiler-gallery by its path under data/
+func galleryData(baseDir, file string) (string, bool) {
+	for _, candidate := range []string{
+		filepath.Join(baseDir, "data", filepath.FromSlash(file)),
+		filepath.Join(baseDir, "data", filepath.Base(file)),
+	} {
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate, true
+		}
+	}
+	return "", false
+}
+
+// Candidates lists the files a reference can mean, the way the templates
+// resolve it: /images/x.jpg is static/images/x.jpg or assets/images/x.jpg,
+// and a relative path can also be a page resource next to the page
+func (r Ref) Candidates(baseDir string) []string {
+	value := filepath.FromSlash(r.Value)
+	if strings.HasPrefix(r.Value, "/") {
+		return []string{
+			filepath.Join(baseDir, "static", value),
+			filepath.Join(baseDir, "assets", value),
+		}
+	}
+	return []string{
+		filepath.Join(filepath.Dir(r.File), value),
+		filepath.Join(baseDir, "assets", value),
+		filepath.Join(baseDir, "static", value),
+	}
+}
+
+// Resolve returns the file a reference points at, if it exists
+func (r Ref) Resolve(baseDir string) (string, bool) {
+	for _, candidate := range r.Candidates(baseDir) {
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			return candidate, true
+		}
+	}
+	return "", false
+}
+
+// Source returns the image a generated file belongs to: the original of a
+// dithered copy, a responsive variant or a dither sidecar. Other files are
+// their own source.
+func Source(path string) string {
+	path = strings.TrimSuffix(path, ".dither.toml")
+	ext := filepath.Ext(path)
+	if stem := strings.TrimSuffix(path, ext); strings.HasSuffix(stem, "_dithered") {
+		path = strings.TrimSuffix(stem, "_dithered") + ext
+		// WebP images have PNG copies (see dither.DitheredPath)
+		webp := strings.TrimSuffix(path, ext) + ".webp"
+		if _, err := os.Stat(path); err != nil && strings.EqualFold(ext, ".png") {
+			if _, err := os.Stat(webp); err == nil {
+				path = webp
+			}
+		}
+	}
+	if original, _, ok := Original(path); ok {
+		path = original
+	}
+	return path
+}
@@ -0,0 +1,99 @@
+// Package imageset makes the smaller copies of covers and posters that
+// templates use in srcset, and keeps track of their dimensions
+package imageset
+
+import (
+	"image"
+	"image/draw"
+)
+
+// Resize scales an image down to width pixels, keeping its aspect ratio.
+// Every output pixel is the area-weighted average of the source pixels it
+// covers, which keeps fine detail (text on covers) from aliasing. Images
+// that are already narrow enough are returned unchanged.
+func Resize(img image.Image, width int) image.Image {
+	b := img.Bounds()
+	if width <= 0 || width >= b.Dx() {
+		return img
+	}
+	height := (b.Dy()*width + b.Dx()/2) / b.Dx()
+	if height < 1 {
+		height = 1
+	}
+
+	src := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+
+	// Resize rows, then columns
+	tmp := resample(src.Pix, src.Stride, b.Dx(), b.Dy(), width, true)
+	out := image.NewNRGBA(image.Rect(0, 0, width, height))
+	pix := resample(tmp, width*4, width, b.Dy(), height, false)
+	copy(out.Pix, pix)
+	return out
+}
+
+// resample box-filters one axis of an NRGBA buffer. With horizontal set the
+// w x h buffer becomes size x h, otherwise w x size. Colors are weighted by
+// alpha so transparent pixels don't darken their neighbours.
+func resample(pix []uint8, stride, w, h, size int, horizontal bool) []uint8 {
+	srcLen, lines := h, w
+	outW, outH := w, size
+	if horizontal {
+		srcLen, lines = w, h
+		outW, outH = size, h
+	}
+	out := make([]uint8, outW*outH*4)
+	scale := float64(srcLen) / float64(size)
+
+	for line := 0; line < lines; line++ {
+		for i := 0; i < size; i++ {
+			start, end := float64(i)*scale, float64(i+1)*scale
+			var r, g, b, a, total float64
+			for s := int(start); s < srcLen && float64(s) < end; s++ {
+				weight := 1.0
+				if lo := float64(s); lo < start {
+					weight -= start - lo
+				}
+				if hi := float64(s + 1); hi > end {
+					weight -= hi - end
+				}
+				var p int
+				if horizontal {
+					p = line*stride + s*4
+				} else {
+					p = s*stride + line*4
+				}
+				alpha := float64(pix[p+3]) * weight
+				r += float64(pix[p]) * alpha
+				g += float64(pix[p+1]) * alpha
+				b += float64(pix[p+2]) * alpha
+				a += alpha
+				total += weight
+			}
+
+			var o int
+			if horizontal {
+				o = line*outW*4 + i*4
+			} else {
+				o = i*outW*4 + line*4
+			}
+			if a > 0 {
+				out[o] = round8(r / a)
+				out[o+1] = round8(g / a)
+				out[o+2] = round8(b / a)
+			}
+			out[o+3] = round8(a / total)
+		}
+	}
+	return out
+}
+
+func round8(v float64) uint8 {
+	switch {
+	case v <= 0:
+		return 0
+	case v >= 255:
+		return 255
+	}
+	return uint8(v + 0.5)
+}
@@ -0,0 +1,94 @@
+package imageset
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestResizeSize(t *testing.T) {
+	tests := []struct {
+		w, h, width  int
+		wantW, wantH int
+	}{
+		{500, 750, 320, 320, 480},
+		{600, 600, 160, 160, 160},
+		{1000, 333, 160, 160, 53},
+		{3, 2, 2, 2, 1},
+		{1000, 1, 10, 10, 1},
+		// Already narrow enough
+		{300, 450, 320, 300, 450},
+		{320, 480, 320, 320, 480},
+		{300, 450, 0, 300, 450},
+	}
+	for _, tt := range tests {
+		src := image.NewRGBA(image.Rect(0, 0, tt.w, tt.h))
+		got := Resize(src, tt.width).Bounds()
+		if got.Min != (image.Point{}) || got.Dx() != tt.wantW || got.Dy() != tt.wantH {
+			t.Errorf("%dx%d to %d: %v, want %dx%d", tt.w, tt.h, tt.width, got, tt.wantW, tt.wantH)
+		}
+	}
+}
+
+func TestResizeUnchanged(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	if got := Resize(src, 10); got != image.Image(src) {
+		t.Error("an image that is narrow enough was copied")
+	}
+}
+
+func TestResizeAverages(t *testing.T) {
+	tests := []struct {
+		name string
+		src  []color.NRGBA // one row, resized to one pixel
+		want color.NRGBA
+	}{
+		{
+			name: "average",
+			src:  []color.NRGBA{{0, 0, 0, 255}, {200, 100, 50, 255}},
+			want: color.NRGBA{100, 50, 25, 255},
+		},
+		{
+			name: "transparent pixels don't darken",
+			src:  []color.NRGBA{{0, 0, 0, 0}, {200, 100, 50, 255}},
+			want: color.NRGBA{200, 100, 50, 128},
+		},
+		{
+			name: "all transparent",
+			src:  []color.NRGBA{{10, 10, 10, 0}, {20, 20, 20, 0}},
+			want: color.NRGBA{0, 0, 0, 0},
+		},
+		{
+			name: "three pixels",
+			src:  []color.NRGBA{{0, 0, 0, 255}, {0, 0, 0, 255}, {90, 90, 90, 255}},
+			want: color.NRGBA{30, 30, 30, 255},
+		},
+	}
+	for _, tt := range tests {
+		src := image.NewNRGBA(image.Rect(0, 0, len(tt.src), 1))
+		for x, c := range tt.src {
+			src.SetNRGBA(x, 0, c)
+		}
+		if got := Resize(src, 1).(*image.NRGBA).NRGBAAt(0, 0); got != tt.want {
+			t.Errorf("%s: %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestResizeOffsetBounds(t *testing.T) {
+	// Left half black, right half white, in a sub-image that doesn't
+	// start at 0,0
+	full := image.NewGray(image.Rect(0, 0, 8, 4))
+	for y := 0; y < 4; y++ {
+		for x := 4; x < 8; x++ {
+			full.SetGray(x, y, color.Gray{255})
+		}
+	}
+	img := Resize(full.SubImage(image.Rect(2, 1, 8, 3)), 3)
+	want := []uint8{0, 255, 255}
+	for x, v := range want {
+		if r, _, _, _ := img.At(x, 0).RGBA(); uint8(r>>8) != v {
+			t.Errorf("pixel %d = %d, want %d", x, r>>8, v)
+		}
+	}
+}
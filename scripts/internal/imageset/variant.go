@@ -0,0 +1,72 @@
+package imageset
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Widths are the variants made of every image by default: grid cards are
+// 90-200 CSS pixels wide and the single page poster 280, so these cover 1x
+// and 2x screens, with the original as the largest candidate
+var Widths = []int{160, 320, 480}
+
+// Quality is the JPEG quality of the variants
+const Quality = 85
+
+// variantPattern matches the width suffix of a variant: cover_320w.jpg
+var variantPattern = regexp.MustCompile(`^(.+)_(\d+)w$`)
+
+// VariantPath returns the path of the copy of an image that is width pixels
+// wide: foo/cover.jpg becomes foo/cover_320w.jpg
+func VariantPath(path string, width int) string {
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%s_%dw%s", strings.TrimSuffix(path, ext), width, ext)
+}
+
+// Original returns the image a variant was made from. ok is false for
+// paths that aren't variants.
+func Original(path string) (original string, width int, ok bool) {
+	ext := filepath.Ext(path)
+	m := variantPattern.FindStringSubmatch(strings.TrimSuffix(path, ext))
+	if m == nil {
+		return "", 0, false
+	}
+	width, err := strconv.Atoi(m[2])
+	if err != nil {
+		return "", 0, false
+	}
+	return m[1] + ext, width, true
+}
+
+// IsVariant reports whether a file is itself a resized copy
+func IsVariant(path string) bool {
+	_, _, ok := Original(path)
+	return ok
+}
+
+// ParseWidths reads a comma-separated list of widths, e.g. "160,320,480",
+// and returns them sorted without duplicates
+func ParseWidths(s string) ([]int, error) {
+	seen := make(map[int]bool)
+	var widths []int
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		width, err := strconv.Atoi(field)
+		if err != nil || width <= 0 {
+			return nil, fmt.Errorf("invalid width %q", field)
+		}
+		if !seen[width] {
+			seen[width] = true
+			widths = append(widths, width)
+		}
+	}
+	sort.Ints(widths)
+	return widths, nil
+}
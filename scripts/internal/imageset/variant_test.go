@@ -0,0 +1,120 @@
+package imageset
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestVariantPath(t *testing.T) {
+	tests := []struct {
+		path  string
+		width int
+		want  string
+	}{
+		{"static/images/movies/bunny_poster.jpg", 320, "static/images/movies/bunny_poster_320w.jpg"},
+		{"cover.PNG", 160, "cover_160w.PNG"},
+		{"avatar.webp", 480, "avatar_480w.webp"},
+		{"no-extension", 160, "no-extension_160w"},
+		{"dir.v2/cover", 160, "dir.v2/cover_160w"},
+	}
+	for _, tt := range tests {
+		got := VariantPath(tt.path, tt.width)
+		if got != tt.want {
+			t.Errorf("VariantPath(%q, %d) = %q, want %q", tt.path, tt.width, got, tt.want)
+			continue
+		}
+		if original, width, ok := Original(got); !ok || original != tt.path || width != tt.width {
+			t.Errorf("Original(%q) = %q, %d, %v, want %q, %d", got, original, width, ok, tt.path, tt.width)
+		}
+	}
+}
+
+func TestOriginal(t *testing.T) {
+	tests := []struct {
+		path     string
+		original string
+		width    int
+		ok       bool
+	}{
+		{"cover_320w.jpg", "cover.jpg", 320, true},
+		{"my_cover_2010_160w.png", "my_cover_2010.png", 160, true},
+		{"cover_320w_320w.jpg", "cover_320w.jpg", 320, true},
+		{"cover.jpg", "", 0, false},
+		{"cover_w.jpg", "", 0, false},
+		{"cover_320.jpg", "", 0, false},
+		{"cover-320w.jpg", "", 0, false},
+		{"_320w.jpg", "", 0, false},
+		{"cover_320w_dithered.jpg", "", 0, false},
+		{"cover_99999999999999999999w.jpg", "", 0, false},
+	}
+	for _, tt := range tests {
+		original, width, ok := Original(tt.path)
+		if original != tt.original || width != tt.width || ok != tt.ok {
+			t.Errorf("Original(%q) = %q, %d, %v, want %q, %d, %v", tt.path, original, width, ok, tt.original, tt.width, tt.ok)
+		}
+		if IsVariant(tt.path) != tt.ok {
+			t.Errorf("IsVariant(%q) = %v, want %v", tt.path, !tt.ok, tt.ok)
+		}
+	}
+}
+
+func TestParseWidths(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []int
+		wantErr bool
+	}{
+		{in: "160,320,480", want: []int{160, 320, 480}},
+		{in: " 480, 160 ,320 ", want: []int{160, 320, 480}},
+		{in: "320,160,320", want: []int{160, 320}},
+		{in: "320,,", want: []int{320}},
+		{in: "", want: nil},
+		{in: "160,wide", wantErr: true},
+		{in: "0", wantErr: true},
+		{in: "-160", wantErr: true},
+		{in: "160.5", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseWidths(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseWidths(%q) error = %v, want error %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseWidths(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSource(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"avatar.webp", "both.webp", "both.png", "logo.png"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		path, want string
+	}{
+		{"cover.jpg", "cover.jpg"},
+		{"cover_dithered.jpg", "cover.jpg"},
+		{"cover_320w.jpg", "cover.jpg"},
+		{"cover_320w_dithered.jpg", "cover.jpg"},
+		{"cover.jpg.dither.toml", "cover.jpg"},
+		{"notes.txt", "notes.txt"},
+		// The PNG copy of a WebP original goes with the WebP
+		{"avatar_dithered.png", "avatar.webp"},
+		// unless there's a PNG original too
+		{"both_dithered.png", "both.png"},
+		{"logo_dithered.png", "logo.png"},
+		{"gone_dithered.png", "gone.png"},
+	}
+	for _, tt := range tests {
+		path, want := filepath.Join(dir, tt.path), filepath.Join(dir, tt.want)
+		if got := Source(path); got != want {
+			t.Errorf("Source(%s) = %s, want %s", tt.path, filepath.Base(got), tt.want)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+// Package journal records what a download run has done, entry by entry, so
+// that a run cut short (Ctrl-C, a crash, a lost connection) can be picked up
+// again with -resume instead of starting over, and keeps the files the run
+// replaced so it can be undone.
+package journal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Dir is where runs are recorded, relative to the site root. Every run gets
+// its own directory, named after its ID.
+const Dir = ".cache/runs"
+
+// Entry statuses. Pending entries haven't been processed (or were
+// interrupted); done and failed ones are not tried again on -resume.
+const (
+	Pending = "pending"
+	Done    = "done"
+	Failed  = "failed"
+)
+
+// ErrNoRun is returned by Latest when a script has never recorded a run
+var ErrNoRun = errors.New("no recorded run")
+
+// Entry is one title of a run
+type Entry struct {
+	Key    string `json:"key"` // see Key
+	Title  string `json:"title"`
+	Status string `json:"status"`
+}
+
+// Key is the key of an entry: its page's path relative to the site root,
+// or its title when it has no page (books.toml)
+func Key(baseDir, path, title string) string {
+	if path == "" {
+		return title
+	}
+	if rel, err := filepath.Rel(baseDir, path); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return filepath.ToSlash(path)
+}
+
+// Page returns the path of an entry's page, or false for entries keyed by
+// their title
+func (e Entry) Page(baseDir string) (string, bool) {
+	if !strings.HasSuffix(e.Key, ".md") {
+		return "", false
+	}
+	return filepath.Join(baseDir, filepath.FromSlash(e.Key)), true
+}
+
+// Journal is the record of one run of a script
+type Journal struct {
+	ID       string    `json:"id"`
+	Script   string    `json:"script"`
+	Started  time.Time `json:"started"`
+	Finished bool      `json:"finished"`
+	Undone   bool      `json:"undone,omitempty"`
+	Entries  []Entry   `json:"entries"`
+	Backups  []Backup  `json:"backups,omitempty"`
+
+	baseDir string
+	path    string
+	mu      sync.Mutex
+}
+
+// New records the start of a run of script over entries, all pending
+func New(baseDir, script string, entries []Entry) (*Journal, error) {
+	runsDir := filepath.Join(baseDir, Dir)
+	if err := os.MkdirAll(runsDir, 0755); err != nil {
+		return nil, err
+	}
+
+	// IDs sort by start time; a second run within the same second gets a suffix
+	started := time.Now()
+	base := started.Format("20060102-150405") + "-" + script
+	id := base
+	for n := 2; ; n++ {
+		err := os.Mkdir(filepath.Join(runsDir, id), 0755)
+		if err == nil {
+			break
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		id = fmt.Sprintf("%s-%d", base, n)
+	}
+
+	j := &Journal{
+		ID:      id,
+		Script:  script,
+		Started: started,
+		Entries: make([]Entry, len(entries)),
+		baseDir: baseDir,
+		path:    filepath.Join(runsDir, id, "journal.json"),
+	}
+	for i, e := range entries {
+		if e.Key == "" {
+			os.Remove(filepath.Dir(j.path))
+			return nil, fmt.Errorf("%q has no key", e.Title)
+		}
+		e.Status = Pending
+		j.Entries[i] = e
+	}
+	return j, j.save()
+}
+
+// Load reads the journal of a run
+func Load(baseDir, id string) (*Journal, error) {
+	path := filepath.Join(baseDir, Dir, id, "journal.json")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var j Journal
+	if err := json.Unmarshal(data, &j); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	j.baseDir = baseDir
+	j.path = path
+	return &j, nil
+}
+
+// Latest loads the most recent run of script, finished or not
+func Latest(baseDir, script string) (*Journal, error) {
+	ids, err := Runs(baseDir)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(ids) - 1; i >= 0; i-- {
+		j, err := Load(baseDir, ids[i])
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if j.Script == script {
+			return j, nil
+		}
+	}
+	return nil, fmt.Errorf("%s: %w", script, ErrNoRun)
+}
+
+// Runs lists the IDs of all recorded runs, oldest first
+func Runs(baseDir string) ([]string, error) {
+	dirEntries, err := os.ReadDir(filepath.Join(baseDir, Dir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, d := range dirEntries {
+		if d.IsDir() && !strings.HasPrefix(d.Name(), ".") {
+			ids = append(ids, d.Name())
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// Pending returns the entries still to be processed, in run order
+func (j *Journal) Pending() []Entry {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	var pending []Entry
+	for _, e := range j.Entries {
+		if e.Status == Pending {
+			pending = append(pending, e)
+		}
+	}
+	return pending
+}
+
+// Mark records the status of every entry with key (a title can be given
+// twice) and saves the journal
+func (j *Journal) Mark(key, status string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	found := false
+	for i := range j.Entries {
+		if j.Entries[i].Key == key {
+			j.Entries[i].Status = status
+			found = true
+		}
+	}
+	if !found {
+		return fmt.Errorf("%s is not part of run %s", key, j.ID)
+	}
+	return j.save()
+}
+
+// Finish records that the run got through all of its entries
+func (j *Journal) Finish() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, e := range j.Entries {
+		if e.Status == Pending {
+			return nil
+		}
+	}
+	j.Finished = true
+	return j.save()
+}
+
+// save writes the journal through a temporary file, so an interrupted save
+// leaves the previous version
+func (j *Journal) save() error {
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := j.path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, j.path)
+}
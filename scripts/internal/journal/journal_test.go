@@ -0,0 +1,250 @@
+package journal
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestKey(t *testing.T) {
+	base := "site"
+	tests := []struct {
+		path, title, want string
+	}{
+		{filepath.Join(base, "content", "en", "consumed", "movie", "bunny.md"), "Bunny", "content/en/consumed/movie/bunny.md"},
+		{"", "Dune", "Dune"},
+	}
+	for _, tt := range tests {
+		key := Key(base, tt.path, tt.title)
+		if key != tt.want {
+			t.Errorf("Key(%q, %q) = %q, want %q", tt.path, tt.title, key, tt.want)
+		}
+		path, ok := Entry{Key: key}.Page(base)
+		if ok != (tt.path != "") || path != tt.path {
+			t.Errorf("Page of %q = %q, %v, want %q", key, path, ok, tt.path)
+		}
+	}
+}
+
+func TestMarkPendingFinish(t *testing.T) {
+	base := t.TempDir()
+	entries := []Entry{
+		{Key: "content/en/consumed/movie/bunny.md", Title: "Bunny"},
+		{Key: "Dune", Title: "Dune"},
+		{Key: "Dune", Title: "Dune"}, // given twice
+		{Key: "content/en/consumed/movie/heat.md", Title: "Heat"},
+	}
+	j, err := New(base, "movies", entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := j.Pending(); len(got) != 4 || got[0].Status != Pending {
+		t.Fatalf("Pending = %+v, want every entry", got)
+	}
+
+	if err := j.Mark("Dune", Done); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Mark("content/en/consumed/movie/bunny.md", Failed); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Mark("Not in the run", Done); err == nil {
+		t.Error("marking an unknown key succeeded")
+	}
+	want := []Entry{{Key: "content/en/consumed/movie/heat.md", Title: "Heat", Status: Pending}}
+	if got := j.Pending(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Pending = %+v, want %+v", got, want)
+	}
+
+	if err := j.Finish(); err != nil || j.Finished {
+		t.Errorf("Finish with an entry pending: finished = %v, %v", j.Finished, err)
+	}
+	// What -resume sees
+	loaded, err := Latest(base, "movies")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := loaded.Pending(); !reflect.DeepEqual(got, want) {
+		t.Errorf("loaded Pending = %+v, want %+v", got, want)
+	}
+
+	if err := loaded.Mark("content/en/consumed/movie/heat.md", Done); err != nil {
+		t.Fatal(err)
+	}
+	if err := loaded.Finish(); err != nil || !loaded.Finished {
+		t.Errorf("Finish = %v, finished = %v", err, loaded.Finished)
+	}
+	if again, _ := Load(base, j.ID); !again.Finished {
+		t.Error("Finished wasn't saved")
+	}
+}
+
+func TestNewRejectsEmptyKey(t *testing.T) {
+	base := t.TempDir()
+	_, err := New(base, "movies", []Entry{{Key: "a.md", Title: "A"}, {Title: "No page"}})
+	if err == nil {
+		t.Fatal("New accepted an entry without a key")
+	}
+	if ids, _ := Runs(base); len(ids) != 0 {
+		t.Errorf("left runs %v behind", ids)
+	}
+}
+
+func TestLatest(t *testing.T) {
+	base := t.TempDir()
+	if _, err := Latest(base, "movies"); !errors.Is(err, ErrNoRun) {
+		t.Errorf("err = %v, want ErrNoRun", err)
+	}
+	first, _ := New(base, "movies", []Entry{{Key: "a.md"}})
+	New(base, "music", []Entry{{Key: "b.md"}})
+	second, _ := New(base, "movies", []Entry{{Key: "c.md"}})
+	if first.ID == second.ID {
+		t.Fatalf("two runs got ID %s", first.ID)
+	}
+	latest, err := Latest(base, "movies")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest.ID != second.ID {
+		t.Errorf("Latest = %s, want %s", latest.ID, second.ID)
+	}
+}
+
+func write(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func read(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestBackupRestore(t *testing.T) {
+	base := t.TempDir()
+	page := filepath.Join(base, "content", "en", "consumed", "movie", "bunny.md")
+	poster := filepath.Join(base, "static", "images", "movies", "bunny_2025_poster.jpg")
+	write(t, page, "before")
+
+	j, err := New(base, "movies", []Entry{{Key: "content/en/consumed/movie/bunny.md"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{page, poster} {
+		if err := j.Backup(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(t, page, "first write")
+	// Only the first backup of a path copies it
+	if err := j.Backup(page); err != nil {
+		t.Fatal(err)
+	}
+	write(t, page, "second write")
+	write(t, poster, "poster")
+	for _, path := range []string{page, poster} {
+		if err := j.Written(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := j.Written(filepath.Join(base, "other.md")); err == nil {
+		t.Error("Written without a Backup succeeded")
+	}
+
+	// What undo sees
+	loaded, err := Load(base, j.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.Backups) != 2 {
+		t.Fatalf("Backups = %+v, want the page and the poster", loaded.Backups)
+	}
+	for _, b := range loaded.Backups {
+		if changed, err := loaded.Changed(b); changed || err != nil {
+			t.Errorf("%s: Changed = %v, %v, want false", b.Path, changed, err)
+		}
+	}
+	write(t, page, "edited since")
+	if changed, _ := loaded.Changed(loaded.Backups[0]); !changed {
+		t.Error("an edit after the run isn't reported")
+	}
+
+	for _, b := range loaded.Backups {
+		if err := loaded.Restore(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := read(t, page); got != "before" {
+		t.Errorf("page restored to %q, want %q", got, "before")
+	}
+	if _, err := os.Stat(poster); !os.IsNotExist(err) {
+		t.Errorf("the poster the run created is still there (%v)", err)
+	}
+	// Restoring again is harmless
+	for _, b := range loaded.Backups {
+		if err := loaded.Restore(b); err != nil {
+			t.Errorf("second Restore of %s: %v", b.Path, err)
+		}
+	}
+}
+
+func TestBackupRemoved(t *testing.T) {
+	base := t.TempDir()
+	old := filepath.Join(base, "static", "images", "movies", "bunny_poster.jpg")
+	renamed := filepath.Join(base, "static", "images", "movies", "bunny_2025_poster.jpg")
+	write(t, old, "poster")
+
+	j, err := New(base, "rename-artwork", []Entry{{Key: "/images/movies/bunny_poster.jpg"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{old, renamed} {
+		if err := j.Backup(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Rename(old, renamed); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{old, renamed} {
+		if err := j.Written(path); err != nil {
+			t.Fatalf("Written(%s): %v", path, err)
+		}
+	}
+
+	loaded, _ := Load(base, j.ID)
+	for _, b := range loaded.Backups {
+		if changed, err := loaded.Changed(b); changed || err != nil {
+			t.Errorf("%s: Changed = %v, %v, want false", b.Path, changed, err)
+		}
+	}
+	// Something new where the run removed a file is a change
+	write(t, old, "another poster")
+	if changed, _ := loaded.Changed(loaded.Backups[0]); !changed {
+		t.Error("a file put where the run removed one isn't reported")
+	}
+	os.Remove(old)
+
+	for _, b := range loaded.Backups {
+		if err := loaded.Restore(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := read(t, old); got != "poster" {
+		t.Errorf("renamed file restored to %q", got)
+	}
+	if _, err := os.Stat(renamed); !os.IsNotExist(err) {
+		t.Errorf("the new name is still there (%v)", err)
+	}
+}
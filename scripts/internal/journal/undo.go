@@ -0,0 +1,148 @@
+package journal
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/consumed"
+)
+
+// Backup is a file a run replaced or created. Files that existed are copied
+// to files/<path> in the run's directory before they are first replaced.
+type Backup struct {
+	Path    string `json:"path"`              // relative to the site root
+	Existed bool   `json:"existed"`           // false for files the run created
+	Written string `json:"written,omitempty"` // SHA-256 of what the run left there
+	Removed bool   `json:"removed,omitempty"` // the run left no file there (renamed away)
+}
+
+// Backup keeps the current content of path before the run replaces it.
+// Only the first call for a path copies anything, so undoing goes back to
+// how the file was before the run, however often the run wrote it.
+func (j *Journal) Backup(path string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	rel, err := j.rel(path)
+	if err != nil {
+		return err
+	}
+	if j.backup(rel) != nil {
+		return nil
+	}
+
+	b := Backup{Path: rel}
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		b.Existed = true
+		saved := j.savedPath(rel)
+		if err := os.MkdirAll(filepath.Dir(saved), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(saved, data, 0644); err != nil {
+			return err
+		}
+	case !os.IsNotExist(err):
+		return err
+	}
+	j.Backups = append(j.Backups, b)
+	return j.save()
+}
+
+// Written records what the run left at path, after Backup, so undo can tell
+// whether the file was changed since. A path the run removed is recorded as
+// such.
+func (j *Journal) Written(path string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	rel, err := j.rel(path)
+	if err != nil {
+		return err
+	}
+	b := j.backup(rel)
+	if b == nil {
+		return fmt.Errorf("%s was written without a backup", rel)
+	}
+	b.Written, err = fileHash(path)
+	b.Removed = os.IsNotExist(err)
+	if err != nil && !b.Removed {
+		return err
+	}
+	return j.save()
+}
+
+// Changed reports whether a file is no longer what the run left there
+func (j *Journal) Changed(b Backup) (bool, error) {
+	hash, err := fileHash(filepath.Join(j.baseDir, filepath.FromSlash(b.Path)))
+	if os.IsNotExist(err) {
+		return !b.Removed, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return b.Removed || hash != b.Written, nil
+}
+
+// Restore puts a file back the way it was before the run: the saved copy
+// if it existed, otherwise no file at all
+func (j *Journal) Restore(b Backup) error {
+	path := filepath.Join(j.baseDir, filepath.FromSlash(b.Path))
+	if !b.Existed {
+		err := os.Remove(path)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	data, err := os.ReadFile(j.savedPath(b.Path))
+	if err != nil {
+		return err
+	}
+	if current, err := os.ReadFile(path); err == nil && bytes.Equal(current, data) {
+		return nil
+	}
+	return consumed.WriteFile(path, data)
+}
+
+// MarkUndone records that the run's changes were reverted
+func (j *Journal) MarkUndone() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.Undone = true
+	return j.save()
+}
+
+func (j *Journal) backup(rel string) *Backup {
+	for i := range j.Backups {
+		if j.Backups[i].Path == rel {
+			return &j.Backups[i]
+		}
+	}
+	return nil
+}
+
+func (j *Journal) rel(path string) (string, error) {
+	rel, err := filepath.Rel(j.baseDir, path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// savedPath is where the copy of a file is kept
+func (j *Journal) savedPath(rel string) string {
+	return filepath.Join(filepath.Dir(j.path), "files", filepath.FromSlash(rel))
+}
+
+func fileHash(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,90 @@
+// Package metadata finds and strips the metadata of published images: EXIF
+// (camera, serial numbers, GPS location), XMP, IPTC and comments. Pixel
+// data is copied as is, so stripping is lossless.
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+const (
+	tagOrientation = 0x0112
+	tagGPSInfo     = 0x8825
+	tagGPSVersion  = 0x0000
+)
+
+// Info describes the metadata found in an image
+type Info struct {
+	Format      string   // jpeg, png or webp
+	Segments    []string // metadata blocks found, e.g. "EXIF", "XMP", "COM"
+	GPS         bool     // a GPS position is recorded (EXIF GPS tags or XMP)
+	Orientation int      // EXIF orientation, 1 (upright) to 8; 0 if unset
+}
+
+// HasMetadata reports whether anything would be stripped
+func (i Info) HasMetadata() bool {
+	return len(i.Segments) > 0
+}
+
+// parseEXIF reads the orientation and looks for GPS tags in a TIFF
+// structure (the payload of an EXIF block). Damaged data is ignored.
+func parseEXIF(tiff []byte, info *Info) {
+	tiff = bytes.TrimPrefix(tiff, []byte("Exif\x00\x00"))
+	if len(tiff) < 8 {
+		return
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return
+	}
+	if order.Uint16(tiff[2:]) != 42 {
+		return
+	}
+
+	for _, entry := range ifdEntries(tiff, order, order.Uint32(tiff[4:])) {
+		tag := order.Uint16(entry)
+		switch tag {
+		case tagOrientation:
+			if o := int(order.Uint16(entry[8:])); o >= 1 && o <= 8 {
+				info.Orientation = o
+			}
+		case tagGPSInfo:
+			// A GPS directory with anything besides the version is a location
+			for _, gps := range ifdEntries(tiff, order, order.Uint32(entry[8:])) {
+				if order.Uint16(gps) != tagGPSVersion {
+					info.GPS = true
+					break
+				}
+			}
+		}
+	}
+}
+
+// ifdEntries returns the 12-byte entries of the image file directory at
+// offset, or nil if it doesn't fit in tiff
+func ifdEntries(tiff []byte, order binary.ByteOrder, offset uint32) [][]byte {
+	if offset < 8 || uint64(offset)+2 > uint64(len(tiff)) {
+		return nil
+	}
+	count := int(order.Uint16(tiff[offset:]))
+	start := int(offset) + 2
+	if start+count*12 > len(tiff) {
+		return nil
+	}
+	entries := make([][]byte, count)
+	for i := range entries {
+		entries[i] = tiff[start+i*12 : start+(i+1)*12]
+	}
+	return entries
+}
+
+// xmpHasGPS looks for a position in an XMP packet
+func xmpHasGPS(xmp []byte) bool {
+	return bytes.Contains(xmp, []byte("GPSLatitude")) || bytes.Contains(xmp, []byte("GPSLongitude"))
+}
@@ -0,0 +1,55 @@
+package metadata
+
+import (
+	"image"
+	"testing"
+)
+
+func TestOrient(t *testing.T) {
+	// Stored as
+	//
+	//	a b c
+	//	d e f
+	const a, b, c, d, e, f = 10, 20, 30, 40, 50, 60
+	src := image.NewGray(image.Rect(0, 0, 3, 2))
+	copy(src.Pix, []uint8{a, b, c, d, e, f})
+
+	tests := map[int][][]uint8{
+		0: {{a, b, c}, {d, e, f}},
+		1: {{a, b, c}, {d, e, f}},
+		2: {{c, b, a}, {f, e, d}},
+		3: {{f, e, d}, {c, b, a}},
+		4: {{d, e, f}, {a, b, c}},
+		5: {{a, d}, {b, e}, {c, f}},
+		6: {{d, a}, {e, b}, {f, c}},
+		7: {{f, c}, {e, b}, {d, a}},
+		8: {{c, f}, {b, e}, {a, d}},
+		9: {{a, b, c}, {d, e, f}},
+	}
+	for orientation, want := range tests {
+		img := Orient(src, orientation)
+		bounds := img.Bounds()
+		if bounds.Dx() != len(want[0]) || bounds.Dy() != len(want) {
+			t.Errorf("orientation %d: %dx%d, want %dx%d", orientation, bounds.Dx(), bounds.Dy(), len(want[0]), len(want))
+			continue
+		}
+		for y, row := range want {
+			for x, v := range row {
+				r, _, _, _ := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+				if uint8(r>>8) != v {
+					t.Errorf("orientation %d: pixel %d,%d = %d, want %d", orientation, x, y, r>>8, v)
+				}
+			}
+		}
+	}
+}
+
+func TestOrientOffsetBounds(t *testing.T) {
+	// A sub-image doesn't start at 0,0
+	src := image.NewGray(image.Rect(0, 0, 4, 4))
+	src.Pix[1*4+2] = 200
+	img := Orient(src.SubImage(image.Rect(2, 1, 4, 2)), 2)
+	if r, _, _, _ := img.At(1, 0).RGBA(); r>>8 != 200 {
+		t.Errorf("mirrored sub-image pixel = %d, want 200", r>>8)
+	}
+}
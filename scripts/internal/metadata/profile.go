@@ -0,0 +1,96 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+var iccHeader = []byte("ICC_PROFILE\x00")
+
+// pngColorChunks describe how to display a PNG's colors
+var pngColorChunks = map[string]bool{"iCCP": true, "sRGB": true, "gAMA": true, "cHRM": true}
+
+// CopyProfile returns encoded, a re-encoded copy of original, with the
+// color profile of original: the ICC segments of a JPEG, or the iCCP, sRGB,
+// gAMA and cHRM chunks of a PNG. Go's encoders write none of them. The
+// Adobe segment isn't copied, as it describes the old encoding.
+func CopyProfile(original, encoded []byte) []byte {
+	var profile []byte
+	switch {
+	case isJPEG(original) && isJPEG(encoded):
+		for _, s := range jpegSegments(original) {
+			if s[1] == 0xE2 && bytes.HasPrefix(s[4:], iccHeader) {
+				profile = append(profile, s...)
+			}
+		}
+		// Right after SOI
+		return splice(encoded, 2, profile)
+	case bytes.HasPrefix(original, pngSignature) && bytes.HasPrefix(encoded, pngSignature):
+		for _, c := range pngChunks(original) {
+			if pngColorChunks[string(c[4:8])] {
+				profile = append(profile, c...)
+			}
+		}
+		chunks := pngChunks(encoded)
+		if len(chunks) == 0 {
+			return encoded
+		}
+		// Right after IHDR, which comes first
+		return splice(encoded, len(pngSignature)+len(chunks[0]), profile)
+	}
+	return encoded
+}
+
+func isJPEG(data []byte) bool {
+	return len(data) > 2 && data[0] == 0xFF && data[1] == 0xD8
+}
+
+func splice(data []byte, at int, insert []byte) []byte {
+	if len(insert) == 0 {
+		return data
+	}
+	out := make([]byte, 0, len(data)+len(insert))
+	out = append(out, data[:at]...)
+	out = append(out, insert...)
+	return append(out, data[at:]...)
+}
+
+// jpegSegments returns the segments before the image data, markers
+// included, stopping at the first damaged one
+func jpegSegments(data []byte) [][]byte {
+	var segments [][]byte
+	pos := 2
+	for pos+4 <= len(data) && data[pos] == 0xFF {
+		marker := data[pos+1]
+		if marker == 0xFF {
+			pos++
+			continue
+		}
+		if marker == 0xDA || marker == 0xD9 {
+			break
+		}
+		end := pos + 2 + int(binary.BigEndian.Uint16(data[pos+2:]))
+		if end > len(data) {
+			break
+		}
+		segments = append(segments, data[pos:end])
+		pos = end
+	}
+	return segments
+}
+
+// pngChunks returns the chunks of a PNG with their length and CRC,
+// stopping at the first damaged one
+func pngChunks(data []byte) [][]byte {
+	var chunks [][]byte
+	pos := len(pngSignature)
+	for pos+12 <= len(data) {
+		end := pos + 12 + int(binary.BigEndian.Uint32(data[pos:]))
+		if end > len(data) || end < pos {
+			break
+		}
+		chunks = append(chunks, data[pos:end])
+		pos = end
+	}
+	return chunks
+}
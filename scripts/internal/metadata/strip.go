@@ -0,0 +1,201 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+var (
+	pngSignature = []byte("\x89PNG\r\n\x1a\n")
+	exifHeader   = []byte("Exif\x00\x00")
+	xmpHeader    = []byte("http://ns.adobe.com/xap/1.0/\x00")
+)
+
+// ErrFormat is returned for files that aren't JPEG, PNG or WebP
+var ErrFormat = errors.New("not a JPEG, PNG or WebP image")
+
+// Inspect reports the metadata of an image without changing it
+func Inspect(data []byte) (Info, error) {
+	_, info, err := Strip(data)
+	return info, err
+}
+
+// Strip returns a copy of an image without its metadata, and what was
+// found. Color profiles (ICC, Adobe, gamma) are kept; an image re-encoded
+// afterwards only keeps them through CopyProfile. If there is nothing to
+// strip, data is returned as is.
+func Strip(data []byte) ([]byte, Info, error) {
+	switch {
+	case len(data) > 2 && data[0] == 0xFF && data[1] == 0xD8:
+		return stripJPEG(data)
+	case bytes.HasPrefix(data, pngSignature):
+		return stripPNG(data)
+	case len(data) > 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		return stripWebP(data)
+	}
+	return data, Info{}, ErrFormat
+}
+
+// stripJPEG drops the APP1 (EXIF, XMP), APP13 (IPTC), other application
+// and COM segments before the image data. APP0 (JFIF), APP2 (ICC profile)
+// and APP14 (Adobe color transform) are kept.
+func stripJPEG(data []byte) ([]byte, Info, error) {
+	info := Info{Format: "jpeg"}
+	out := make([]byte, 0, len(data))
+	out = append(out, data[:2]...)
+	pos := 2
+	for {
+		if pos+4 > len(data) || data[pos] != 0xFF {
+			return data, info, fmt.Errorf("damaged JPEG: bad marker at byte %d", pos)
+		}
+		marker := data[pos+1]
+		if marker == 0xFF {
+			// Fill byte
+			pos++
+			continue
+		}
+		if marker == 0xDA || marker == 0xD9 {
+			// Start of scan: the rest is image data
+			out = append(out, data[pos:]...)
+			break
+		}
+		end := pos + 2 + int(binary.BigEndian.Uint16(data[pos+2:]))
+		if end > len(data) {
+			return data, info, fmt.Errorf("damaged JPEG: segment at byte %d runs past the end", pos)
+		}
+		payload := data[pos+4 : end]
+
+		name := ""
+		switch {
+		case marker == 0xE1 && bytes.HasPrefix(payload, exifHeader):
+			name = "EXIF"
+			parseEXIF(payload, &info)
+		case marker == 0xE1 && bytes.HasPrefix(payload, xmpHeader):
+			name = "XMP"
+			info.GPS = info.GPS || xmpHasGPS(payload)
+		case marker == 0xE1:
+			name = "APP1"
+			info.GPS = info.GPS || xmpHasGPS(payload)
+		case marker == 0xED:
+			name = "IPTC"
+		case marker == 0xFE:
+			name = "COM"
+		case marker >= 0xE3 && marker <= 0xEF && marker != 0xEE:
+			name = fmt.Sprintf("APP%d", marker-0xE0)
+		}
+		if name != "" {
+			info.Segments = appendOnce(info.Segments, name)
+		} else {
+			out = append(out, data[pos:end]...)
+		}
+		pos = end
+	}
+
+	if !info.HasMetadata() {
+		return data, info, nil
+	}
+	return out, info, nil
+}
+
+// stripPNG drops the eXIf, text and tIME chunks
+func stripPNG(data []byte) ([]byte, Info, error) {
+	info := Info{Format: "png"}
+	out := make([]byte, 0, len(data))
+	out = append(out, pngSignature...)
+	pos := len(pngSignature)
+	for pos < len(data) {
+		if pos+12 > len(data) {
+			return data, info, fmt.Errorf("damaged PNG: truncated chunk at byte %d", pos)
+		}
+		length := int(binary.BigEndian.Uint32(data[pos:]))
+		kind := string(data[pos+4 : pos+8])
+		end := pos + 12 + length
+		if length < 0 || end > len(data) {
+			return data, info, fmt.Errorf("damaged PNG: %s chunk runs past the end", kind)
+		}
+		payload := data[pos+8 : pos+8+length]
+
+		switch kind {
+		case "eXIf":
+			info.Segments = appendOnce(info.Segments, "EXIF")
+			parseEXIF(payload, &info)
+		case "tEXt", "zTXt", "iTXt":
+			if bytes.HasPrefix(payload, []byte("XML:com.adobe.xmp\x00")) {
+				info.Segments = appendOnce(info.Segments, "XMP")
+				info.GPS = info.GPS || xmpHasGPS(payload)
+			} else {
+				info.Segments = appendOnce(info.Segments, "text")
+			}
+		case "tIME":
+			info.Segments = appendOnce(info.Segments, "tIME")
+		default:
+			out = append(out, data[pos:end]...)
+		}
+		pos = end
+		if kind == "IEND" {
+			break
+		}
+	}
+
+	if !info.HasMetadata() {
+		return data, info, nil
+	}
+	return out, info, nil
+}
+
+// stripWebP drops the EXIF and XMP chunks and clears their flags in the
+// VP8X header
+func stripWebP(data []byte) ([]byte, Info, error) {
+	info := Info{Format: "webp"}
+	out := make([]byte, 0, len(data))
+	out = append(out, data[:12]...)
+	vp8x := -1
+	pos := 12
+	for pos < len(data) {
+		if pos+8 > len(data) {
+			return data, info, fmt.Errorf("damaged WebP: truncated chunk at byte %d", pos)
+		}
+		kind := string(data[pos : pos+4])
+		length := int(binary.LittleEndian.Uint32(data[pos+4:]))
+		end := pos + 8 + length + length%2
+		if length < 0 || end > len(data) {
+			return data, info, fmt.Errorf("damaged WebP: %s chunk runs past the end", kind)
+		}
+		payload := data[pos+8 : pos+8+length]
+
+		switch kind {
+		case "EXIF":
+			info.Segments = appendOnce(info.Segments, "EXIF")
+			parseEXIF(payload, &info)
+		case "XMP ":
+			info.Segments = appendOnce(info.Segments, "XMP")
+			info.GPS = info.GPS || xmpHasGPS(payload)
+		default:
+			if kind == "VP8X" && length > 0 {
+				vp8x = len(out) + 8
+			}
+			out = append(out, data[pos:end]...)
+		}
+		pos = end
+	}
+
+	if !info.HasMetadata() {
+		return data, info, nil
+	}
+	if vp8x >= 0 {
+		out[vp8x] &^= 0x08 | 0x04 // EXIF and XMP present
+	}
+	binary.LittleEndian.PutUint32(out[4:], uint32(len(out)-8))
+	return out, info, nil
+}
+
+func appendOnce(list []string, s string) []string {
+	for _, existing := range list {
+		if existing == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
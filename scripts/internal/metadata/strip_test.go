@@ -0,0 +1,322 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"reflect"
+	"testing"
+)
+
+// byteOrder is binary.LittleEndian or binary.BigEndian
+type byteOrder interface {
+	binary.ByteOrder
+	binary.AppendByteOrder
+}
+
+// exif builds an EXIF payload with an orientation (0 for none) and, if gps
+// isn't nil, a GPS directory holding those tags
+func exif(order byteOrder, orientation int, gps []uint16) []byte {
+	var entries [][3]uint32 // tag, type, value
+	if orientation > 0 {
+		entries = append(entries, [3]uint32{tagOrientation, 3, uint32(orientation)})
+	}
+	gpsOffset := 8 + 2 + 12*(len(entries)+1) + 4
+	if gps != nil {
+		entries = append(entries, [3]uint32{tagGPSInfo, 4, uint32(gpsOffset)})
+	}
+
+	buf := []byte("Exif\x00\x00")
+	tiff := make([]byte, 8)
+	if order == binary.LittleEndian {
+		copy(tiff, "II")
+	} else {
+		copy(tiff, "MM")
+	}
+	order.PutUint16(tiff[2:], 42)
+	order.PutUint32(tiff[4:], 8)
+	tiff = ifd(tiff, order, entries)
+	if gps != nil {
+		var gpsEntries [][3]uint32
+		for _, tag := range gps {
+			gpsEntries = append(gpsEntries, [3]uint32{uint32(tag), 4, 1})
+		}
+		tiff = ifd(tiff, order, gpsEntries)
+	}
+	return append(buf, tiff...)
+}
+
+func ifd(tiff []byte, order byteOrder, entries [][3]uint32) []byte {
+	tiff = order.AppendUint16(tiff, uint16(len(entries)))
+	for _, e := range entries {
+		tiff = order.AppendUint16(tiff, uint16(e[0]))
+		tiff = order.AppendUint16(tiff, uint16(e[1]))
+		tiff = order.AppendUint32(tiff, 1)
+		if e[1] == 3 {
+			tiff = order.AppendUint16(tiff, uint16(e[2]))
+			tiff = append(tiff, 0, 0)
+		} else {
+			tiff = order.AppendUint32(tiff, e[2])
+		}
+	}
+	return order.AppendUint32(tiff, 0) // no next directory
+}
+
+func TestParseEXIF(t *testing.T) {
+	const latitude = 0x0002
+	tests := []struct {
+		name        string
+		payload     []byte
+		orientation int
+		gps         bool
+	}{
+		{"orientation", exif(binary.LittleEndian, 6, nil), 6, false},
+		{"big endian", exif(binary.BigEndian, 8, nil), 8, false},
+		{"GPS version only", exif(binary.LittleEndian, 0, []uint16{tagGPSVersion}), 0, false},
+		{"GPS position", exif(binary.BigEndian, 1, []uint16{tagGPSVersion, latitude}), 1, true},
+		{"out of range orientation", exif(binary.LittleEndian, 9, nil), 0, false},
+		{"truncated", exif(binary.LittleEndian, 6, nil)[:14], 0, false},
+		{"not TIFF", []byte("Exif\x00\x00XX\x00\x2a\x00\x00\x00\x08"), 0, false},
+	}
+	for _, tt := range tests {
+		var info Info
+		parseEXIF(tt.payload, &info)
+		if info.Orientation != tt.orientation || info.GPS != tt.gps {
+			t.Errorf("%s: orientation %d, GPS %v, want %d, %v", tt.name, info.Orientation, info.GPS, tt.orientation, tt.gps)
+		}
+	}
+}
+
+func segment(marker byte, payload []byte) []byte {
+	s := []byte{0xFF, marker, 0, 0}
+	binary.BigEndian.PutUint16(s[2:], uint16(len(payload)+2))
+	return append(s, payload...)
+}
+
+func testJPEG(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, 8, 4)), nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestStripJPEG(t *testing.T) {
+	encoded := testJPEG(t)
+	kept := [][]byte{
+		segment(0xE0, []byte("JFIF\x00\x01\x01\x00\x00\x01\x00\x01\x00\x00")),
+		segment(0xE2, append([]byte("ICC_PROFILE\x00\x01\x01"), "profile"...)),
+		segment(0xEE, []byte("Adobe\x00\x64\x00\x00\x00\x00\x01")),
+	}
+	dropped := [][]byte{
+		segment(0xE1, exif(binary.LittleEndian, 3, []uint16{tagGPSVersion, 0x0004})),
+		segment(0xE1, append(append([]byte{}, xmpHeader...), "<x:xmpmeta/>"...)),
+		segment(0xED, []byte("Photoshop 3.0\x00")),
+		segment(0xE5, []byte("maker notes")),
+		segment(0xFE, []byte("a comment")),
+	}
+	data := append([]byte{}, encoded[:2]...)
+	for i := range kept {
+		data = append(data, kept[i]...)
+		data = append(data, dropped[i]...)
+	}
+	for _, s := range dropped[len(kept):] {
+		data = append(data, s...)
+	}
+	data = append(data, encoded[2:]...)
+
+	out, info, err := Strip(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Info{Format: "jpeg", Segments: []string{"EXIF", "XMP", "IPTC", "APP5", "COM"}, GPS: true, Orientation: 3}
+	if !reflect.DeepEqual(info, want) {
+		t.Errorf("info = %+v, want %+v", info, want)
+	}
+	wantOut := append([]byte{}, encoded[:2]...)
+	for _, s := range kept {
+		wantOut = append(wantOut, s...)
+	}
+	wantOut = append(wantOut, encoded[2:]...)
+	if !bytes.Equal(out, wantOut) {
+		t.Error("output isn't the image with only APP0, APP2 and APP14 left")
+	}
+	if _, err := jpeg.Decode(bytes.NewReader(out)); err != nil {
+		t.Errorf("stripped image doesn't decode: %v", err)
+	}
+
+	// Nothing to strip
+	clean := append(append(append([]byte{}, encoded[:2]...), kept[0]...), encoded[2:]...)
+	if out, info, err := Strip(clean); err != nil || info.HasMetadata() || &out[0] != &clean[0] {
+		t.Errorf("clean image: %+v, %v, want it returned as is", info, err)
+	}
+}
+
+func TestXMPHasGPS(t *testing.T) {
+	data := testJPEG(t)
+	xmp := segment(0xE1, append(append([]byte{}, xmpHeader...), `<rdf:Description exif:GPSLatitude="45,30N"/>`...))
+	data = append(append(append([]byte{}, data[:2]...), xmp...), data[2:]...)
+	if info, _ := Inspect(data); !info.GPS {
+		t.Error("GPS position in XMP not found")
+	}
+}
+
+func chunk(kind string, payload []byte) []byte {
+	c := binary.BigEndian.AppendUint32(nil, uint32(len(payload)))
+	c = append(c, kind...)
+	c = append(c, payload...)
+	return binary.BigEndian.AppendUint32(c, crc32.ChecksumIEEE(c[4:]))
+}
+
+// testPNG encodes a paletted image, so it has a PLTE chunk, and returns it
+// with the IHDR chunk and the rest apart
+func testPNG(t *testing.T) (ihdr, rest []byte) {
+	t.Helper()
+	img := image.NewPaletted(image.Rect(0, 0, 4, 4), color.Palette{color.Black, color.White})
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	end := len(pngSignature) + 25
+	return data[:end], data[end:]
+}
+
+func TestStripPNG(t *testing.T) {
+	ihdr, rest := testPNG(t)
+	kept := [][]byte{
+		chunk("iCCP", []byte("profile\x00\x00data")),
+		chunk("gAMA", []byte{0, 0, 0xB1, 0x8F}),
+	}
+	dropped := [][]byte{
+		chunk("eXIf", exif(binary.BigEndian, 6, nil)[6:]),
+		chunk("tEXt", []byte("Software\x00a camera")),
+		chunk("iTXt", []byte("XML:com.adobe.xmp\x00\x00\x00\x00\x00<exif:GPSLongitude>1</exif:GPSLongitude>")),
+		chunk("tIME", []byte{0x07, 0xE8, 1, 1, 0, 0, 0}),
+	}
+	data := append([]byte{}, ihdr...)
+	data = append(data, kept[0]...)
+	for _, c := range dropped {
+		data = append(data, c...)
+	}
+	data = append(data, kept[1]...)
+	data = append(data, rest...)
+
+	out, info, err := Strip(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Info{Format: "png", Segments: []string{"EXIF", "text", "XMP", "tIME"}, GPS: true, Orientation: 6}
+	if !reflect.DeepEqual(info, want) {
+		t.Errorf("info = %+v, want %+v", info, want)
+	}
+	wantOut := append(append(append(append([]byte{}, ihdr...), kept[0]...), kept[1]...), rest...)
+	if !bytes.Equal(out, wantOut) {
+		t.Error("output isn't the image with only iCCP, gAMA and PLTE left")
+	}
+	if !bytes.Contains(out, []byte("PLTE")) {
+		t.Error("PLTE dropped")
+	}
+	if _, err := png.Decode(bytes.NewReader(out)); err != nil {
+		t.Errorf("stripped image doesn't decode: %v", err)
+	}
+}
+
+func riffChunk(kind string, payload []byte) []byte {
+	c := append([]byte(kind), 0, 0, 0, 0)
+	binary.LittleEndian.PutUint32(c[4:], uint32(len(payload)))
+	c = append(c, payload...)
+	if len(payload)%2 == 1 {
+		c = append(c, 0)
+	}
+	return c
+}
+
+func webp(chunks ...[]byte) []byte {
+	data := []byte("RIFF\x00\x00\x00\x00WEBP")
+	for _, c := range chunks {
+		data = append(data, c...)
+	}
+	binary.LittleEndian.PutUint32(data[4:], uint32(len(data)-8))
+	return data
+}
+
+func TestStripWebP(t *testing.T) {
+	const iccFlag, exifFlag, xmpFlag = 0x20, 0x08, 0x04
+	vp8x := func(flags byte) []byte {
+		return riffChunk("VP8X", []byte{flags, 0, 0, 0, 3, 0, 0, 3, 0, 0})
+	}
+	iccp := riffChunk("ICCP", []byte("profile"))
+	image := riffChunk("VP8L", []byte("pixels, odd"))
+	data := webp(vp8x(iccFlag|exifFlag|xmpFlag), iccp, image,
+		riffChunk("EXIF", exif(binary.LittleEndian, 8, nil)[6:]),
+		riffChunk("XMP ", []byte("<x:xmpmeta/>")))
+
+	out, info, err := Strip(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Info{Format: "webp", Segments: []string{"EXIF", "XMP"}, Orientation: 8}
+	if !reflect.DeepEqual(info, want) {
+		t.Errorf("info = %+v, want %+v", info, want)
+	}
+	if wantOut := webp(vp8x(iccFlag), iccp, image); !bytes.Equal(out, wantOut) {
+		t.Errorf("got\n%q\nwant\n%q", out, wantOut)
+	}
+}
+
+func TestStripErrors(t *testing.T) {
+	ihdr, _ := testPNG(t)
+	tests := map[string][]byte{
+		"GIF":            []byte("GIF89a..."),
+		"damaged JPEG":   append(testJPEG(t)[:2], 0x00, 0x01, 0x02, 0x03),
+		"truncated JPEG": append(testJPEG(t)[:2], segment(0xE1, []byte("Exif\x00\x00"))[:6]...),
+		"truncated PNG":  ihdr[:len(ihdr)-4],
+		"truncated WebP": webp(riffChunk("VP8L", []byte("pixels")))[:20],
+	}
+	for name, data := range tests {
+		if _, _, err := Strip(data); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+	if _, _, err := Strip([]byte("GIF89a")); err != ErrFormat {
+		t.Errorf("GIF: err = %v, want ErrFormat", err)
+	}
+}
+
+func TestCopyProfile(t *testing.T) {
+	icc := segment(0xE2, append([]byte("ICC_PROFILE\x00\x01\x01"), "profile"...))
+	original := testJPEG(t)
+	original = append(append(append([]byte{}, original[:2]...), icc...), original[2:]...)
+	encoded := testJPEG(t)
+	out := CopyProfile(original, encoded)
+	if want := append(append(append([]byte{}, encoded[:2]...), icc...), encoded[2:]...); !bytes.Equal(out, want) {
+		t.Error("JPEG: ICC segment not copied after SOI")
+	}
+	if _, err := jpeg.Decode(bytes.NewReader(out)); err != nil {
+		t.Errorf("JPEG with the profile doesn't decode: %v", err)
+	}
+
+	ihdr, rest := testPNG(t)
+	iccp := chunk("iCCP", []byte("profile\x00\x00data"))
+	text := chunk("tEXt", []byte("Software\x00a camera"))
+	original = append(append(append(append([]byte{}, ihdr...), iccp...), text...), rest...)
+	encoded = append(append([]byte{}, ihdr...), rest...)
+	out = CopyProfile(original, encoded)
+	if want := append(append(append([]byte{}, ihdr...), iccp...), rest...); !bytes.Equal(out, want) {
+		t.Error("PNG: iCCP chunk not copied after IHDR, or text copied too")
+	}
+	if _, err := png.Decode(bytes.NewReader(out)); err != nil {
+		t.Errorf("PNG with the profile doesn't decode: %v", err)
+	}
+
+	// Nothing to copy
+	if out := CopyProfile(encoded, encoded); !bytes.Equal(out, encoded) {
+		t.Error("PNG without a profile changed")
+	}
+}
@@ -0,0 +1,111 @@
+// Package secrets loads the provider keys and tokens the scripts use, and
+// keeps them out of what the scripts print.
+//
+// A secret named e.g. TMDB_API_KEY is taken from the first of:
+//
+//   - TMDB_API_KEY itself, from the environment or .env
+//   - the file named by TMDB_API_KEY_FILE
+//   - the output of the command in TMDB_API_KEY_COMMAND, run by sh (e.g.
+//     a password manager's CLI)
+package secrets
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// Redacted replaces secrets in redacted text
+const Redacted = "REDACTED"
+
+// MinLength is the length below which values aren't redacted: real keys
+// are longer, and short ones such as "fake" would match ordinary text
+const MinLength = 8
+
+var known struct {
+	sync.RWMutex
+	values []string
+}
+
+// Lookup returns the secret called name, or "" if none of its sources is
+// set. The value is registered for Redact.
+func Lookup(name string) (string, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		if path := os.Getenv(name + "_FILE"); path != "" {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return "", fmt.Errorf("%s_FILE: %w", name, err)
+			}
+			value = string(data)
+		}
+	}
+	if value == "" {
+		if command := os.Getenv(name + "_COMMAND"); command != "" {
+			cmd := exec.Command("sh", "-c", command)
+			// Let the command prompt, e.g. to unlock a vault
+			cmd.Stdin, cmd.Stderr = os.Stdin, os.Stderr
+			out, err := cmd.Output()
+			if err != nil {
+				return "", fmt.Errorf("%s_COMMAND: %w", name, err)
+			}
+			value = string(out)
+		}
+	}
+	value = strings.TrimSpace(value)
+	Register(value)
+	return value, nil
+}
+
+// Register adds values for Redact to remove, e.g. secrets that weren't
+// loaded with Lookup
+func Register(values ...string) {
+	known.Lock()
+	defer known.Unlock()
+	for _, v := range values {
+		if len(v) >= MinLength {
+			known.values = append(known.values, v)
+		}
+	}
+}
+
+// Redact replaces the registered secrets in s
+func Redact(s string) string {
+	return string(Replace([]byte(s), Known()))
+}
+
+// Known returns the registered secrets
+func Known() []string {
+	known.RLock()
+	defer known.RUnlock()
+	return append([]string(nil), known.values...)
+}
+
+// Replace replaces each of values in data, ignoring values shorter than
+// MinLength
+func Replace(data []byte, values []string) []byte {
+	for _, v := range values {
+		if len(v) >= MinLength {
+			data = bytes.ReplaceAll(data, []byte(v), []byte(Redacted))
+		}
+	}
+	return data
+}
+
+// Error returns err with the registered secrets redacted from its message,
+// such as the URL of a failed request with a key in its query. errors.Is
+// and errors.As still see err.
+func Error(err error) error {
+	if err == nil {
+		return nil
+	}
+	return redactedError{err}
+}
+
+type redactedError struct{ err error }
+
+func (e redactedError) Error() string { return Redact(e.err.Error()) }
+func (e redactedError) Unwrap() error { return e.err }
@@ -0,0 +1,107 @@
+package secrets
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLookup(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "key")
+	if err := os.WriteFile(file, []byte("from-the-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    string
+		wantErr string
+	}{
+		{name: "unset"},
+		{name: "env", env: map[string]string{"TEST_SECRET": " from-the-env "}, want: "from-the-env"},
+		{name: "file", env: map[string]string{"TEST_SECRET_FILE": file}, want: "from-the-file"},
+		{name: "command", env: map[string]string{"TEST_SECRET_COMMAND": "echo from-the-command"}, want: "from-the-command"},
+		{
+			name: "env first",
+			env:  map[string]string{"TEST_SECRET": "from-the-env", "TEST_SECRET_FILE": file, "TEST_SECRET_COMMAND": "echo from-the-command"},
+			want: "from-the-env",
+		},
+		{
+			name: "file before command",
+			env:  map[string]string{"TEST_SECRET_FILE": file, "TEST_SECRET_COMMAND": "echo from-the-command"},
+			want: "from-the-file",
+		},
+		{name: "missing file", env: map[string]string{"TEST_SECRET_FILE": filepath.Join(dir, "missing")}, wantErr: "TEST_SECRET_FILE"},
+		{name: "failing command", env: map[string]string{"TEST_SECRET_COMMAND": "exit 3"}, wantErr: "TEST_SECRET_COMMAND"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{"TEST_SECRET", "TEST_SECRET_FILE", "TEST_SECRET_COMMAND"} {
+				t.Setenv(name, tt.env[name])
+			}
+			got, err := Lookup("TEST_SECRET")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want one naming %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Lookup = %q, want %q", got, tt.want)
+			}
+			if got != "" && Redact("key="+got) != "key="+Redacted {
+				t.Errorf("%q wasn't registered for Redact", got)
+			}
+		})
+	}
+}
+
+func TestRedact(t *testing.T) {
+	Register("registered-secret", "short")
+	tests := map[string]string{
+		"https://api.example.com/?api_key=registered-secret&q=x": "https://api.example.com/?api_key=" + Redacted + "&q=x",
+		"registered-secret registered-secret":                    Redacted + " " + Redacted,
+		"a short word stays":                                     "a short word stays",
+		"nothing to hide":                                        "nothing to hide",
+	}
+	for in, want := range tests {
+		if got := Redact(in); got != want {
+			t.Errorf("Redact(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestReplace(t *testing.T) {
+	got := Replace([]byte("token=abcdefgh1234 id=abc"), []string{"abcdefgh1234", "abc", ""})
+	if want := "token=" + Redacted + " id=abc"; string(got) != want {
+		t.Errorf("Replace = %q, want %q", got, want)
+	}
+}
+
+func TestError(t *testing.T) {
+	if Error(nil) != nil {
+		t.Error("Error(nil) != nil")
+	}
+	Register("error-secret")
+	err := Error(&fs.PathError{Op: "get", Path: "https://example.com/?api_key=error-secret", Err: fs.ErrNotExist})
+	if strings.Contains(err.Error(), "error-secret") {
+		t.Errorf("secret left in %q", err)
+	}
+	if !strings.Contains(err.Error(), Redacted) {
+		t.Errorf("%q doesn't say what was removed", err)
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Error("errors.Is doesn't see the wrapped error")
+	}
+	var pathErr *fs.PathError
+	if !errors.As(err, &pathErr) {
+		t.Error("errors.As doesn't see the wrapped error")
+	}
+}
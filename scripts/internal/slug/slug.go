@@ -0,0 +1,128 @@
+// Package slug turns titles into the slugs used for page filenames
+// (hyphens) and artwork filenames (underscores).
+//
+// Spaces and hyphens separate words, apostrophes and other punctuation are
+// dropped, accents are removed. Letters without an accent to drop (ß, ø,
+// Greek, Cyrillic) are transliterated, so the result only has a-z, 0-9 and
+// the separator, and Hugo keeps it as is for the page URL. The exception
+// is letters of scripts with no transliteration, such as CJK, which are
+// kept as they are: 千と千尋の神隠し stays 千と千尋の神隠し. That is
+// stricter than Hugo's urlize, which keeps ".", "+", "#" and accented
+// letters ("Mr. Robot" is mr-robot here, mr.-robot for urlize), so
+// templates link to pages by .RelPermalink.
+package slug
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Separators
+const (
+	Hyphen     = '-' // page filenames and URLs
+	Underscore = '_' // artwork filenames
+)
+
+// transliterations maps groups of lowercase letters to their ASCII spelling
+var transliterations = map[string]string{
+	// Latin
+	"àáâãäåāăąǎǻ":     "a",
+	"æǽ":              "ae",
+	"çćĉċč":           "c",
+	"ďđð":             "d",
+	"èéêëēĕėęěẽ":      "e",
+	"ĝğġģ":            "g",
+	"ĥħ":              "h",
+	"ìíîïĩīĭįıǐ":      "i",
+	"ĳ":               "ij",
+	"ĵ":               "j",
+	"ķ":               "k",
+	"ĺļľŀł":           "l",
+	"ñńņňŉŋ":          "n",
+	"òóôõöøōŏőǒǿ":     "o",
+	"œ":               "oe",
+	"ŕŗř":             "r",
+	"śŝşšș":           "s",
+	"ß":               "ss",
+	"ţťŧț":            "t",
+	"þ":               "th",
+	"ùúûüũūŭůűųǔǖǘǚǜ": "u",
+	"ŵ":               "w",
+	"ýÿŷỳ":            "y",
+	"źżž":             "z",
+	"ſ":               "s",
+	// Greek
+	"αά": "a", "β": "v", "γ": "g", "δ": "d", "εέ": "e", "ζ": "z", "ηή": "i",
+	"θ": "th", "ιίϊΐ": "i", "κ": "k", "λ": "l", "μ": "m", "ν": "n", "ξ": "x",
+	"οό": "o", "π": "p", "ρ": "r", "σς": "s", "τ": "t", "υύϋΰ": "y", "φ": "f",
+	"χ": "ch", "ψ": "ps", "ωώ": "o",
+	// Cyrillic (Russian, Ukrainian, Serbian)
+	"а": "a", "б": "b", "в": "v", "гґ": "g", "д": "d", "е": "e", "ё": "yo",
+	"ж": "zh", "з": "z", "иі": "i", "й": "y", "к": "k", "л": "l", "м": "m",
+	"н": "n", "о": "o", "п": "p", "р": "r", "с": "s", "т": "t", "у": "u",
+	"ф": "f", "х": "kh", "ц": "ts", "ч": "ch", "ш": "sh", "щ": "shch",
+	"ъь": "", "ы": "y", "э": "e", "ю": "yu", "я": "ya", "ї": "yi", "є": "ye",
+	"ђ": "dj", "ј": "j", "љ": "lj", "њ": "nj", "ћ": "c", "џ": "dz",
+}
+
+// ascii is transliterations by letter
+var ascii = func() map[rune]string {
+	m := make(map[rune]string)
+	for letters, s := range transliterations {
+		for _, r := range letters {
+			m[r] = s
+		}
+	}
+	return m
+}()
+
+// Make returns the slug of s with words joined by sep: "La mujer de la
+// fila" becomes la-mujer-de-la-fila, "Café Society" cafe-society and
+// "Can't Hurt Me" cant-hurt-me. Letters of scripts without a
+// transliteration (e.g. CJK) are kept, as Hugo does.
+func Make(s string, sep rune) string {
+	var b strings.Builder
+	pending := false
+	for _, r := range strings.ToLower(s) {
+		word := ""
+		switch {
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			word = string(r)
+		case ascii[r] != "" || isDropped(r):
+			word = ascii[r]
+		case unicode.Is(unicode.Mn, r):
+			// Combining accent of a decomposed letter
+			continue
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			word = string(r)
+		case unicode.IsSpace(r) || isSeparator(r):
+			pending = b.Len() > 0
+			continue
+		default:
+			// Other punctuation and symbols vanish without splitting words
+			continue
+		}
+		if word == "" {
+			continue
+		}
+		if pending {
+			b.WriteRune(sep)
+			pending = false
+		}
+		b.WriteString(word)
+	}
+	return b.String()
+}
+
+// isDropped reports letters that transliterate to nothing (hard and soft
+// signs)
+func isDropped(r rune) bool {
+	s, ok := ascii[r]
+	return ok && s == ""
+}
+
+// isSeparator reports punctuation that separates words: dashes, and the
+// characters Hugo keeps in paths but a slug can't (., _, /, \, +, #, ~, @)
+func isSeparator(r rune) bool {
+	return unicode.Is(unicode.Pd, r) || strings.ContainsRune(`._/\+#~@`, r)
+}
@@ -0,0 +1,39 @@
+package slug
+
+import "testing"
+
+func TestMake(t *testing.T) {
+	tests := []struct {
+		in, hyphen, underscore string
+	}{
+		{"La mujer de la fila", "la-mujer-de-la-fila", "la_mujer_de_la_fila"},
+		{"Café Society", "cafe-society", "cafe_society"},
+		{"Can't Hurt Me", "cant-hurt-me", "cant_hurt_me"},
+		{"Love & War", "love-war", "love_war"},
+		{"Mr. Robot", "mr-robot", "mr_robot"},
+		{"C++ Primer", "c-primer", "c_primer"},
+		{"C# in Depth", "c-in-depth", "c_in_depth"},
+		{"Spider-Man: No Way Home", "spider-man-no-way-home", "spider_man_no_way_home"},
+		{"Blade Runner — 2049", "blade-runner-2049", "blade_runner_2049"},
+		{"AC/DC", "ac-dc", "ac_dc"},
+		{"  Leading and   trailing  ", "leading-and-trailing", "leading_and_trailing"},
+		{"...", "", ""},
+		{"Die Blechtrommel: Straße", "die-blechtrommel-strasse", "die_blechtrommel_strasse"},
+		{"Søren Kierkegaard", "soren-kierkegaard", "soren_kierkegaard"},
+		{"Æon Flux", "aeon-flux", "aeon_flux"},
+		{"L'Œuvre", "loeuvre", "loeuvre"},
+		{"Война и мир", "voyna-i-mir", "voyna_i_mir"},
+		{"Οδύσσεια", "odysseia", "odysseia"},
+		{"Cafe\u0301 decomposed", "cafe-decomposed", "cafe_decomposed"},
+		{"千と千尋の神隠し", "千と千尋の神隠し", "千と千尋の神隠し"},
+		{"2001: A Space Odyssey", "2001-a-space-odyssey", "2001_a_space_odyssey"},
+	}
+	for _, tt := range tests {
+		if got := Make(tt.in, Hyphen); got != tt.hyphen {
+			t.Errorf("Make(%q, Hyphen) = %q, want %q", tt.in, got, tt.hyphen)
+		}
+		if got := Make(tt.in, Underscore); got != tt.underscore {
+			t.Errorf("Make(%q, Underscore) = %q, want %q", tt.in, got, tt.underscore)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package workpool
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// Interruptible returns a context that is cancelled on the first Ctrl-C (or
+// SIGTERM), so running jobs can finish the file they are writing and the
+// rest are left for -resume. A second Ctrl-C quits at once.
+func Interruptible() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		signal.Stop(signals)
+		fmt.Println("\n⏹️  Interrupted, finishing the pages being written (Ctrl-C again to quit now)")
+		cancel()
+	}()
+	return ctx
+}
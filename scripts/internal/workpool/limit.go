@@ -0,0 +1,126 @@
+package workpool
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limit caps the requests to one provider: how many may be in flight at
+// once, and the least time between two of them starting
+type Limit struct {
+	Concurrency int
+	Interval    time.Duration
+}
+
+// Limits are the providers' published rate limits, with some headroom.
+// Hosts not listed are only bounded by the number of workers.
+var Limits = map[string]Limit{
+	"api.themoviedb.org":     {Concurrency: 4, Interval: 50 * time.Millisecond}, // ~50 requests/s
+	"image.tmdb.org":         {Concurrency: 4},
+	"api.discogs.com":        {Concurrency: 2, Interval: 1100 * time.Millisecond}, // 60 requests/min with a token
+	"i.discogs.com":          {Concurrency: 2, Interval: time.Second},
+	"www.googleapis.com":     {Concurrency: 2, Interval: 500 * time.Millisecond},
+	"openlibrary.org":        {Concurrency: 2, Interval: time.Second},
+	"covers.openlibrary.org": {Concurrency: 2, Interval: time.Second},
+}
+
+// Transport is an http.RoundTripper applying a Limit per host
+type Transport struct {
+	Base   http.RoundTripper
+	Limits map[string]Limit
+
+	mu    sync.Mutex
+	hosts map[string]*host
+}
+
+// NewTransport limits the requests made through base
+func NewTransport(base http.RoundTripper, limits map[string]Limit) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Transport{Base: base, Limits: limits}
+}
+
+type host struct {
+	slots    chan struct{}
+	interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+// RoundTrip implements http.RoundTripper. A request holds its slot until
+// its body is closed. Waiting for a slot ends when the request's context is
+// cancelled.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	h := t.host(req.URL.Hostname())
+	if h == nil {
+		return t.Base.RoundTrip(req)
+	}
+
+	ctx := req.Context()
+	select {
+	case h.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	h.mu.Lock()
+	now := time.Now()
+	start := h.next
+	if start.Before(now) {
+		start = now
+	}
+	h.next = start.Add(h.interval)
+	h.mu.Unlock()
+	wait := time.NewTimer(time.Until(start))
+	defer wait.Stop()
+	select {
+	case <-wait.C:
+	case <-ctx.Done():
+		<-h.slots
+		return nil, ctx.Err()
+	}
+
+	resp, err := t.Base.RoundTrip(req)
+	if err != nil {
+		<-h.slots
+		return nil, err
+	}
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: func() { <-h.slots }}
+	return resp, nil
+}
+
+func (t *Transport) host(name string) *host {
+	name = strings.ToLower(name)
+	limit, ok := t.Limits[name]
+	if !ok || limit.Concurrency < 1 {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.hosts == nil {
+		t.hosts = make(map[string]*host)
+	}
+	h, ok := t.hosts[name]
+	if !ok {
+		h = &host{slots: make(chan struct{}, limit.Concurrency), interval: limit.Interval}
+		t.hosts[name] = h
+	}
+	return h
+}
+
+// releasingBody frees a request's slot once, when its body is closed
+type releasingBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
@@ -0,0 +1,96 @@
+// Package workpool runs the download scripts' lookups concurrently: a
+// bounded pool of workers with results reported in order, per-provider
+// request limits, and locks that keep two workers from writing the same
+// file at once.
+package workpool
+
+import (
+	"context"
+	"sync"
+)
+
+// Workers is the default number of workers
+const Workers = 4
+
+// Run calls job for 0..n-1 on up to workers goroutines. report gets every
+// result on the calling goroutine, in job order, as soon as that result
+// and all earlier ones are done, so output reads as if the jobs ran one
+// after another. Once ctx is cancelled no more jobs are started; Run waits
+// for the running ones and reports them before returning.
+func Run[T any](ctx context.Context, n, workers int, job func(i int) T, report func(i int, result T)) {
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > n {
+		workers = n
+	}
+
+	type done struct {
+		i      int
+		result T
+	}
+	jobs := make(chan int)
+	results := make(chan done)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results <- done{i, job(i)}
+			}
+		}()
+	}
+	go func() {
+	feed:
+		for i := 0; i < n; i++ {
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				break feed
+			}
+		}
+		close(jobs)
+		wg.Wait()
+		close(results)
+	}()
+
+	pending := make(map[int]T)
+	next := 0
+	for d := range results {
+		pending[d.i] = d.result
+		for {
+			result, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			report(next, result)
+			next++
+		}
+	}
+}
+
+// Locks serializes work on the same file, e.g. two titles resolving to
+// one page or one poster
+type Locks struct {
+	mu    sync.Mutex
+	files map[string]*sync.Mutex
+}
+
+// Lock locks path and returns the function that unlocks it
+func (l *Locks) Lock(path string) func() {
+	l.mu.Lock()
+	if l.files == nil {
+		l.files = make(map[string]*sync.Mutex)
+	}
+	m, ok := l.files[path]
+	if !ok {
+		m = &sync.Mutex{}
+		l.files[path] = m
+	}
+	l.mu.Unlock()
+
+	m.Lock()
+	return m.Unlock
+}
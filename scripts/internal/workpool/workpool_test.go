@@ -0,0 +1,165 @@
+package workpool
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunOrder(t *testing.T) {
+	for _, workers := range []int{0, 1, 3, 20} {
+		var reported []int
+		Run(context.Background(), 10, workers, func(i int) int {
+			// Later jobs finish first
+			time.Sleep(time.Duration(10-i) * time.Millisecond)
+			return i * i
+		}, func(i, result int) {
+			if result != i*i {
+				t.Errorf("%d workers: job %d reported %d", workers, i, result)
+			}
+			reported = append(reported, i)
+		})
+		if len(reported) != 10 {
+			t.Fatalf("%d workers: reported %v", workers, reported)
+		}
+		for i, got := range reported {
+			if got != i {
+				t.Errorf("%d workers: reported %v, want job order", workers, reported)
+				break
+			}
+		}
+	}
+}
+
+func TestRunNoJobs(t *testing.T) {
+	Run(context.Background(), 0, Workers, func(i int) int {
+		t.Error("job called")
+		return 0
+	}, func(i, result int) {
+		t.Error("report called")
+	})
+}
+
+func TestRunCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var started atomic.Int64
+	var reported []int
+	Run(ctx, 100, 2, func(i int) int {
+		started.Add(1)
+		if i == 3 {
+			cancel()
+		}
+		time.Sleep(time.Millisecond)
+		return i
+	}, func(i, result int) {
+		reported = append(reported, i)
+	})
+
+	if n := started.Load(); n >= 100 || n < 4 {
+		t.Errorf("%d jobs started, want the ones up to job 3 and a few running", n)
+	}
+	// Every job that started is reported, in order
+	if int64(len(reported)) != started.Load() {
+		t.Errorf("reported %d of %d started jobs", len(reported), started.Load())
+	}
+	for i, got := range reported {
+		if got != i {
+			t.Errorf("reported %v, want job order", reported)
+			break
+		}
+	}
+}
+
+func TestLocks(t *testing.T) {
+	var locks Locks
+	var inside, most atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			unlock := locks.Lock("content/en/consumed/movie/bunny.md")
+			defer unlock()
+			if n := inside.Add(1); n > most.Load() {
+				most.Store(n)
+			}
+			time.Sleep(time.Millisecond)
+			inside.Add(-1)
+		}()
+	}
+	wg.Wait()
+	if most.Load() != 1 {
+		t.Errorf("%d workers held the lock at once", most.Load())
+	}
+	// Other paths don't wait
+	unlock := locks.Lock("a")
+	locks.Lock("b")()
+	unlock()
+}
+
+// fakeTransport answers every request with an empty body, or err
+type fakeTransport struct{ err error }
+
+func (f fakeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("")), Request: req}, nil
+}
+
+// roundTrip sends a request to api.example.com, giving up after wait
+func roundTrip(tr http.RoundTripper, wait time.Duration) (*http.Response, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), wait)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "https://api.example.com/x", nil)
+	return tr.RoundTrip(req)
+}
+
+func TestTransportReleasesSlot(t *testing.T) {
+	tr := NewTransport(fakeTransport{}, map[string]Limit{"api.example.com": {Concurrency: 1}})
+
+	first, err := roundTrip(tr, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := roundTrip(tr, 20*time.Millisecond); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("second request while the first is open: err = %v, want it to wait", err)
+	}
+
+	// Closing twice frees the one slot once
+	first.Body.Close()
+	first.Body.Close()
+	second, err := roundTrip(tr, time.Second)
+	if err != nil {
+		t.Fatalf("request after Close: %v", err)
+	}
+	if _, err := roundTrip(tr, 20*time.Millisecond); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("a second Close freed another slot: err = %v", err)
+	}
+	second.Body.Close()
+
+	// Other hosts aren't limited
+	req, _ := http.NewRequest(http.MethodGet, "https://other.example.com/x", nil)
+	third, _ := roundTrip(tr, time.Second)
+	if _, err := tr.RoundTrip(req); err != nil {
+		t.Errorf("unlimited host: %v", err)
+	}
+	third.Body.Close()
+}
+
+func TestTransportReleasesSlotOnError(t *testing.T) {
+	failed := errors.New("connection refused")
+	tr := NewTransport(fakeTransport{err: failed}, map[string]Limit{"api.example.com": {Concurrency: 1}})
+	for i := 0; i < 3; i++ {
+		if _, err := roundTrip(tr, 100*time.Millisecond); !errors.Is(err, failed) {
+			t.Fatalf("request %d: err = %v, want the transport's error", i, err)
+		}
+	}
+}
@@ -2,12 +2,26 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
+
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/consumed"
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/imageset"
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/slug"
 )
 
+// overrideBase replaces an API base with the one in the environment
+// variable env, if it is set, e.g. to point a script at the provider fakes
+// (see scripts/internal/fakeprovider)
+func overrideBase(base *string, env string) {
+	if value := os.Getenv(env); value != "" {
+		*base = strings.TrimSuffix(value, "/")
+	}
+}
+
 // MovieInfo represents a movie from markdown frontmatter
 type MovieInfo struct {
 	Title     string
@@ -30,6 +44,9 @@ type MovieData struct {
 	ImagePath  string // Path for frontmatter img field
 	TrailerURL string // YouTube trailer URL
 	Draft      bool   // Mark as draft if no poster found
+	Artwork    imageset.Artwork // Placeholder and colors of the poster
+	Source     consumed.Source  // Where the data came from, for the provenance table
+	Refreshed  bool             // Every value came from the provider, none was kept from the page
 }
 
 // parseMarkdownFiles reads all markdown files in content/consumed/movie/ and extracts movie info
@@ -151,18 +168,26 @@ func updateMarkdownFrontmatter(filePath string, data MovieData) error {
 	if err != nil {
 		return err
 	}
+	newContent, err := movieFrontmatter(string(content), data)
+	if err != nil || newContent == string(content) {
+		return err
+	}
+	return consumed.WriteFile(filePath, []byte(newContent))
+}
 
-	contentStr := string(content)
+// movieFrontmatter returns a page's content with its frontmatter updated with movie
+// data, without writing anything
+func movieFrontmatter(contentStr string, data MovieData) (string, error) {
 	
 	// Find frontmatter boundaries
 	frontmatterStart := strings.Index(contentStr, "+++")
 	if frontmatterStart == -1 {
-		return fmt.Errorf("no frontmatter found")
+		return "", fmt.Errorf("no frontmatter found")
 	}
 	
 	frontmatterEnd := strings.Index(contentStr[frontmatterStart+3:], "+++")
 	if frontmatterEnd == -1 {
-		return fmt.Errorf("no closing frontmatter found")
+		return "", fmt.Errorf("no closing frontmatter found")
 	}
 	frontmatterEnd += frontmatterStart + 3
 
@@ -171,6 +196,19 @@ func updateMarkdownFrontmatter(filePath string, data MovieData) error {
 
 	updated := false
 
+	// Leave locked fields alone
+	before := &consumed.Page{Frontmatter: frontmatter}
+	before.ClearLocked(map[string]*string{
+		"year":     &data.Year,
+		"director": &data.Director,
+		"tmdb":     &data.TMDBURL,
+		"img":      &data.ImagePath,
+		"trailer":  &data.TrailerURL,
+	})
+	if before.Locked("img") {
+		data.Artwork = imageset.Artwork{}
+	}
+
 	// Update year
 	if data.Year != "" {
 		if regexp.MustCompile(`year\s*=\s*"[^"]*"`).MatchString(frontmatter) {
@@ -263,7 +301,9 @@ func updateMarkdownFrontmatter(filePath string, data MovieData) error {
 	}
 
 	// Update draft status
-	if data.Draft {
+	if before.Locked("draft") {
+		// Leave it as it is
+	} else if data.Draft {
 		if regexp.MustCompile(`draft\s*=\s*(true|false)`).MatchString(frontmatter) {
 			frontmatter = regexp.MustCompile(`draft\s*=\s*(true|false)`).ReplaceAllString(frontmatter, "draft = true")
 			updated = true
@@ -307,12 +347,110 @@ func updateMarkdownFrontmatter(filePath string, data MovieData) error {
 		updated = true
 	}
 
+	// Add placeholder and colors of the downloaded image
+	if data.Artwork.Placeholder != "" {
+		frontmatter = applyArtwork(frontmatter, data.Artwork)
+		updated = true
+	}
+
+	frontmatter = recordProvenance(before, data.Refreshed, frontmatter, data.Source, "year", "director", "tmdb", "img", "trailer")
+
 	if updated {
-		newContent := "+++" + frontmatter + "+++" + body
-		return os.WriteFile(filePath, []byte(newContent), 0644)
+		return "+++" + frontmatter + "+++" + body, nil
+	}
+
+	return contentStr, nil
+}
+
+// findPageFile finds the page of a title given on the command line. It
+// accepts a page slug or a title and looks in content/<lang>/consumed/<kind>
+// (the first language that has it) before the old content/consumed/<kind>.
+func findPageFile(contentDir, kind, title string) (string, bool) {
+	var candidates []string
+	if langs, err := consumed.Languages(contentDir); err == nil {
+		for _, lang := range langs {
+			candidates = append(candidates,
+				filepath.Join(consumed.Dir(contentDir, lang, kind), title+".md"),
+				filepath.Join(consumed.Dir(contentDir, lang, kind), consumed.PageSlug(title)+".md"))
+		}
+	}
+	candidates = append(candidates, filepath.Join(contentDir, "consumed", kind, slug.Make(title, slug.Underscore)+".md"))
+	for _, candidate := range candidates {
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate, true
+		}
 	}
+	return "", false
+}
 
-	return nil
+// applyArtwork sets the placeholder, color and accent fields after img
+func applyArtwork(frontmatter string, art imageset.Artwork) string {
+	page := &consumed.Page{Frontmatter: frontmatter}
+	art.Apply(page)
+	return page.Frontmatter
+}
+
+// recordProvenance notes src in the provenance table for the fields an
+// update changed, before being the frontmatter it started from. Refreshed
+// data came from src entirely, so every field it set is noted.
+func recordProvenance(before *consumed.Page, refreshed bool, frontmatter string, src consumed.Source, fields ...string) string {
+	if refreshed {
+		before = &consumed.Page{}
+	}
+	page := &consumed.Page{Frontmatter: frontmatter}
+	page.RecordProvenance(before, src, fields...)
+	return page.Frontmatter
+}
+
+// processedPages lists the processed pages of a kind in every language,
+// the ones -refresh-stale looks at
+func processedPages(contentDir, kind string, includeDrafts bool) ([]string, error) {
+	pages, err := consumed.LoadAll(contentDir, kind)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, page := range pages {
+		if page.Bool("processed") && (includeDrafts || !page.Bool("draft")) {
+			paths = append(paths, page.Path)
+		}
+	}
+	return paths, nil
+}
+
+// describeChanges writes the fields an update would change on a page, for
+// -refresh-stale to report
+func describeChanges(out io.Writer, path string, update func(string) (string, error)) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	updated, err := update(string(content))
+	if err != nil {
+		return
+	}
+	before, err := consumed.Parse(string(content))
+	if err != nil {
+		return
+	}
+	after, err := consumed.Parse(updated)
+	if err != nil {
+		return
+	}
+	changed := after.ChangedFields(before)
+	if len(changed) == 0 {
+		fmt.Fprintf(out, "  ✓ Nothing changed\n")
+	}
+	for _, c := range changed {
+		fmt.Fprintf(out, "  ↻ %s\n", c)
+	}
+}
+
+// pageLocks reports whether a page locks field, so its artwork isn't
+// downloaded for nothing
+func pageLocks(path, field string) bool {
+	page, err := consumed.Load(path)
+	return err == nil && page.Locked(field)
 }
 
 // AlbumInfo represents a music album from markdown frontmatter
@@ -337,6 +475,9 @@ type AlbumData struct {
 	DiscogsID  int
 	CoverURL   string
 	CoverPath  string
+	Artwork    imageset.Artwork // Placeholder and colors of the cover
+	Source     consumed.Source  // Where the data came from, for the provenance table
+	Refreshed  bool             // Every value came from the provider, none was kept from the page
 }
 
 // parseMarkdownMusicFiles reads all markdown files in content/consumed/music/ and extracts album info
@@ -465,18 +606,26 @@ func updateMarkdownMusicFrontmatter(filePath string, data AlbumData) error {
 	if err != nil {
 		return err
 	}
+	newContent, err := musicFrontmatter(string(content), data)
+	if err != nil || newContent == string(content) {
+		return err
+	}
+	return consumed.WriteFile(filePath, []byte(newContent))
+}
 
-	contentStr := string(content)
+// musicFrontmatter returns a page's content with its frontmatter updated with album
+// data, without writing anything
+func musicFrontmatter(contentStr string, data AlbumData) (string, error) {
 	
 	// Find frontmatter boundaries
 	frontmatterStart := strings.Index(contentStr, "+++")
 	if frontmatterStart == -1 {
-		return fmt.Errorf("no frontmatter found")
+		return "", fmt.Errorf("no frontmatter found")
 	}
 	
 	frontmatterEnd := strings.Index(contentStr[frontmatterStart+3:], "+++")
 	if frontmatterEnd == -1 {
-		return fmt.Errorf("no closing frontmatter found")
+		return "", fmt.Errorf("no closing frontmatter found")
 	}
 	frontmatterEnd += frontmatterStart + 3
 
@@ -485,6 +634,20 @@ func updateMarkdownMusicFrontmatter(filePath string, data AlbumData) error {
 
 	updated := false
 
+	// Leave locked fields alone
+	before := &consumed.Page{Frontmatter: frontmatter}
+	before.ClearLocked(map[string]*string{
+		"artist":       &data.Artist,
+		"year":         &data.Year,
+		"label":        &data.Label,
+		"discogs":      &data.DiscogsURL,
+		"discogsLabel": &data.LabelURL,
+		"img":          &data.CoverPath,
+	})
+	if before.Locked("img") {
+		data.Artwork = imageset.Artwork{}
+	}
+
 	// Update artist
 	if data.Artist != "" {
 		if regexp.MustCompile(`artist\s*=\s*"[^"]*"`).MatchString(frontmatter) {
@@ -597,12 +760,19 @@ func updateMarkdownMusicFrontmatter(filePath string, data AlbumData) error {
 		updated = true
 	}
 
+	// Add placeholder and colors of the downloaded image
+	if data.Artwork.Placeholder != "" {
+		frontmatter = applyArtwork(frontmatter, data.Artwork)
+		updated = true
+	}
+
+	frontmatter = recordProvenance(before, data.Refreshed, frontmatter, data.Source, "artist", "year", "label", "discogs", "discogsLabel", "img")
+
 	if updated {
-		newContent := "+++" + frontmatter + "+++" + body
-		return os.WriteFile(filePath, []byte(newContent), 0644)
+		return "+++" + frontmatter + "+++" + body, nil
 	}
 
-	return nil
+	return contentStr, nil
 }
 
 // BookInfo represents a book from markdown frontmatter
@@ -625,6 +795,7 @@ type BookData struct {
 	OpenLibraryURL string
 	CoverURL      string
 	CoverPath     string
+	Source        consumed.Source // Where the data came from, for the provenance table
 }
 
 // parseMarkdownBookFiles reads all markdown files in content/consumed/book/ and extracts book info
@@ -753,18 +924,26 @@ func updateMarkdownBookFrontmatter(filePath string, data BookData) error {
 	if err != nil {
 		return err
 	}
+	newContent, err := bookFrontmatter(string(content), data)
+	if err != nil || newContent == string(content) {
+		return err
+	}
+	return consumed.WriteFile(filePath, []byte(newContent))
+}
 
-	contentStr := string(content)
+// bookFrontmatter returns a page's content with its frontmatter updated with book
+// data, without writing anything
+func bookFrontmatter(contentStr string, data BookData) (string, error) {
 	
 	// Find frontmatter boundaries
 	frontmatterStart := strings.Index(contentStr, "+++")
 	if frontmatterStart == -1 {
-		return fmt.Errorf("no frontmatter found")
+		return "", fmt.Errorf("no frontmatter found")
 	}
 	
 	frontmatterEnd := strings.Index(contentStr[frontmatterStart+3:], "+++")
 	if frontmatterEnd == -1 {
-		return fmt.Errorf("no closing frontmatter found")
+		return "", fmt.Errorf("no closing frontmatter found")
 	}
 	frontmatterEnd += frontmatterStart + 3
 
@@ -773,6 +952,16 @@ func updateMarkdownBookFrontmatter(filePath string, data BookData) error {
 
 	updated := false
 
+	// Leave locked fields alone
+	before := &consumed.Page{Frontmatter: frontmatter}
+	before.ClearLocked(map[string]*string{
+		"author":      &data.Author,
+		"year":        &data.Year,
+		"publisher":   &data.Publisher,
+		"openlibrary": &data.OpenLibraryURL,
+		"img":         &data.CoverPath,
+	})
+
 	// Update author
 	if data.Author != "" {
 		if regexp.MustCompile(`author\s*=\s*"[^"]*"`).MatchString(frontmatter) {
@@ -872,11 +1061,12 @@ func updateMarkdownBookFrontmatter(filePath string, data BookData) error {
 		updated = true
 	}
 
+	frontmatter = recordProvenance(before, false, frontmatter, data.Source, "author", "year", "publisher", "openlibrary", "img")
+
 	if updated {
-		newContent := "+++" + frontmatter + "+++" + body
-		return os.WriteFile(filePath, []byte(newContent), 0644)
+		return "+++" + frontmatter + "+++" + body, nil
 	}
 
-	return nil
+	return contentStr, nil
 }
 
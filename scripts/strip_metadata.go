@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/consumed"
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/dither"
+	"github.com/christiankopac/christiankopac_com__hugo/scripts/internal/metadata"
+)
+
+// Image metadata stripping. Removes EXIF (camera model, serial numbers, GPS
+// location, capture time), XMP, IPTC and comments from every image that
+// gets published. Pixel data is copied as is; only images with an EXIF
+// orientation are re-encoded, turned upright first so they still display
+// the right way up, with their ICC profile copied over.
+//
+// Usage:
+//
+//	go run scripts/strip_metadata.go [directory...] [--recursive] [--check] [--no-orient]
+//
+// Directories default to content, assets and static. Exits with status 1
+// if any image had a GPS location, even after stripping it: the file with
+// the location may already be in git, so the build stops until the
+// stripped file is committed.
+
+func main() {
+	var recursive, check, noOrient bool
+	flag.BoolVar(&recursive, "recursive", false, "Include subdirectories")
+	flag.BoolVar(&recursive, "r", false, "Shorthand for -recursive")
+	flag.BoolVar(&check, "check", false, "Only report metadata, don't change any file")
+	flag.BoolVar(&noOrient, "no-orient", false, "Strip the orientation tag without turning the image upright")
+	args := parseArgs(flag.CommandLine, os.Args[1:])
+
+	baseDir := getBaseDir()
+	dirs := args
+	if len(dirs) == 0 {
+		for _, dir := range []string{"content", "assets", "static"} {
+			dirs = append(dirs, filepath.Join(baseDir, dir))
+		}
+	}
+
+	var files []string
+	for _, dir := range dirs {
+		found, err := findImages(dir, recursive)
+		if err != nil {
+			fmt.Printf("❌ %v\n", err)
+			os.Exit(1)
+		}
+		files = append(files, found...)
+	}
+
+	fmt.Printf("\n🔍 Checking %d image(s) for metadata\n\n", len(files))
+
+	stripped, rotated, clean, failed := 0, 0, 0, 0
+	var located []string
+	for _, file := range files {
+		name := relPath(baseDir, file)
+		info, changed, err := stripFile(file, check, !noOrient)
+		if err != nil {
+			fmt.Printf("✗ %s: %v\n", name, err)
+			failed++
+			continue
+		}
+		if !info.HasMetadata() {
+			clean++
+			continue
+		}
+		if info.GPS {
+			located = append(located, name)
+		}
+
+		action := "Stripped"
+		if check {
+			action = "Has"
+		}
+		fmt.Printf("✓ %s: %s %s", name, action, strings.Join(info.Segments, ", "))
+		if info.GPS {
+			fmt.Printf(" (GPS location)")
+		}
+		if changed && info.Orientation > 1 && !noOrient && info.Format != "webp" {
+			fmt.Printf(", turned upright (orientation %d)", info.Orientation)
+			rotated++
+		}
+		fmt.Println()
+		stripped++
+	}
+
+	fmt.Printf("\n%s\n", strings.Repeat("=", 50))
+	fmt.Printf("Summary:\n")
+	if check {
+		fmt.Printf("  With metadata: %d\n", stripped)
+	} else {
+		fmt.Printf("  Stripped: %d\n", stripped)
+		fmt.Printf("  Turned upright: %d\n", rotated)
+	}
+	fmt.Printf("  Clean: %d\n", clean)
+	fmt.Printf("  Failed: %d\n", failed)
+	fmt.Printf("  With GPS location: %d\n", len(located))
+
+	if len(located) > 0 {
+		fmt.Println("\n✗ These images had a GPS location:")
+		for _, name := range located {
+			fmt.Printf("  %s\n", name)
+		}
+		if check {
+			fmt.Println("\nRun go run scripts/strip_metadata.go to strip it, then commit the images.")
+		} else {
+			fmt.Println("\nThe location is stripped now; commit the images and build again.")
+		}
+	}
+	if len(located) > 0 || failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// stripFile strips the metadata of an image in place, turning it upright
+// first if it has an EXIF orientation and orient is set. It reports what
+// was found and whether the file was rewritten.
+func stripFile(path string, check, orient bool) (metadata.Info, bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return metadata.Info{}, false, err
+	}
+	out, info, err := metadata.Strip(data)
+	if err != nil || check || !info.HasMetadata() {
+		return info, false, err
+	}
+
+	if orient && info.Orientation > 1 {
+		if info.Format == "webp" {
+			fmt.Printf("⚠ %s: WebP can't be re-encoded, orientation %d is lost\n", filepath.Base(path), info.Orientation)
+		} else if out, err = upright(out, info); err != nil {
+			return info, false, err
+		}
+	}
+
+	return info, true, consumed.WriteFile(path, out)
+}
+
+// upright decodes an image, applies its EXIF orientation and encodes it
+// again. Go's encoders write no metadata, so the color profile is copied
+// from data.
+func upright(data []byte, info metadata.Info) ([]byte, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	img = metadata.Orient(img, info.Orientation)
+	var buf bytes.Buffer
+	if info.Format == "png" {
+		err = png.Encode(&buf, img)
+	} else {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: dither.JPEGQuality})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return metadata.CopyProfile(data, buf.Bytes()), nil
+}
+
+// findImages lists the JPEG, PNG and WebP files in a directory
+func findImages(directory string, recursive bool) ([]string, error) {
+	if _, err := os.Stat(directory); os.IsNotExist(err) {
+		return nil, nil
+	}
+	var files []string
+	err := filepath.WalkDir(directory, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != directory && !recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if dither.IsImage(path) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files, err
+}
+
+func relPath(baseDir, path string) string {
+	if rel, err := filepath.Rel(baseDir, path); err == nil {
+		return rel
+	}
+	return path
+}
+
+// parseArgs parses flags that may come before or after the positional
+// arguments, e.g. "content --check"
+func parseArgs(fs *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		fs.Parse(args)
+		if fs.NArg() == 0 {
+			return positional
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+func getBaseDir() string {
+	// Start from current working directory and walk up to find project root
+	wd, _ := os.Getwd()
+	startWd := wd
+	for {
+		if _, err := os.Stat(filepath.Join(wd, "content")); err == nil {
+			return wd
+		}
+		parent := filepath.Dir(wd)
+		if parent == wd {
+			break
+		}
+		wd = parent
+	}
+	return startWd
+}